@@ -0,0 +1,122 @@
+// Package builder builds CWL tools and workflows in Go, without knowing
+// how the loader represents them, e.g. that "File?" is a union of File and
+// null. For example:
+//
+//	tool := builder.NewTool("bwa", "mem").Docker("biocontainers/bwa:0.7.17")
+//	tool.Input("index").Type(builder.File).Position(1)
+//	tool.Input("reads").Type(builder.Array(builder.File)).Position(2)
+//	tool.Output("sam").Type(builder.Stdout)
+//
+//	wf := builder.NewWorkflow()
+//	wf.Input("reads").Type(builder.Array(builder.File))
+//	wf.Input("index").Type(builder.File)
+//	wf.Step("align", tool).In("reads", "reads").In("index", "index")
+//	wf.Output("sam", "align/sam")
+//	b, err := wf.YAML()
+//
+// Builders check what they can as they're called, e.g. for duplicate IDs,
+// and Build checks the rest with cwl.Validate, e.g. that the sources of
+// step inputs exist and have compatible types.
+//
+// Built documents are marshaled by YAML in the form usually written by hand,
+// which cwl.Load reads back as the same document. See cwl.MarshalCompactYAML.
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/buchanae/cwl"
+	"strings"
+)
+
+// Error lists the problems found while building a document, e.g. an input
+// which was defined twice. Problems found by cwl.Validate are returned as
+// a *cwl.ValidationError instead.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid document:\n" + strings.Join(e.Problems, "\n")
+}
+
+// Process is a process which may be run by a step: a *Tool, a *Workflow,
+// or a loaded document. See Document.
+type Process interface {
+	// document returns the process as built so far, and the problems
+	// found while building it.
+	document() (cwl.Document, []string)
+}
+
+// Document returns a Process for a document which wasn't built by this
+// package, e.g. one loaded by cwl.Load.
+func Document(doc cwl.Document) Process {
+	return loaded{doc}
+}
+
+type loaded struct {
+	doc cwl.Document
+}
+
+func (l loaded) document() (cwl.Document, []string) {
+	return l.doc, nil
+}
+
+// problems records the problems found by a builder.
+type problems struct {
+	list []string
+}
+
+func (p *problems) add(format string, args ...interface{}) {
+	p.list = append(p.list, fmt.Sprintf(format, args...))
+}
+
+// ids records the IDs of inputs, outputs or steps,
+// to find duplicates.
+type ids map[string]bool
+
+// check records a problem if "id" is invalid or a duplicate.
+func (s ids) check(p *problems, kind, id string) {
+	if err := checkID(id); err != nil {
+		p.add("%s: %s", kind, err)
+		return
+	}
+	if s[id] {
+		p.add("%s %q is defined more than once", kind, id)
+	}
+	s[id] = true
+}
+
+// build resolves the IDs of a built document, as Load does, and validates it.
+func build(doc cwl.Document, probs []string) error {
+	if len(probs) > 0 {
+		return &Error{Problems: probs}
+	}
+	cwl.ResolveIDs(doc, "")
+
+	var errs []cwl.Diagnostic
+	for _, d := range cwl.Validate(doc) {
+		if d.Severity == cwl.SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return &cwl.ValidationError{Diagnostics: errs}
+	}
+	return nil
+}
+
+// loadValue returns a default value as Load reads it, e.g. an int for
+// int64(1), a float64 for float32(0.5), or a cwl.File for a map with
+// "class: File", so that built documents are loaded back identically.
+func loadValue(v interface{}) (cwl.Value, error) {
+	b, err := json.Marshal(map[string]interface{}{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	vals, err := cwl.LoadValuesBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return vals["v"], nil
+}
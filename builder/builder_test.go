@@ -0,0 +1,293 @@
+package builder
+
+import (
+	"github.com/buchanae/cwl"
+	"github.com/kr/pretty"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func alignTool() *Tool {
+	tool := NewTool("bwa", "mem").Docker("biocontainers/bwa:0.7.17").Version("v1.0")
+	tool.Arguments("-t", "$(runtime.cores)")
+	tool.Input("index").Type(File).Position(1).SecondaryFiles(".bwt", ".sa")
+	tool.Input("reads").Type(Array(File)).Prefix("-r").Doc("reads to align")
+	tool.Input("mode").Type(Optional(Enum("fast", "slow"))).Prefix("--mode").Separate(false)
+	tool.Input("threads").Type(Int).Default(4)
+	tool.Input("min_score").Type(Optional(Float)).Default(float32(0.5)).Prefix("-T")
+	tool.Input("paired").Type(Boolean).Default(true).Prefix("-p")
+	tool.Input("group").Type(Record(
+		Field{Name: "id", Type: String},
+		Field{Name: "library", Type: Optional(String)},
+	))
+	tool.Output("sam").Type(Stdout)
+	tool.Output("log").Type(Optional(File)).Glob("bwa.log")
+	return tool
+}
+
+func TestTool(t *testing.T) {
+	b, err := alignTool().YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `class: CommandLineTool
+cwlVersion: v1.0
+requirements:
+  DockerRequirement:
+    dockerPull: biocontainers/bwa:0.7.17
+inputs:
+  index:
+    type: File
+    secondaryFiles:
+    - .bwt
+    - .sa
+    inputBinding:
+      position: 1
+  reads:
+    doc: reads to align
+    type: File[]
+    inputBinding:
+      prefix: -r
+  mode:
+    type:
+    - type: enum
+      symbols:
+      - fast
+      - slow
+    - "null"
+    inputBinding:
+      prefix: --mode
+      separate: false
+  threads:
+    default: 4
+    type: int
+  min_score:
+    default: 0.5
+    type: float?
+    inputBinding:
+      prefix: -T
+  paired:
+    default: true
+    type: boolean
+    inputBinding:
+      prefix: -p
+  group:
+    type:
+      type: record
+      fields:
+      - name: id
+        type: string
+      - name: library
+        type: string?
+outputs:
+  sam: stdout
+  log:
+    type: File?
+    outputBinding:
+      glob: bwa.log
+baseCommand:
+- bwa
+- mem
+arguments:
+- valueFrom: -t
+- valueFrom: $(runtime.cores)
+`
+	if string(b) != expect {
+		t.Errorf("unexpected YAML:\n%s", b)
+	}
+	expectRoundTrip(t, alignTool())
+
+	tool, err := alignTool().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults []cwl.Value
+	for _, in := range tool.Inputs {
+		defaults = append(defaults, in.Default)
+	}
+	expectDefaults := []cwl.Value{nil, nil, nil, 4, 0.5, true, nil}
+	if !reflect.DeepEqual(defaults, expectDefaults) {
+		t.Errorf("unexpected defaults: %#v", defaults)
+	}
+}
+
+func TestWorkflow(t *testing.T) {
+	count := NewTool("wc", "-l").Stdout("count.txt")
+	count.Input("file").Type(File).Bind()
+	count.Output("count").Type(Stdout)
+
+	inner := NewWorkflow()
+	inner.Input("files").Type(Array(File))
+	inner.Step("count", count).In("file", "files").Scatter("file")
+	inner.Output("counts", "count/count")
+
+	wf := NewWorkflow().Version("v1.0").Requirement(cwl.InlineJavascriptRequirement{})
+	wf.Input("reads").Type(Array(File))
+	wf.Input("more").Type(Array(File))
+	wf.Input("index").Type(File)
+	wf.Step("align", alignTool()).
+		In("reads", "reads", "more").
+		LinkMerge("reads", cwl.MergeFlattened).
+		In("index", "index").
+		Default("threads", 8).
+		In("group", "index").
+		ValueFrom("group", "$({id: self.nameroot})").
+		Out("sam")
+	wf.Step("count", inner).In("files", "align/sam", "index")
+	wf.Output("sam", "align/sam")
+	wf.Output("counts", "count/counts")
+	wf.Output("reference", "index")
+
+	doc, err := wf.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reqs []string
+	for _, r := range doc.Requirements {
+		reqs = append(reqs, reflect.TypeOf(r).Name())
+	}
+	expectReqs := "InlineJavascriptRequirement, MultipleInputFeatureRequirement, SubworkflowFeatureRequirement, StepInputExpressionRequirement"
+	if got := strings.Join(reqs, ", "); got != expectReqs {
+		t.Errorf("unexpected requirements: %s", got)
+	}
+	inner_, _ := doc.Steps[1].Run.(*cwl.Workflow)
+	if inner_ == nil || len(inner_.Requirements) != 1 {
+		t.Errorf("expected the subworkflow to require scatter")
+	}
+
+	var types []string
+	for _, out := range doc.Outputs {
+		types = append(types, typeString(out.Type))
+	}
+	if got := strings.Join(types, ", "); got != "File, File[], File" {
+		t.Errorf("unexpected output types: %s", got)
+	}
+	expectRoundTrip(t, wf)
+}
+
+func typeString(types []cwl.OutputType) string {
+	var out []string
+	for _, t := range types {
+		if a, ok := t.(cwl.OutputArray); ok {
+			out = append(out, typeString(a.Items)+"[]")
+		} else {
+			out = append(out, t.String())
+		}
+	}
+	return strings.Join(out, " | ")
+}
+
+func TestErrors(t *testing.T) {
+	tool := NewTool("echo")
+	tool.Input("x").Type(Int)
+	tool.Input("x").Type(Stdout)
+	tool.Input("a/b").Type(Enum())
+	tool.Input("untyped")
+	tool.Output("y").Type(Record(Field{Name: "z", Type: Array(Stderr)}))
+
+	_, err := tool.Build()
+	expect := `invalid document:
+input "x" is defined more than once
+input "x": stdout may only be the type of a tool output
+input: invalid ID "a/b"
+input "a/b": enum has no symbols
+input "untyped": missing type`
+	if err == nil || err.Error() != expect {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	wf := NewWorkflow()
+	wf.Input("x").Type(Int)
+	wf.Step("echo", NewTool("echo")).In("y", "x/y/z")
+	wf.Output("out", "echo/missing")
+	_, err = wf.Build()
+	expect = `invalid document:
+step "echo": input "y": invalid source "x/y/z"
+output "out": unknown source "echo/missing"`
+	if err == nil || err.Error() != expect {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Problems which are found by cwl.Validate.
+	wf = NewWorkflow()
+	wf.Input("x").Type(Int)
+	wf.Step("echo", NewTool("echo")).In("y", "missing")
+	wf.Output("out", "x").Type(File)
+	_, err = wf.Build()
+	if _, ok := err.(*cwl.ValidationError); !ok {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+// expectRoundTrip checks that a built document is loaded as the same
+// document from its YAML.
+func expectRoundTrip(t *testing.T, p interface {
+	Process
+	YAML() ([]byte, error)
+}) {
+	t.Helper()
+	doc, probs := p.document()
+	if err := build(doc, probs); err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := cwl.LoadDocumentBytes(b, "", nil, cwl.Strict())
+	if err != nil {
+		t.Fatalf("loading built YAML: %s\n%s", err, b)
+	}
+	clearPositions(reflect.ValueOf(&loaded))
+	if !reflect.DeepEqual(doc, loaded) {
+		t.Errorf("loaded document differs from built document:\n%s", pretty.Diff(doc, loaded))
+	}
+}
+
+var posType = reflect.TypeOf(cwl.Position{})
+
+// clearPositions zeroes every Position in "v", since built documents
+// don't have positions.
+func clearPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			clearPositions(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		// Values held by interfaces aren't addressable, so copy, clear, and replace.
+		c := reflect.New(v.Elem().Type()).Elem()
+		c.Set(v.Elem())
+		clearPositions(c)
+		if v.CanSet() {
+			v.Set(c)
+		}
+	case reflect.Struct:
+		if v.Type() == posType {
+			v.Set(reflect.Zero(posType))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				clearPositions(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearPositions(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			clearPositions(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package builder
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+)
+
+// Tool builds a CommandLineTool. See NewTool.
+type Tool struct {
+	tool     cwl.Tool
+	inputs   []*Input
+	outputs  []*Output
+	ids      ids
+	problems problems
+}
+
+// NewTool returns a builder for a CommandLineTool which runs
+// "baseCommand", e.g. NewTool("bwa", "mem"). The tool's cwlVersion
+// is cwl.LatestVersion.
+func NewTool(baseCommand ...string) *Tool {
+	return &Tool{
+		tool: cwl.Tool{
+			CWLVersion:  cwl.LatestVersion,
+			BaseCommand: baseCommand,
+		},
+		ids: ids{},
+	}
+}
+
+// ID sets the ID of the tool.
+func (t *Tool) ID(id string) *Tool {
+	t.tool.ID = id
+	return t
+}
+
+// Version sets the cwlVersion of the tool, e.g. "v1.0".
+func (t *Tool) Version(v string) *Tool {
+	t.tool.CWLVersion = v
+	return t
+}
+
+func (t *Tool) Label(label string) *Tool {
+	t.tool.Label = label
+	return t
+}
+
+func (t *Tool) Doc(doc string) *Tool {
+	t.tool.Doc = doc
+	return t
+}
+
+// Docker adds a DockerRequirement to pull "image",
+// e.g. "biocontainers/bwa:0.7.17".
+func (t *Tool) Docker(image string) *Tool {
+	return t.Requirement(cwl.DockerRequirement{Pull: image})
+}
+
+// InlineJavascript adds an InlineJavascriptRequirement, which allows
+// JavaScript expressions such as $(inputs.n + 1).
+func (t *Tool) InlineJavascript() *Tool {
+	return t.Requirement(cwl.InlineJavascriptRequirement{})
+}
+
+func (t *Tool) Requirement(r cwl.Requirement) *Tool {
+	t.tool.Requirements = append(t.tool.Requirements, r)
+	return t
+}
+
+func (t *Tool) Hint(r cwl.Requirement) *Tool {
+	t.tool.Hints = append(t.tool.Hints, r)
+	return t
+}
+
+// Arguments adds arguments to the command line, after the base command,
+// e.g. "-t" and "$(runtime.cores)".
+func (t *Tool) Arguments(args ...string) *Tool {
+	for _, a := range args {
+		t.tool.Arguments = append(t.tool.Arguments, &cwl.CommandLineBinding{
+			ValueFrom: cwl.Expression(a),
+		})
+	}
+	return t
+}
+
+// Stdin sets the file passed to the tool's standard input,
+// e.g. "$(inputs.reads.path)".
+func (t *Tool) Stdin(expr string) *Tool {
+	t.tool.Stdin = cwl.Expression(expr)
+	return t
+}
+
+// Stdout sets the name of the file the tool's standard output is written to.
+func (t *Tool) Stdout(name string) *Tool {
+	t.tool.Stdout = cwl.Expression(name)
+	return t
+}
+
+// Stderr sets the name of the file the tool's standard error is written to.
+func (t *Tool) Stderr(name string) *Tool {
+	t.tool.Stderr = cwl.Expression(name)
+	return t
+}
+
+// Input adds an input. Inputs are only added to the command line
+// if they have a binding, e.g. a Prefix or Position.
+func (t *Tool) Input(id string) *Input {
+	t.ids.check(&t.problems, "input", id)
+	in := &Input{in: cwl.CommandInput{ID: id}, problems: &t.problems}
+	t.inputs = append(t.inputs, in)
+	return in
+}
+
+// Output adds an output.
+func (t *Tool) Output(id string) *Output {
+	t.ids.check(&t.problems, "output", id)
+	out := &Output{out: cwl.CommandOutput{ID: id}, problems: &t.problems}
+	t.outputs = append(t.outputs, out)
+	return out
+}
+
+// Build returns the tool, or an error listing the problems found while
+// building it, or by cwl.Validate.
+func (t *Tool) Build() (*cwl.Tool, error) {
+	doc, probs := t.document()
+	if err := build(doc, probs); err != nil {
+		return nil, err
+	}
+	return doc.(*cwl.Tool), nil
+}
+
+// YAML builds the tool and marshals it to YAML.
+func (t *Tool) YAML() ([]byte, error) {
+	doc, err := t.Build()
+	if err != nil {
+		return nil, err
+	}
+	return cwl.MarshalCompactYAML(doc)
+}
+
+func (t *Tool) document() (cwl.Document, []string) {
+	tool := t.tool
+	tool.Inputs = nil
+	tool.Outputs = nil
+
+	var probs []string
+	probs = append(probs, t.problems.list...)
+	for _, in := range t.inputs {
+		if !in.typed {
+			probs = append(probs, fmt.Sprintf("input %q: missing type", in.in.ID))
+		}
+		// Copy the binding, so that the built tool doesn't change
+		// if the builder is used again.
+		c := in.in
+		if c.InputBinding != nil {
+			b := *c.InputBinding
+			c.InputBinding = &b
+		}
+		tool.Inputs = append(tool.Inputs, c)
+	}
+	for _, out := range t.outputs {
+		if !out.typed {
+			probs = append(probs, fmt.Sprintf("output %q: missing type", out.out.ID))
+		}
+		c := out.out
+		if c.OutputBinding != nil {
+			b := *c.OutputBinding
+			c.OutputBinding = &b
+		}
+		tool.Outputs = append(tool.Outputs, c)
+	}
+	return &tool, probs
+}
+
+// Input builds an input of a tool. See Tool.Input.
+type Input struct {
+	in       cwl.CommandInput
+	typed    bool
+	problems *problems
+}
+
+// Type sets the type of the input.
+func (in *Input) Type(t Type) *Input {
+	types, err := t.inputTypes()
+	if err != nil {
+		in.problems.add("input %q: %s", in.in.ID, err)
+	}
+	in.in.Type = types
+	in.typed = true
+	return in
+}
+
+func (in *Input) Label(label string) *Input {
+	in.in.Label = label
+	return in
+}
+
+func (in *Input) Doc(doc string) *Input {
+	in.in.Doc = doc
+	return in
+}
+
+// Default sets the default value of the input, e.g. 1, "fast",
+// or a cwl.File.
+func (in *Input) Default(v interface{}) *Input {
+	d, err := loadValue(v)
+	if err != nil {
+		in.problems.add("input %q: invalid default: %s", in.in.ID, err)
+	}
+	in.in.Default = d
+	return in
+}
+
+// SecondaryFiles sets the patterns of the input's secondary files,
+// e.g. ".bai" or "^.bai".
+func (in *Input) SecondaryFiles(patterns ...string) *Input {
+	in.in.SecondaryFiles = secondaryFiles(patterns)
+	return in
+}
+
+// Bind adds the input to the command line, after the arguments
+// with a lower position.
+func (in *Input) Bind() *Input {
+	in.binding()
+	return in
+}
+
+func (in *Input) Position(p int) *Input {
+	in.binding().Position = p
+	return in
+}
+
+// Prefix adds the input to the command line, after "prefix", e.g. "-r".
+func (in *Input) Prefix(prefix string) *Input {
+	in.binding().Prefix = prefix
+	return in
+}
+
+// Separate sets whether the prefix and the value are separate arguments,
+// which they are by default.
+func (in *Input) Separate(separate bool) *Input {
+	in.binding().Separate.Set(separate)
+	return in
+}
+
+// ItemSeparator joins the items of an array into one argument, e.g. ",".
+func (in *Input) ItemSeparator(sep string) *Input {
+	in.binding().ItemSeparator = sep
+	return in
+}
+
+// ValueFrom adds an expression to the command line, instead of the value.
+func (in *Input) ValueFrom(expr string) *Input {
+	in.binding().ValueFrom = cwl.Expression(expr)
+	return in
+}
+
+func (in *Input) binding() *cwl.CommandLineBinding {
+	if in.in.InputBinding == nil {
+		in.in.InputBinding = &cwl.CommandLineBinding{}
+	}
+	return in.in.InputBinding
+}
+
+// Output builds an output of a tool. See Tool.Output.
+type Output struct {
+	out      cwl.CommandOutput
+	typed    bool
+	problems *problems
+}
+
+// Type sets the type of the output.
+func (out *Output) Type(t Type) *Output {
+	types, err := t.outputTypes()
+	if err != nil {
+		out.problems.add("output %q: %s", out.out.ID, err)
+	}
+	out.out.Type = types
+	out.typed = true
+	return out
+}
+
+func (out *Output) Label(label string) *Output {
+	out.out.Label = label
+	return out
+}
+
+func (out *Output) Doc(doc string) *Output {
+	out.out.Doc = doc
+	return out
+}
+
+// SecondaryFiles sets the patterns of the output's secondary files.
+func (out *Output) SecondaryFiles(patterns ...string) *Output {
+	out.out.SecondaryFiles = secondaryFiles(patterns)
+	return out
+}
+
+// Glob sets the patterns which find the output's files,
+// e.g. "*.bam" or "$(inputs.prefix).bam".
+func (out *Output) Glob(patterns ...string) *Output {
+	b := out.binding()
+	b.Glob = nil
+	for _, p := range patterns {
+		b.Glob = append(b.Glob, cwl.Expression(p))
+	}
+	return out
+}
+
+// LoadContents loads the first 64 KiB of the output's files,
+// for use by OutputEval.
+func (out *Output) LoadContents() *Output {
+	out.binding().LoadContents = true
+	return out
+}
+
+// OutputEval sets an expression which returns the value of the output,
+// e.g. "$(self[0].contents)".
+func (out *Output) OutputEval(expr string) *Output {
+	out.binding().OutputEval = cwl.Expression(expr)
+	return out
+}
+
+func (out *Output) binding() *cwl.CommandOutputBinding {
+	if out.out.OutputBinding == nil {
+		out.out.OutputBinding = &cwl.CommandOutputBinding{}
+	}
+	return out.out.OutputBinding
+}
+
+func secondaryFiles(patterns []string) []cwl.SecondaryFileSchema {
+	var out []cwl.SecondaryFileSchema
+	for _, p := range patterns {
+		out = append(out, cwl.SecondaryFileSchema{Pattern: cwl.Expression(p)})
+	}
+	return out
+}
@@ -0,0 +1,249 @@
+package builder
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+	"strings"
+)
+
+// Type is the type of an input, output or record field, e.g. File,
+// Array(File) or Optional(Int).
+type Type struct {
+	// name is the name of a basic type, e.g. "File", or "array",
+	// "enum" or "record".
+	name     string
+	items    *Type
+	symbols  []string
+	fields   []Field
+	optional bool
+}
+
+// Basic types. Stdout and Stderr may only be used by the outputs of a tool.
+var (
+	Any       = Type{name: "Any"}
+	Boolean   = Type{name: "boolean"}
+	Int       = Type{name: "int"}
+	Long      = Type{name: "long"}
+	Float     = Type{name: "float"}
+	Double    = Type{name: "double"}
+	String    = Type{name: "string"}
+	File      = Type{name: "File"}
+	Directory = Type{name: "Directory"}
+	Stdout    = Type{name: "stdout"}
+	Stderr    = Type{name: "stderr"}
+)
+
+// Field is a field of a record type. See Record.
+type Field struct {
+	Name string
+	Type Type
+	Doc  string
+}
+
+// Array returns the type of an array of "items".
+func Array(items Type) Type {
+	return Type{name: "array", items: &items}
+}
+
+// Optional returns "t", or null.
+func Optional(t Type) Type {
+	t.optional = true
+	return t
+}
+
+// Enum returns the type of a string which is one of the given symbols.
+func Enum(symbols ...string) Type {
+	return Type{name: "enum", symbols: symbols}
+}
+
+// Record returns the type of an object with the given fields.
+func Record(fields ...Field) Type {
+	return Type{name: "record", fields: fields}
+}
+
+// String returns the type in the form used by documents, e.g. "File[]?".
+func (t Type) String() string {
+	s := t.name
+	if t.name == "array" {
+		s = t.items.String() + "[]"
+	}
+	if t.optional {
+		s += "?"
+	}
+	return s
+}
+
+// basicInputTypes maps the names of basic types to their cwl types.
+// Every basic type is both an input and an output type,
+// except for stdout and stderr.
+var basicInputTypes = map[string]cwl.InputType{
+	"Any":       cwl.Any{},
+	"boolean":   cwl.Boolean{},
+	"int":       cwl.Int{},
+	"long":      cwl.Long{},
+	"float":     cwl.Float{},
+	"double":    cwl.Double{},
+	"string":    cwl.String{},
+	"File":      cwl.FileType{},
+	"Directory": cwl.DirectoryType{},
+}
+
+// inputTypes returns the type as Load reads it, e.g. [File, null]
+// for "File?".
+func (t Type) inputTypes() ([]cwl.InputType, error) {
+	var it cwl.InputType
+	switch t.name {
+	case "":
+		return nil, fmt.Errorf("missing type")
+	case "stdout", "stderr":
+		return nil, fmt.Errorf("%s may only be the type of a tool output", t.name)
+
+	case "array":
+		items, err := t.items.inputTypes()
+		if err != nil {
+			return nil, err
+		}
+		it = cwl.InputArray{Items: items}
+
+	case "enum":
+		if err := checkSymbols(t.symbols); err != nil {
+			return nil, err
+		}
+		it = cwl.InputEnum{Symbols: t.symbols}
+
+	case "record":
+		if err := checkFields(t.fields); err != nil {
+			return nil, err
+		}
+		var fields []cwl.InputField
+		for _, f := range t.fields {
+			types, err := f.Type.inputTypes()
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", f.Name, err)
+			}
+			fields = append(fields, cwl.InputField{Name: f.Name, Doc: f.Doc, Type: types})
+		}
+		it = cwl.InputRecord{Fields: fields}
+
+	default:
+		it = basicInputTypes[t.name]
+	}
+
+	types := []cwl.InputType{it}
+	if t.optional {
+		types = append(types, cwl.Null{})
+	}
+	return types, nil
+}
+
+// outputTypes returns the type as Load reads it. See inputTypes.
+func (t Type) outputTypes() ([]cwl.OutputType, error) {
+	var ot cwl.OutputType
+	switch t.name {
+	case "":
+		return nil, fmt.Errorf("missing type")
+	case "stdout":
+		ot = cwl.Stdout{}
+	case "stderr":
+		ot = cwl.Stderr{}
+
+	case "array":
+		items, err := t.items.outputTypes()
+		if err != nil {
+			return nil, err
+		}
+		ot = cwl.OutputArray{Items: items}
+
+	case "enum":
+		if err := checkSymbols(t.symbols); err != nil {
+			return nil, err
+		}
+		ot = cwl.OutputEnum{Symbols: t.symbols}
+
+	case "record":
+		if err := checkFields(t.fields); err != nil {
+			return nil, err
+		}
+		var fields []cwl.OutputField
+		for _, f := range t.fields {
+			types, err := f.Type.outputTypes()
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", f.Name, err)
+			}
+			fields = append(fields, cwl.OutputField{Name: f.Name, Doc: f.Doc, Type: types})
+		}
+		ot = cwl.OutputRecord{Fields: fields}
+
+	default:
+		ot = basicInputTypes[t.name].(cwl.OutputType)
+	}
+
+	types := []cwl.OutputType{ot}
+	if t.optional {
+		types = append(types, cwl.Null{})
+	}
+	return types, nil
+}
+
+func checkSymbols(symbols []string) error {
+	if len(symbols) == 0 {
+		return fmt.Errorf("enum has no symbols")
+	}
+	seen := map[string]bool{}
+	for _, s := range symbols {
+		if s == "" || seen[s] {
+			return fmt.Errorf("invalid or duplicate enum symbol %q", s)
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+func checkFields(fields []Field) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("record has no fields")
+	}
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if err := checkID(f.Name); err != nil || seen[f.Name] {
+			return fmt.Errorf("invalid or duplicate field name %q", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
+
+// outputTypesOf returns the output types matching a list of input types,
+// e.g. the type of a workflow output connected to a workflow input.
+func outputTypesOf(types []cwl.InputType) []cwl.OutputType {
+	var out []cwl.OutputType
+	for _, t := range types {
+		switch z := t.(type) {
+		case cwl.InputArray:
+			out = append(out, cwl.OutputArray{Items: outputTypesOf(z.Items)})
+		case cwl.InputEnum:
+			out = append(out, cwl.OutputEnum{Symbols: z.Symbols})
+		case cwl.InputRecord:
+			var fields []cwl.OutputField
+			for _, f := range z.Fields {
+				fields = append(fields, cwl.OutputField{Name: f.Name, Doc: f.Doc, Type: outputTypesOf(f.Type)})
+			}
+			out = append(out, cwl.OutputRecord{Fields: fields})
+		case cwl.OutputType:
+			out = append(out, z)
+		}
+	}
+	return out
+}
+
+// checkID returns an error if "id" can't be used as the ID of an input,
+// output or step, e.g. because it's empty or contains "/".
+func checkID(id string) error {
+	if id == "" {
+		return fmt.Errorf("empty ID")
+	}
+	if strings.ContainsAny(id, "/# \t\n") {
+		return fmt.Errorf("invalid ID %q", id)
+	}
+	return nil
+}
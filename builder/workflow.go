@@ -0,0 +1,482 @@
+package builder
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+	"reflect"
+	"strings"
+)
+
+// Workflow builds a Workflow. See NewWorkflow.
+type Workflow struct {
+	wf       cwl.Workflow
+	inputs   []*WorkflowInput
+	outputs  []*WorkflowOutput
+	steps    []*Step
+	ids      ids
+	problems problems
+}
+
+// NewWorkflow returns a builder for a Workflow. The workflow's cwlVersion
+// is cwl.LatestVersion.
+//
+// The requirements of the features used by the workflow, e.g.
+// ScatterFeatureRequirement, are added when it's built.
+func NewWorkflow() *Workflow {
+	return &Workflow{
+		wf:  cwl.Workflow{CWLVersion: cwl.LatestVersion},
+		ids: ids{},
+	}
+}
+
+// ID sets the ID of the workflow.
+func (w *Workflow) ID(id string) *Workflow {
+	w.wf.ID = id
+	return w
+}
+
+// Version sets the cwlVersion of the workflow, e.g. "v1.0".
+func (w *Workflow) Version(v string) *Workflow {
+	w.wf.CWLVersion = v
+	return w
+}
+
+func (w *Workflow) Label(label string) *Workflow {
+	w.wf.Label = label
+	return w
+}
+
+func (w *Workflow) Doc(doc string) *Workflow {
+	w.wf.Doc = doc
+	return w
+}
+
+func (w *Workflow) Requirement(r cwl.Requirement) *Workflow {
+	w.wf.Requirements = append(w.wf.Requirements, r)
+	return w
+}
+
+func (w *Workflow) Hint(r cwl.Requirement) *Workflow {
+	w.wf.Hints = append(w.wf.Hints, r)
+	return w
+}
+
+// Input adds an input.
+func (w *Workflow) Input(id string) *WorkflowInput {
+	w.ids.check(&w.problems, "input", id)
+	in := &WorkflowInput{in: cwl.WorkflowInput{ID: id}, problems: &w.problems}
+	w.inputs = append(w.inputs, in)
+	return in
+}
+
+// Output adds an output connected to "sources", e.g. "align/bam".
+// The type of the output is the type of the source, unless it's set
+// with Type.
+func (w *Workflow) Output(id string, sources ...string) *WorkflowOutput {
+	w.ids.check(&w.problems, "output", id)
+	checkSources(&w.problems, fmt.Sprintf("output %q", id), sources)
+	out := &WorkflowOutput{
+		out:      cwl.WorkflowOutput{ID: id, OutputSource: sources},
+		problems: &w.problems,
+	}
+	w.outputs = append(w.outputs, out)
+	return out
+}
+
+// Step adds a step which runs a process. The step's outputs are all the
+// outputs of the process, unless they're set with Out.
+func (w *Workflow) Step(id string, run Process) *Step {
+	w.ids.check(&w.problems, "step", id)
+	if run == nil {
+		w.problems.add("step %q: missing process", id)
+		run = Document(nil)
+	}
+	s := &Step{step: cwl.Step{ID: id}, run: run, problems: &w.problems}
+	w.steps = append(w.steps, s)
+	return s
+}
+
+// Build returns the workflow, or an error listing the problems found while
+// building it, or by cwl.Validate.
+func (w *Workflow) Build() (*cwl.Workflow, error) {
+	doc, probs := w.document()
+	if err := build(doc, probs); err != nil {
+		return nil, err
+	}
+	return doc.(*cwl.Workflow), nil
+}
+
+// YAML builds the workflow and marshals it to YAML.
+func (w *Workflow) YAML() ([]byte, error) {
+	doc, err := w.Build()
+	if err != nil {
+		return nil, err
+	}
+	return cwl.MarshalCompactYAML(doc)
+}
+
+func (w *Workflow) document() (cwl.Document, []string) {
+	wf := w.wf
+	wf.Inputs = nil
+	wf.Outputs = nil
+	wf.Steps = nil
+	wf.Requirements = append([]cwl.Requirement(nil), w.wf.Requirements...)
+
+	var probs []string
+	probs = append(probs, w.problems.list...)
+
+	inputs := map[string][]cwl.InputType{}
+	for _, in := range w.inputs {
+		if !in.typed {
+			probs = append(probs, fmt.Sprintf("input %q: missing type", in.in.ID))
+		}
+		inputs[in.in.ID] = in.in.Type
+		wf.Inputs = append(wf.Inputs, in.in)
+	}
+
+	steps := map[string]cwl.Step{}
+	for _, s := range w.steps {
+		step, p := s.document()
+		for _, x := range p {
+			probs = append(probs, fmt.Sprintf("step %q: %s", s.step.ID, x))
+		}
+		steps[step.ID] = step
+		wf.Steps = append(wf.Steps, step)
+	}
+
+	for _, out := range w.outputs {
+		c := out.out
+		if !out.typed {
+			types, err := sourceType(c.OutputSource, inputs, steps)
+			if err != nil {
+				probs = append(probs, fmt.Sprintf("output %q: %s", c.ID, err))
+			}
+			c.Type = types
+		}
+		wf.Outputs = append(wf.Outputs, c)
+	}
+
+	addFeatures(&wf)
+	return &wf, probs
+}
+
+// sourceType returns the type of the source of a workflow output,
+// which is a workflow input or step output.
+func sourceType(sources []string, inputs map[string][]cwl.InputType, steps map[string]cwl.Step) ([]cwl.OutputType, error) {
+	if len(sources) == 0 {
+		// Reported by checkSources.
+		return nil, nil
+	}
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("can't find the type of an output with %d sources, set it with Type", len(sources))
+	}
+	src := sources[0]
+
+	i := strings.Index(src, "/")
+	if i == -1 {
+		types, ok := inputs[src]
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q", src)
+		}
+		return outputTypesOf(types), nil
+	}
+
+	step, ok := steps[src[:i]]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", src)
+	}
+	_, outputs := processOutputs(step.Run)
+	types, ok := outputs[src[i+1:]]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", src)
+	}
+
+	// Stdout and stderr are Files outside of the tool.
+	var out []cwl.OutputType
+	for _, t := range types {
+		switch t.(type) {
+		case cwl.Stdout, cwl.Stderr:
+			t = cwl.FileType{}
+		}
+		out = append(out, t)
+	}
+
+	// Scattered steps have an array of outputs, nested
+	// for each input in a nested_crossproduct.
+	levels := 0
+	if len(step.Scatter) > 0 {
+		levels = 1
+		if step.ScatterMethod == cwl.NestedCrossProduct {
+			levels = len(step.Scatter)
+		}
+	}
+	for i := 0; i < levels; i++ {
+		out = []cwl.OutputType{cwl.OutputArray{Items: out}}
+	}
+	return out, nil
+}
+
+// processOutputs returns the IDs of the outputs of a process,
+// and their types by ID.
+func processOutputs(doc cwl.Document) ([]string, map[string][]cwl.OutputType) {
+	var ids []string
+	types := map[string][]cwl.OutputType{}
+	add := func(id string, t []cwl.OutputType) {
+		id = cwl.LocalID(id)
+		ids = append(ids, id)
+		types[id] = t
+	}
+
+	switch z := doc.(type) {
+	case *cwl.Tool:
+		for _, out := range z.Outputs {
+			add(out.ID, out.Type)
+		}
+	case *cwl.ExpressionTool:
+		for _, out := range z.Outputs {
+			add(out.ID, out.Type)
+		}
+	case *cwl.Workflow:
+		for _, out := range z.Outputs {
+			add(out.ID, out.Type)
+		}
+	}
+	return ids, types
+}
+
+// addFeatures adds the requirements of the features used by a workflow,
+// unless they're already requirements or hints.
+func addFeatures(wf *cwl.Workflow) {
+	var scatter, multiple, subworkflow, valueFrom bool
+	for _, s := range wf.Steps {
+		scatter = scatter || len(s.Scatter) > 0
+		_, ok := s.Run.(*cwl.Workflow)
+		subworkflow = subworkflow || ok
+		for _, in := range s.In {
+			multiple = multiple || len(in.Source) > 1
+			valueFrom = valueFrom || in.ValueFrom != ""
+		}
+	}
+	for _, out := range wf.Outputs {
+		multiple = multiple || len(out.OutputSource) > 1
+	}
+
+	add := func(used bool, req cwl.Requirement) {
+		if used && !hasRequirement(wf.Requirements, req) && !hasRequirement(wf.Hints, req) {
+			wf.Requirements = append(wf.Requirements, req)
+		}
+	}
+	add(scatter, cwl.ScatterFeatureRequirement{})
+	add(multiple, cwl.MultipleInputFeatureRequirement{})
+	add(subworkflow, cwl.SubworkflowFeatureRequirement{})
+	add(valueFrom, cwl.StepInputExpressionRequirement{})
+}
+
+func hasRequirement(reqs []cwl.Requirement, req cwl.Requirement) bool {
+	for _, r := range reqs {
+		if reflect.TypeOf(r) == reflect.TypeOf(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSources records a problem if a source isn't a workflow input ID,
+// e.g. "reads", or a step output, e.g. "align/bam".
+func checkSources(p *problems, what string, sources []string) {
+	if len(sources) == 0 {
+		p.add("%s: missing source", what)
+	}
+	for _, src := range sources {
+		parts := strings.Split(src, "/")
+		for _, part := range parts {
+			if len(parts) > 2 || checkID(part) != nil {
+				p.add("%s: invalid source %q", what, src)
+				break
+			}
+		}
+	}
+}
+
+// WorkflowInput builds an input of a workflow. See Workflow.Input.
+type WorkflowInput struct {
+	in       cwl.WorkflowInput
+	typed    bool
+	problems *problems
+}
+
+// Type sets the type of the input.
+func (in *WorkflowInput) Type(t Type) *WorkflowInput {
+	types, err := t.inputTypes()
+	if err != nil {
+		in.problems.add("input %q: %s", in.in.ID, err)
+	}
+	in.in.Type = types
+	in.typed = true
+	return in
+}
+
+func (in *WorkflowInput) Label(label string) *WorkflowInput {
+	in.in.Label = label
+	return in
+}
+
+func (in *WorkflowInput) Doc(doc string) *WorkflowInput {
+	in.in.Doc = doc
+	return in
+}
+
+// Default sets the default value of the input, e.g. 1, "fast",
+// or a cwl.File.
+func (in *WorkflowInput) Default(v interface{}) *WorkflowInput {
+	d, err := loadValue(v)
+	if err != nil {
+		in.problems.add("input %q: invalid default: %s", in.in.ID, err)
+	}
+	in.in.Default = d
+	return in
+}
+
+// SecondaryFiles sets the patterns of the input's secondary files,
+// e.g. ".bai" or "^.bai".
+func (in *WorkflowInput) SecondaryFiles(patterns ...string) *WorkflowInput {
+	in.in.SecondaryFiles = secondaryFiles(patterns)
+	return in
+}
+
+// WorkflowOutput builds an output of a workflow. See Workflow.Output.
+type WorkflowOutput struct {
+	out      cwl.WorkflowOutput
+	typed    bool
+	problems *problems
+}
+
+// Type sets the type of the output, instead of the type of its source.
+func (out *WorkflowOutput) Type(t Type) *WorkflowOutput {
+	types, err := t.outputTypes()
+	if err != nil {
+		out.problems.add("output %q: %s", out.out.ID, err)
+	}
+	out.out.Type = types
+	out.typed = true
+	return out
+}
+
+func (out *WorkflowOutput) Label(label string) *WorkflowOutput {
+	out.out.Label = label
+	return out
+}
+
+func (out *WorkflowOutput) Doc(doc string) *WorkflowOutput {
+	out.out.Doc = doc
+	return out
+}
+
+// LinkMerge sets how the values of multiple sources are merged.
+func (out *WorkflowOutput) LinkMerge(m cwl.LinkMergeMethod) *WorkflowOutput {
+	out.out.LinkMerge = m
+	return out
+}
+
+// Step builds a step of a workflow. See Workflow.Step.
+type Step struct {
+	step     cwl.Step
+	run      Process
+	out      []string
+	problems *problems
+}
+
+func (s *Step) Label(label string) *Step {
+	s.step.Label = label
+	return s
+}
+
+func (s *Step) Doc(doc string) *Step {
+	s.step.Doc = doc
+	return s
+}
+
+// In connects an input of the step to "sources", which are workflow
+// inputs, e.g. "reads", or outputs of other steps, e.g. "align/bam".
+func (s *Step) In(id string, sources ...string) *Step {
+	checkSources(s.problems, fmt.Sprintf("step %q: input %q", s.step.ID, id), sources)
+	s.input(id).Source = sources
+	return s
+}
+
+// Default sets the value of an input of the step,
+// which is used if it has no source, or its source is null.
+func (s *Step) Default(id string, v interface{}) *Step {
+	d, err := loadValue(v)
+	if err != nil {
+		s.problems.add("step %q: input %q: invalid default: %s", s.step.ID, id, err)
+	}
+	s.input(id).Default = d
+	return s
+}
+
+// ValueFrom sets an expression which returns the value of an input
+// of the step, e.g. "$(self.basename)".
+func (s *Step) ValueFrom(id, expr string) *Step {
+	s.input(id).ValueFrom = cwl.Expression(expr)
+	return s
+}
+
+// LinkMerge sets how the values of the sources of an input are merged.
+func (s *Step) LinkMerge(id string, m cwl.LinkMergeMethod) *Step {
+	s.input(id).LinkMerge = m
+	return s
+}
+
+// Out sets the outputs of the step, which are used by other steps
+// or workflow outputs.
+func (s *Step) Out(ids ...string) *Step {
+	s.out = ids
+	return s
+}
+
+// Scatter runs the step once for each item of the given inputs,
+// which are arrays.
+func (s *Step) Scatter(ids ...string) *Step {
+	s.step.Scatter = ids
+	return s
+}
+
+// ScatterMethod sets how multiple scattered inputs are combined.
+func (s *Step) ScatterMethod(m cwl.ScatterMethod) *Step {
+	s.step.ScatterMethod = m
+	return s
+}
+
+// input returns the input of the step with the given ID,
+// adding it if needed.
+func (s *Step) input(id string) *cwl.StepInput {
+	for i := range s.step.In {
+		if s.step.In[i].ID == id {
+			return &s.step.In[i]
+		}
+	}
+	if err := checkID(id); err != nil {
+		s.problems.add("step %q: input: %s", s.step.ID, err)
+	}
+	s.step.In = append(s.step.In, cwl.StepInput{ID: id})
+	return &s.step.In[len(s.step.In)-1]
+}
+
+func (s *Step) document() (cwl.Step, []string) {
+	step := s.step
+	step.In = append([]cwl.StepInput(nil), s.step.In...)
+
+	run, probs := s.run.document()
+	step.Run = run
+
+	ids := s.out
+	if ids == nil {
+		ids, _ = processOutputs(run)
+	}
+	step.Out = nil
+	for _, id := range ids {
+		step.Out = append(step.Out, cwl.StepOutput{ID: id})
+	}
+	return step, probs
+}
@@ -0,0 +1,160 @@
+package cwl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// CachingResolver resolves http and https URLs like HTTPResolver, but keeps
+// the documents in a cache directory, keyed by URL, so that loading remote
+// documents again works offline.
+//
+// Cached documents are revalidated with the ETag and Last-Modified headers
+// of the response they were cached from, so unchanged documents aren't
+// downloaded again. If the server can't be reached, or responds with
+// a server error, the cached document is used.
+//
+// Other locations, e.g. file paths, are resolved by FileResolver.
+type CachingResolver struct {
+	// Dir is the cache directory, which is created if needed.
+	Dir string
+	// HTTP configures the requests, e.g. the client and authorization.
+	HTTP HTTPResolver
+}
+
+// cacheEntry describes a cached document.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (c CachingResolver) Resolve(base, loc string) ([]byte, string, error) {
+	u, ok := isHTTP(base, loc)
+	if !ok || (u.Scheme != "http" && u.Scheme != "https") {
+		return FileResolver{}.Resolve(base, loc)
+	}
+	if c.Dir == "" {
+		return nil, "", errf("missing cache directory")
+	}
+	b, err := c.fetch(u)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, u.String(), nil
+}
+
+func (c CachingResolver) fetch(u *url.URL) ([]byte, error) {
+	key := cacheKey(u.String())
+	entry, cached := c.read(key)
+
+	hdr := http.Header{}
+	if cached != nil {
+		if entry.ETag != "" {
+			hdr.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			hdr.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.HTTP.get(u, hdr)
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, errf(`failed to resolve HTTP URL %s: %w`, u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case cached != nil && resp.StatusCode == http.StatusNotModified:
+		return cached, nil
+	case cached != nil && resp.StatusCode >= 500:
+		return cached, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, &HTTPError{URL: u.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := c.HTTP.read(u, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = cacheEntry{
+		URL:          u.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if err := c.write(key, entry, body); err != nil {
+		return nil, errf(`failed to cache %s: %s`, u.String(), err)
+	}
+	return body, nil
+}
+
+// read returns a cached document, or nil if it isn't cached.
+func (c CachingResolver) read(key string) (cacheEntry, []byte) {
+	var entry cacheEntry
+	meta, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".json"))
+	if err != nil {
+		return entry, nil
+	}
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return entry, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(c.Dir, key))
+	if err != nil {
+		return entry, nil
+	}
+	return entry, b
+}
+
+// write caches a document and its entry. The entry is written last,
+// so that a document is only read once it's completely written.
+func (c CachingResolver) write(key string, entry cacheEntry, b []byte) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := c.writeFile(key, b); err != nil {
+		return err
+	}
+	return c.writeFile(key+".json", meta)
+}
+
+// writeFile writes a file in the cache directory by renaming a temporary
+// file, so that concurrent readers never see a partially written file.
+func (c CachingResolver) writeFile(name string, b []byte) error {
+	f, err := ioutil.TempFile(c.Dir, name+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.Dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func cacheKey(u string) string {
+	h := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(h[:])
+}
@@ -22,6 +22,22 @@ func (l *loader) ScalarToCommandInput(n node) (CommandInput, error) {
 	return o, err
 }
 
+// SeqToCommandOutput loads an output given as a list of types,
+// e.g. "out: [File, 'null']".
+func (l *loader) SeqToCommandOutput(n node) (CommandOutput, error) {
+	o := CommandOutput{}
+	err := l.load(n, &o.Type)
+	return o, err
+}
+
+// SeqToCommandInput loads an input given as a list of types,
+// e.g. "reads: [File, 'null']".
+func (l *loader) SeqToCommandInput(n node) (CommandInput, error) {
+	o := CommandInput{}
+	err := l.load(n, &o.Type)
+	return o, err
+}
+
 func (l *loader) ScalarToCommandLineBinding(n node) (CommandLineBinding, error) {
 	return CommandLineBinding{
 		ValueFrom: Expression(n.Value),
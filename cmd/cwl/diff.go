@@ -0,0 +1,101 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "github.com/buchanae/cwl"
+  "github.com/spf13/cobra"
+)
+
+type diffOpts struct {
+  format string
+}
+
+func init() {
+  opts := diffOpts{format: "text"}
+
+  cmd := &cobra.Command{
+    Use: "diff <old.cwl> <new.cwl>",
+    Short: "Compare two versions of a document, field by field",
+    Long: `Compare two versions of a document, field by field.
+
+Documents are compared after they're loaded, so formatting, shortcuts and
+the order of inputs, outputs and requirements don't matter. Each change is
+classified as breaking, if callers of the process may need to change, e.g.
+because a required input was added, or non-breaking.
+
+The command exits with a non-zero status if any changes are breaking.`,
+    Args: cobra.ExactArgs(2),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return diffDocs(opts, args[0], args[1])
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringVarP(&opts.format, "format", "f", opts.format, "output format: text or json")
+}
+
+func diffDocs(opts diffOpts, oldPath, newPath string) error {
+  switch opts.format {
+  case "text", "json":
+  default:
+    return errf("unknown format %q", opts.format)
+  }
+
+  old, err := cwl.Load(oldPath)
+  if err != nil {
+    return err
+  }
+  new, err := cwl.Load(newPath)
+  if err != nil {
+    return err
+  }
+
+  changes := cwl.Diff(old, new)
+
+  var breaking int
+  for _, c := range changes {
+    if c.Breaking {
+      breaking++
+    }
+  }
+
+  if opts.format == "json" {
+    if err := writeJSONChanges(changes); err != nil {
+      return err
+    }
+  } else {
+    for _, c := range changes {
+      if c.Breaking {
+        fmt.Println("breaking:", c)
+      } else {
+        fmt.Println("non-breaking:", c)
+      }
+    }
+    fmt.Fprintf(os.Stderr, "%d changes, %d breaking\n", len(changes), breaking)
+  }
+
+  if breaking > 0 {
+    return errf("found %d breaking changes", breaking)
+  }
+  return nil
+}
+
+type jsonChange struct {
+  Path string `json:"path"`
+  Breaking bool `json:"breaking"`
+  Message string `json:"message"`
+}
+
+func writeJSONChanges(changes []cwl.Change) error {
+  out := []jsonChange{}
+  for _, c := range changes {
+    out = append(out, jsonChange{
+      Path: c.Path,
+      Breaking: c.Breaking,
+      Message: c.Message,
+    })
+  }
+  return printJSON(out)
+}
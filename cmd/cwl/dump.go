@@ -3,7 +3,6 @@ package main
 import (
   "fmt"
   "encoding/json"
-  "github.com/go-yaml/yaml"
   "github.com/buchanae/cwl"
   "github.com/spf13/cobra"
 )
@@ -11,6 +10,7 @@ import (
 type dumpOpts struct {
   resolveSchemaDefs bool
   noResolve bool
+  strict bool
   json bool
 }
 
@@ -27,31 +27,34 @@ func init() {
   root.AddCommand(cmd)
 
   f := cmd.Flags()
-  f.BoolVar(&opts.resolveSchemaDefs, "resolve-schema-defs", opts.resolveSchemaDefs, "")
+  f.BoolVar(&opts.resolveSchemaDefs, "resolve-schema-defs", opts.resolveSchemaDefs,
+    "replace references to SchemaDefRequirement types with the types")
   f.BoolVar(&opts.noResolve, "no-resolve", opts.noResolve, "")
+  f.BoolVar(&opts.strict, "strict", opts.strict, "fail on unknown fields")
   f.BoolVar(&opts.json, "json", opts.json, "")
 }
 
 func dump(opts dumpOpts, path string) error {
   var doc cwl.Document
   var err error
+  var loadOpts []cwl.LoadOption
+
+  if opts.strict {
+    loadOpts = append(loadOpts, cwl.Strict())
+  }
 
   if opts.noResolve {
-    doc, err = cwl.LoadWithResolver(path, cwl.NoResolve())
+    doc, err = cwl.LoadWithResolver(path, cwl.NoResolve(), loadOpts...)
   } else {
-    doc, err = cwl.Load(path)
+    doc, err = cwl.Load(path, loadOpts...)
   }
   if err != nil {
     return err
   }
 
-  // TODO also resolve http/file references for schema types?
   if opts.resolveSchemaDefs {
-    if tool, ok := doc.(*cwl.Tool); ok {
-      err := tool.ResolveSchemaDefs()
-      if err != nil {
-        return err
-      }
+    if err := cwl.ResolveSchemaDefs(doc); err != nil {
+      return err
     }
   }
 
@@ -59,7 +62,7 @@ func dump(opts dumpOpts, path string) error {
   if opts.json {
     b, err = json.MarshalIndent(doc, "", "  ")
   } else {
-    b, err = yaml.Marshal(doc)
+    b, err = cwl.MarshalYAML(doc)
   }
   if err != nil {
     return err
@@ -0,0 +1,124 @@
+package main
+
+import (
+  "fmt"
+  "go/token"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "unicode"
+  "github.com/buchanae/cwl"
+  "github.com/buchanae/cwl/gengo"
+  "github.com/spf13/cobra"
+)
+
+type genGoOpts struct {
+  pkg string
+  out string
+}
+
+func init() {
+  opts := genGoOpts{}
+
+  cmd := &cobra.Command{
+    Use: "gen-go <tool.cwl>",
+    Short: "Generate Go types for the inputs and outputs of a process",
+    Long: `Generate Go types for the inputs and outputs of a process.
+
+The generated code declares an Inputs struct and an Outputs struct, with
+types for the enums and records they use, and functions which convert
+them to and from cwl.Values, e.g. InputsFromValues.
+
+The package is named after the document, e.g. "bwamem" for bwa-mem.cwl,
+unless --package is given. For a $graph document, the "#main" process,
+or the only process, is used, unless another one is selected,
+e.g. "packed.cwl#align".`,
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return genGo(opts, args[0])
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringVarP(&opts.pkg, "package", "p", opts.pkg, "name of the generated package")
+  f.StringVarP(&opts.out, "out", "o", opts.out, "write the code to a file, instead of printing it")
+}
+
+func genGo(opts genGoOpts, path string) error {
+  doc, err := cwl.Load(path)
+  if err != nil {
+    return err
+  }
+  if g, ok := doc.(cwl.Graph); ok {
+    doc, err = graphProcess(g, path)
+    if err != nil {
+      return err
+    }
+  }
+
+  pkg := opts.pkg
+  if pkg == "" {
+    pkg = packageName(path)
+  }
+
+  b, err := gengo.Generate(doc, gengo.Options{
+    Package: pkg,
+    Source: filepath.Base(path),
+  })
+  if err != nil {
+    return err
+  }
+
+  if opts.out != "" {
+    return ioutil.WriteFile(opts.out, b, 0644)
+  }
+  fmt.Print(string(b))
+  return nil
+}
+
+// graphProcess returns the "#main" process of a $graph document,
+// or its only process.
+func graphProcess(g cwl.Graph, path string) (cwl.Document, error) {
+  if len(g.Docs) == 1 {
+    return g.Docs[0], nil
+  }
+  for _, d := range g.Docs {
+    var id string
+    switch z := d.(type) {
+    case *cwl.Tool:
+      id = z.LocalID
+    case *cwl.ExpressionTool:
+      id = z.LocalID
+    case *cwl.Workflow:
+      id = z.LocalID
+    }
+    if id == "main" {
+      return d, nil
+    }
+  }
+  return nil, errf(`%s has no "#main" process, select one with %s#<id>`, path, path)
+}
+
+// packageName returns a Go package name for a document,
+// e.g. "bwamem" for "tools/bwa-mem.cwl", or "align" for "packed.cwl#align".
+func packageName(path string) string {
+  base := filepath.Base(path)
+  if i := strings.Index(base, "#"); i != -1 {
+    base = base[i+1:]
+  } else {
+    base = strings.TrimSuffix(base, filepath.Ext(base))
+  }
+
+  var b strings.Builder
+  for _, r := range strings.ToLower(base) {
+    if unicode.IsLetter(r) || unicode.IsDigit(r) {
+      b.WriteRune(r)
+    }
+  }
+  name := b.String()
+  if !token.IsIdentifier(name) || token.IsKeyword(name) {
+    return "tool"
+  }
+  return name
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "github.com/buchanae/cwl"
+  "github.com/buchanae/cwl/process"
+  "github.com/spf13/cobra"
+)
+
+type graphOpts struct {
+  format string
+  expand bool
+  out string
+}
+
+func init() {
+  opts := graphOpts{format: "dot"}
+
+  cmd := &cobra.Command{
+    Use: "graph <workflow.cwl>",
+    Short: "Draw the steps of a workflow as a Graphviz DOT or Mermaid diagram",
+    Long: `Draw the steps of a workflow as a Graphviz DOT or Mermaid diagram.
+
+The inputs, steps and outputs of the workflow are connected by their sources.
+With --expand, the steps which run subworkflows are replaced by the steps
+of the subworkflows.
+
+For a $graph document, the "#main" workflow, or the only workflow, is drawn,
+unless another one is selected, e.g. "packed.cwl#align".
+
+DOT output may be rendered with Graphviz, e.g.
+  cwl graph wf.cwl | dot -Tsvg > wf.svg
+Mermaid output may be embedded in Markdown documents.`,
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return graphDoc(opts, args[0])
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringVarP(&opts.format, "format", "f", opts.format, "output format: dot or mermaid")
+  f.BoolVar(&opts.expand, "expand", opts.expand, "draw the steps of subworkflows")
+  f.StringVarP(&opts.out, "out", "o", opts.out, "write the diagram to a file, instead of printing it")
+}
+
+func graphDoc(opts graphOpts, path string) error {
+  switch opts.format {
+  case "dot", "mermaid":
+  default:
+    return errf("unknown format %q", opts.format)
+  }
+
+  doc, err := cwl.Load(path)
+  if err != nil {
+    return err
+  }
+  if g, ok := doc.(cwl.Graph); ok {
+    doc, err = graphWorkflow(g, path)
+    if err != nil {
+      return err
+    }
+  }
+
+  wf, ok := doc.(*cwl.Workflow)
+  if !ok {
+    return errf("%s is a %s, not a Workflow", path, doc.Doctype())
+  }
+
+  g, err := process.NewGraph(wf)
+  if err != nil {
+    return err
+  }
+  if opts.expand {
+    g, err = g.Expand()
+    if err != nil {
+      return err
+    }
+  }
+  if cycles := g.Cycles(); len(cycles) > 0 {
+    fmt.Fprintln(os.Stderr, "warning:", &process.CycleError{Cycles: cycles})
+  }
+
+  var w io.Writer = os.Stdout
+  if opts.out != "" {
+    file, err := os.Create(opts.out)
+    if err != nil {
+      return err
+    }
+    defer file.Close()
+    w = file
+  }
+
+  if opts.format == "mermaid" {
+    return g.WriteMermaid(w)
+  }
+  return g.WriteDOT(w)
+}
+
+// graphWorkflow returns the "#main" workflow of a $graph document,
+// or its only workflow.
+func graphWorkflow(g cwl.Graph, path string) (cwl.Document, error) {
+  var wfs []*cwl.Workflow
+  for _, d := range g.Docs {
+    if wf, ok := d.(*cwl.Workflow); ok {
+      if wf.LocalID == "main" {
+        return wf, nil
+      }
+      wfs = append(wfs, wf)
+    }
+  }
+  if len(wfs) == 1 {
+    return wfs[0], nil
+  }
+  return nil, errf(`%s has no "#main" workflow, select one with %s#<id>`, path, path)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+  "fmt"
+  "path/filepath"
+  "strings"
+  "github.com/buchanae/cwl"
+  "github.com/spf13/cobra"
+)
+
+type hashOpts struct {
+  jobs []string
+}
+
+func init() {
+  opts := hashOpts{}
+
+  cmd := &cobra.Command{
+    Use: "hash <doc.cwl> ...",
+    Short: "Print a fingerprint of documents and job orders",
+    Long: `Print a fingerprint of documents and job orders, one per line,
+followed by the path.
+
+A document's hash changes only when the document changes in a way that
+matters to running it, so formatting, key order, "doc" and "label" fields,
+and the form of inputs and outputs (e.g. a map or a list) don't change it.
+
+A job order's hash uses the checksums of its files instead of their
+locations. Files without a checksum are read, relative to the job order.
+
+Arguments may be glob patterns, e.g. "tools/*.cwl", as in "cwl validate".`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+      if len(args) == 0 && len(opts.jobs) == 0 {
+        return errf("expected a document or --job")
+      }
+      return hash(opts, args)
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringSliceVar(&opts.jobs, "job", opts.jobs, "hash a job order (inputs) file; may be repeated")
+}
+
+func hash(opts hashOpts, patterns []string) error {
+  var paths []string
+  if len(patterns) > 0 {
+    var err error
+    paths, err = expandGlobs(patterns)
+    if err != nil {
+      return err
+    }
+  }
+
+  for _, path := range paths {
+    doc, err := cwl.Load(path)
+    if err != nil {
+      return err
+    }
+    h, err := cwl.Hash(doc)
+    if err != nil {
+      return errf("%s: %s", path, err)
+    }
+    fmt.Printf("%s  %s\n", h, path)
+  }
+
+  for _, path := range opts.jobs {
+    vals, err := cwl.LoadValuesFile(path)
+    if err != nil {
+      return err
+    }
+    dir := filepath.Dir(path)
+    for k, v := range vals {
+      vals[k] = resolveJobPaths(v, dir)
+    }
+    h, err := cwl.HashValues(vals)
+    if err != nil {
+      return errf("%s: %s", path, err)
+    }
+    fmt.Printf("%s  %s\n", h, path)
+  }
+  return nil
+}
+
+// resolveJobPaths makes the relative paths and locations of files
+// and directories in a job order relative to "dir".
+func resolveJobPaths(v cwl.Value, dir string) cwl.Value {
+  switch z := v.(type) {
+  case cwl.File:
+    z.Path = resolveJobPath(z.Path, dir)
+    z.Location = resolveJobPath(z.Location, dir)
+    for i, s := range z.SecondaryFiles {
+      z.SecondaryFiles[i] = resolveJobPaths(s, dir).(cwl.FileDir)
+    }
+    return z
+  case cwl.Directory:
+    z.Path = resolveJobPath(z.Path, dir)
+    z.Location = resolveJobPath(z.Location, dir)
+    for i, l := range z.Listing {
+      z.Listing[i] = resolveJobPaths(l, dir).(cwl.FileDir)
+    }
+    return z
+  case map[string]cwl.Value:
+    for k, x := range z {
+      z[k] = resolveJobPaths(x, dir)
+    }
+  case []cwl.Value:
+    for i, x := range z {
+      z[i] = resolveJobPaths(x, dir)
+    }
+  }
+  return v
+}
+
+func resolveJobPath(p, dir string) string {
+  if p == "" || filepath.IsAbs(p) || strings.Contains(p, "://") {
+    return p
+  }
+  return filepath.Join(dir, p)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+  "fmt"
+  "github.com/buchanae/cwl"
+  "github.com/buchanae/cwl/lint"
+  "github.com/spf13/cobra"
+)
+
+type lintOpts struct {
+  config string
+  format string
+  rules bool
+}
+
+func init() {
+  opts := lintOpts{format: "text"}
+
+  cmd := &cobra.Command{
+    Use: "lint <doc.cwl> ...",
+    Short: "Check documents against best practices",
+    Long: `Check documents against best practices, such as documenting inputs
+and pinning Docker images. See "cwl lint --rules" for the rules.
+
+Rules are configured by a ` + lint.ConfigFile + ` file, found in the working
+directory or its parents, up to the root of the repository:
+
+  rules:
+    input-doc: off
+    docker-tag: error
+
+Arguments may be glob patterns, as with "cwl validate". The command exits
+with a non-zero status if any findings are errors.`,
+    RunE: func(cmd *cobra.Command, args []string) error {
+      if opts.rules {
+        listRules()
+        return nil
+      }
+      if len(args) == 0 {
+        return errf("missing documents")
+      }
+      return lintDocs(opts, args)
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringVarP(&opts.config, "config", "c", opts.config, "config file, instead of finding "+lint.ConfigFile)
+  f.StringVarP(&opts.format, "format", "f", opts.format, "output format: text, json or sarif")
+  f.BoolVar(&opts.rules, "rules", opts.rules, "list the rules and exit")
+}
+
+func listRules() {
+  for _, r := range lint.Rules {
+    fmt.Printf("%-14s %s\n", r.Name, r.Description)
+  }
+}
+
+func lintDocs(opts lintOpts, patterns []string) error {
+  switch opts.format {
+  case "text", "json", "sarif":
+  default:
+    return errf("unknown format %q", opts.format)
+  }
+
+  path := opts.config
+  if path == "" {
+    var err error
+    path, err = lint.FindConfig(".")
+    if err != nil {
+      return err
+    }
+  }
+  var conf lint.Config
+  if path != "" {
+    var err error
+    conf, err = lint.LoadConfig(path)
+    if err != nil {
+      return err
+    }
+  }
+
+  paths, err := expandGlobs(patterns)
+  if err != nil {
+    return err
+  }
+
+  var diags []cwl.Diagnostic
+  for _, p := range paths {
+    doc, err := cwl.Load(p)
+    if err != nil {
+      diags = append(diags, loadDiagnostic(p, err))
+      continue
+    }
+    for _, d := range lint.Lint(doc, conf) {
+      if d.Pos.File == "" {
+        d.Pos.File = p
+      }
+      diags = append(diags, d)
+    }
+  }
+
+  return writeDiagnostics(opts.format, diags, len(paths))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "github.com/buchanae/cwl"
+  "github.com/spf13/cobra"
+)
+
+type packOpts struct {
+  out string
+  json bool
+}
+
+type unpackOpts struct {
+  dir string
+  force bool
+}
+
+func init() {
+  popts := packOpts{}
+
+  pack := &cobra.Command{
+    Use: "pack <doc.cwl>",
+    Short: "Pack a workflow and the documents it references into a single $graph document",
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return packDoc(popts, args[0])
+    },
+  }
+  root.AddCommand(pack)
+
+  f := pack.Flags()
+  f.StringVarP(&popts.out, "out", "o", popts.out, "write the packed document to a file, instead of printing it")
+  f.BoolVar(&popts.json, "json", popts.json, "write JSON instead of YAML")
+
+  uopts := unpackOpts{dir: "."}
+
+  unpack := &cobra.Command{
+    Use: "unpack <packed.cwl>",
+    Short: "Split a $graph document into one document per process",
+    Args: cobra.ExactArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return unpackDoc(uopts, args[0])
+    },
+  }
+  root.AddCommand(unpack)
+
+  f = unpack.Flags()
+  f.StringVarP(&uopts.dir, "dir", "d", uopts.dir, "directory to write the documents to")
+  f.BoolVarP(&uopts.force, "force", "f", uopts.force, "overwrite existing files")
+}
+
+func packDoc(opts packOpts, path string) error {
+  doc, err := cwl.Load(path)
+  if err != nil {
+    return err
+  }
+
+  g, err := cwl.Pack(doc)
+  if err != nil {
+    return err
+  }
+
+  var b []byte
+  if opts.json {
+    b, err = json.MarshalIndent(g, "", "  ")
+  } else {
+    b, err = cwl.MarshalYAML(g)
+  }
+  if err != nil {
+    return err
+  }
+
+  if opts.out != "" {
+    return ioutil.WriteFile(opts.out, b, 0644)
+  }
+  fmt.Println(string(b))
+  return nil
+}
+
+func unpackDoc(opts unpackOpts, path string) error {
+  doc, err := cwl.Load(path)
+  if err != nil {
+    return err
+  }
+
+  docs, err := cwl.Unpack(doc)
+  if err != nil {
+    return err
+  }
+
+  var names []string
+  for name := range docs {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  // Check every file first, so that nothing is written if one exists.
+  if !opts.force {
+    for _, name := range names {
+      p := filepath.Join(opts.dir, name)
+      if _, err := os.Stat(p); err == nil {
+        return errf("%s already exists, use --force to overwrite it", p)
+      }
+    }
+  }
+
+  if err := os.MkdirAll(opts.dir, 0755); err != nil {
+    return err
+  }
+
+  for _, name := range names {
+    b, err := cwl.MarshalYAML(docs[name])
+    if err != nil {
+      return errf("marshaling %s: %s", name, err)
+    }
+    p := filepath.Join(opts.dir, name)
+    if err := ioutil.WriteFile(p, b, 0644); err != nil {
+      return err
+    }
+    fmt.Println(p)
+  }
+  return nil
+}
@@ -77,14 +77,20 @@ func (r *runner) runDoc(doc cwl.Document, vals cwl.Values) (cwl.Values, error) {
     return r.runTool(z, vals)
   case *cwl.Workflow:
     return r.runWorkflow(z, vals)
+  case *cwl.ExpressionTool:
+    return process.RunExpressionTool(z, vals)
   default:
     return nil, fmt.Errorf(`running doc: unknown doc type "%s"`, doc.Doctype())
   }
 }
 
 func (r *runner) runWorkflow(wf *cwl.Workflow, vals cwl.Values) (cwl.Values, error) {
-  process.DebugWorkflow(wf, vals)
-  return nil, nil
+  if r.debug {
+    if err := process.DebugWorkflow(wf); err != nil {
+      return nil, err
+    }
+  }
+  return process.RunWorkflow(wf, vals, r.runDoc)
 }
 
 func (r *runner) runTool(tool *cwl.Tool, vals cwl.Values) (cwl.Values, error) {
@@ -0,0 +1,70 @@
+package main
+
+import (
+  "fmt"
+  "io/ioutil"
+  "github.com/buchanae/cwl"
+  "github.com/spf13/cobra"
+)
+
+type upgradeOpts struct {
+  resolve bool
+  inPlace bool
+}
+
+func init() {
+  opts := upgradeOpts{}
+
+  cmd := &cobra.Command{
+    Use: "upgrade <doc.cwl> ...",
+    Short: "Upgrade documents to CWL " + cwl.LatestVersion,
+    Args: cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      for _, path := range args {
+        if err := upgrade(opts, path); err != nil {
+          return fmt.Errorf("upgrading %s: %s", path, err)
+        }
+      }
+      return nil
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.BoolVar(&opts.resolve, "resolve", opts.resolve,
+    "embed the documents referenced by steps, instead of keeping the references")
+  f.BoolVarP(&opts.inPlace, "in-place", "i", opts.inPlace,
+    "overwrite the documents instead of printing them")
+}
+
+func upgrade(opts upgradeOpts, path string) error {
+  var doc cwl.Document
+  var err error
+
+  // By default, don't resolve "run: other.cwl", so that the upgraded
+  // document keeps referencing other documents, which can be upgraded separately.
+  if opts.resolve {
+    doc, err = cwl.Load(path)
+  } else {
+    doc, err = cwl.LoadWithResolver(path, cwl.NoResolve())
+  }
+  if err != nil {
+    return err
+  }
+
+  doc, err = cwl.Upgrade(doc)
+  if err != nil {
+    return err
+  }
+
+  b, err := cwl.MarshalYAML(doc)
+  if err != nil {
+    return err
+  }
+
+  if opts.inPlace {
+    return ioutil.WriteFile(path, b, 0644)
+  }
+  fmt.Print(string(b))
+  return nil
+}
@@ -13,3 +13,11 @@ func errf(msg string, args ...interface{}) error {
 func debug(i ...interface{}) {
 	pretty.Println(i...)
 }
+
+// plural formats a count of "noun", e.g. "1 document" or "2 documents".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
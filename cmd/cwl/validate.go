@@ -0,0 +1,418 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "github.com/buchanae/cwl"
+  "github.com/buchanae/cwl/version"
+  "github.com/spf13/cobra"
+)
+
+type validateOpts struct {
+  format string
+  noStrict bool
+}
+
+func init() {
+  opts := validateOpts{format: "text"}
+
+  cmd := &cobra.Command{
+    Use: "validate <doc.cwl> ...",
+    Short: "Check documents for errors, without running them",
+    Long: `Check documents for errors, without running them.
+
+Arguments may be glob patterns, e.g. "tools/*.cwl", where "**" matches
+any number of directories, e.g. "**/*.cwl". Patterns are expanded by
+this command, so quote them to check more files than the shell allows.
+
+The command exits with a non-zero status if any errors are found.`,
+    Args: cobra.MinimumNArgs(1),
+    RunE: func(cmd *cobra.Command, args []string) error {
+      return validate(opts, args)
+    },
+  }
+  root.AddCommand(cmd)
+
+  f := cmd.Flags()
+  f.StringVarP(&opts.format, "format", "f", opts.format, "output format: text, json or sarif")
+  f.BoolVar(&opts.noStrict, "no-strict", opts.noStrict, "report unknown fields as warnings instead of errors")
+}
+
+func validate(opts validateOpts, patterns []string) error {
+  switch opts.format {
+  case "text", "json", "sarif":
+  default:
+    return errf("unknown format %q", opts.format)
+  }
+
+  paths, err := expandGlobs(patterns)
+  if err != nil {
+    return err
+  }
+
+  var diags []cwl.Diagnostic
+  for _, path := range paths {
+    diags = append(diags, validateDoc(opts, path)...)
+  }
+  return writeDiagnostics(opts.format, diags, len(paths))
+}
+
+// writeDiagnostics writes diagnostics in the given format, returning
+// an error if any of them are errors, so that the command fails.
+func writeDiagnostics(format string, diags []cwl.Diagnostic, docs int) error {
+  var err error
+  switch format {
+  case "json":
+    err = writeJSONDiagnostics(diags)
+  case "sarif":
+    err = writeSARIF(diags)
+  default:
+    writeTextDiagnostics(diags, docs)
+  }
+  if err != nil {
+    return err
+  }
+
+  var errs int
+  for _, d := range diags {
+    if d.Severity == cwl.SeverityError {
+      errs++
+    }
+  }
+  if errs > 0 {
+    return errf("found %s", plural(errs, "error"))
+  }
+  return nil
+}
+
+// validateDoc loads and validates the document at "path". Failures to
+// load the document, and unknown fields, are reported as diagnostics.
+func validateDoc(opts validateOpts, path string) []cwl.Diagnostic {
+  var unknown []cwl.UnknownField
+  doc, err := cwl.Load(path, cwl.WarnUnknownFields(&unknown))
+  if err != nil {
+    return []cwl.Diagnostic{loadDiagnostic(path, err)}
+  }
+
+  sev := cwl.SeverityError
+  if opts.noStrict {
+    sev = cwl.SeverityWarning
+  }
+
+  var diags []cwl.Diagnostic
+  for _, u := range unknown {
+    pos := u.Pos
+    // The position is part of the diagnostic, not the message.
+    u.Pos = cwl.Position{}
+    diags = append(diags, cwl.Diagnostic{
+      Severity: sev,
+      Rule: "unknown-field",
+      Message: u.String(),
+      Pos: pos,
+    })
+  }
+
+  for _, d := range cwl.Validate(doc) {
+    if d.Pos.File == "" {
+      d.Pos.File = path
+    }
+    diags = append(diags, d)
+  }
+  return diags
+}
+
+// loadDiagnostic describes a failure to load a document, at the position
+// of the innermost element which failed to load, when known.
+func loadDiagnostic(path string, err error) cwl.Diagnostic {
+  d := cwl.Diagnostic{
+    Severity: cwl.SeverityError,
+    Rule: "load",
+    Message: err.Error(),
+    Pos: cwl.Position{File: path},
+  }
+
+  var le *cwl.LoadError
+  for errors.As(err, &le) {
+    d.Pos = le.Pos
+    d.Message = le.Err.Error()
+    if le.Path != "" {
+      d.Message = le.Path + ": " + d.Message
+    }
+    err = le.Err
+  }
+  if d.Pos.File == "" {
+    d.Pos.File = path
+  }
+  return d
+}
+
+func writeTextDiagnostics(diags []cwl.Diagnostic, docs int) {
+  var errs, warnings int
+  for _, d := range diags {
+    fmt.Println(d)
+    if d.Severity == cwl.SeverityError {
+      errs++
+    } else {
+      warnings++
+    }
+  }
+  fmt.Fprintf(os.Stderr, "checked %s: %s, %s\n",
+    plural(docs, "document"), plural(errs, "error"), plural(warnings, "warning"))
+}
+
+type jsonDiagnostic struct {
+  File string `json:"file"`
+  Line int `json:"line,omitempty"`
+  Column int `json:"column,omitempty"`
+  Severity cwl.Severity `json:"severity"`
+  Rule string `json:"rule"`
+  Message string `json:"message"`
+}
+
+func writeJSONDiagnostics(diags []cwl.Diagnostic) error {
+  out := []jsonDiagnostic{}
+  for _, d := range diags {
+    out = append(out, jsonDiagnostic{
+      File: displayPath(d.Pos.File),
+      Line: d.Pos.Line,
+      Column: d.Pos.Column,
+      Severity: d.Severity,
+      Rule: d.Rule,
+      Message: d.Message,
+    })
+  }
+  return printJSON(out)
+}
+
+// The subset of SARIF 2.1.0 used to report diagnostics.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+  Schema string `json:"$schema"`
+  Version string `json:"version"`
+  Runs []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+  Tool struct {
+    Driver sarifDriver `json:"driver"`
+  } `json:"tool"`
+  Results []sarifResult `json:"results"`
+}
+
+type sarifDriver struct {
+  Name string `json:"name"`
+  Version string `json:"version,omitempty"`
+  InformationURI string `json:"informationUri"`
+  Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+  ID string `json:"id"`
+}
+
+type sarifResult struct {
+  RuleID string `json:"ruleId"`
+  Level string `json:"level"`
+  Message sarifMessage `json:"message"`
+  Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+  Text string `json:"text"`
+}
+
+type sarifLocation struct {
+  PhysicalLocation struct {
+    ArtifactLocation struct {
+      URI string `json:"uri"`
+    } `json:"artifactLocation"`
+    Region *sarifRegion `json:"region,omitempty"`
+  } `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+  StartLine int `json:"startLine"`
+  StartColumn int `json:"startColumn,omitempty"`
+}
+
+func writeSARIF(diags []cwl.Diagnostic) error {
+  run := sarifRun{Results: []sarifResult{}}
+  run.Tool.Driver = sarifDriver{
+    Name: "cwl",
+    InformationURI: "https://github.com/buchanae/cwl",
+    Rules: []sarifRule{},
+  }
+  if version.Version != "unknown" {
+    run.Tool.Driver.Version = version.Version
+  }
+
+  rules := map[string]bool{}
+  for _, d := range diags {
+    if !rules[d.Rule] {
+      rules[d.Rule] = true
+      run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Rule})
+    }
+
+    r := sarifResult{
+      RuleID: d.Rule,
+      Level: string(d.Severity),
+      Message: sarifMessage{Text: d.Message},
+    }
+    if d.Pos.File != "" {
+      var loc sarifLocation
+      loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(displayPath(d.Pos.File))
+      if d.Pos.IsValid() {
+        loc.PhysicalLocation.Region = &sarifRegion{
+          StartLine: d.Pos.Line,
+          StartColumn: d.Pos.Column,
+        }
+      }
+      r.Locations = append(r.Locations, loc)
+    }
+    run.Results = append(run.Results, r)
+  }
+  sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+    return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+  })
+
+  return printJSON(sarifLog{
+    Schema: "https://json.schemastore.org/sarif-2.1.0.json",
+    Version: "2.1.0",
+    Runs: []sarifRun{run},
+  })
+}
+
+func printJSON(v interface{}) error {
+  b, err := json.MarshalIndent(v, "", "  ")
+  if err != nil {
+    return err
+  }
+  fmt.Println(string(b))
+  return nil
+}
+
+// displayPath returns "path" relative to the working directory,
+// if it's inside it, so that reports don't depend on where they were made.
+func displayPath(path string) string {
+  path = strings.TrimPrefix(path, "file://")
+  if !filepath.IsAbs(path) {
+    return path
+  }
+  wd, err := os.Getwd()
+  if err != nil {
+    return path
+  }
+  rel, err := filepath.Rel(wd, path)
+  if err != nil || strings.HasPrefix(rel, "..") {
+    return path
+  }
+  return rel
+}
+
+// expandGlobs returns the files matching the given patterns, in order,
+// without duplicates. Patterns without glob characters are returned as is,
+// so that missing files are reported when they're loaded.
+func expandGlobs(patterns []string) ([]string, error) {
+  var paths []string
+  seen := map[string]bool{}
+  add := func(p string) {
+    if !seen[p] {
+      seen[p] = true
+      paths = append(paths, p)
+    }
+  }
+
+  for _, pattern := range patterns {
+    if !hasMeta(pattern) {
+      add(pattern)
+      continue
+    }
+    matches, err := glob(pattern)
+    if err != nil {
+      return nil, errf("invalid pattern %q: %s", pattern, err)
+    }
+    if len(matches) == 0 {
+      return nil, errf("no documents match %q", pattern)
+    }
+    for _, m := range matches {
+      add(m)
+    }
+  }
+  return paths, nil
+}
+
+func hasMeta(pattern string) bool {
+  return strings.ContainsAny(pattern, `*?[`)
+}
+
+// glob is like filepath.Glob, but "**" matches any number of directories.
+func glob(pattern string) ([]string, error) {
+  if !strings.Contains(pattern, "**") {
+    return filepath.Glob(pattern)
+  }
+
+  // Walk the directory before the first part of the pattern with glob
+  // characters, matching the rest of the pattern.
+  parts := strings.Split(filepath.ToSlash(pattern), "/")
+  i := 0
+  for i < len(parts) && !hasMeta(parts[i]) {
+    i++
+  }
+  dir := filepath.FromSlash(strings.Join(parts[:i], "/"))
+  if dir == "" {
+    dir = "."
+  }
+  if strings.HasPrefix(pattern, "/") && i == 1 {
+    dir = "/"
+  }
+
+  var matches []string
+  err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    if err != nil {
+      return err
+    }
+    if info.IsDir() {
+      return nil
+    }
+    rel, err := filepath.Rel(dir, path)
+    if err != nil {
+      return err
+    }
+    ok, err := matchParts(parts[i:], strings.Split(filepath.ToSlash(rel), "/"))
+    if ok {
+      matches = append(matches, path)
+    }
+    return err
+  })
+  return matches, err
+}
+
+// matchParts matches the parts of a path against the parts of a pattern,
+// where "**" matches zero or more parts.
+func matchParts(pattern, path []string) (bool, error) {
+  if len(pattern) == 0 {
+    return len(path) == 0, nil
+  }
+  if pattern[0] == "**" {
+    for i := 0; i <= len(path); i++ {
+      ok, err := matchParts(pattern[1:], path[i:])
+      if ok || err != nil {
+        return ok, err
+      }
+    }
+    return false, nil
+  }
+  if len(path) == 0 {
+    return false, nil
+  }
+  ok, err := filepath.Match(pattern[0], path[0])
+  if !ok || err != nil {
+    return false, err
+  }
+  return matchParts(pattern[1:], path[1:])
+}
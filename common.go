@@ -24,8 +24,29 @@ const (
 	MergeFlattened                 = "merge_flattened"
 )
 
+// PickValueMethod describes how to pick a value from multiple sources,
+// some of which may be null, e.g. the outputs of conditional steps.
+type PickValueMethod string
+
+const (
+	FirstNonNull   PickValueMethod = "first_non_null"
+	TheOnlyNonNull PickValueMethod = "the_only_non_null"
+	AllNonNull     PickValueMethod = "all_non_null"
+)
+
+// LoadListing describes how much of a Directory listing is loaded.
+type LoadListing string
+
+const (
+	NoListing      LoadListing = "no_listing"
+	ShallowListing LoadListing = "shallow_listing"
+	DeepListing    LoadListing = "deep_listing"
+)
+
 type DocumentRef struct {
 	Location string
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 func (d DocumentRef) MarshalText() ([]byte, error) {
@@ -34,6 +55,8 @@ func (d DocumentRef) MarshalText() ([]byte, error) {
 
 type TypeRef struct {
 	Name string
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 func (t TypeRef) String() string {
@@ -123,6 +146,8 @@ type Document interface {
 type Graph struct {
 	CWLVersion string `json:"cwlVersion,omitempty"`
   Docs []Document `json:"$graph"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 func (Tool) Doctype()       string    { return "CommandLineTool" }
@@ -218,6 +243,11 @@ func (SubworkflowFeatureRequirement) requirement()   {}
 func (ScatterFeatureRequirement) requirement()       {}
 func (MultipleInputFeatureRequirement) requirement() {}
 func (StepInputExpressionRequirement) requirement()  {}
+func (ToolTimeLimit) requirement()                   {}
+func (WorkReuse) requirement()                       {}
+func (NetworkAccess) requirement()                   {}
+func (InplaceUpdateRequirement) requirement()        {}
+func (LoadListingRequirement) requirement()          {}
 
 type WorkflowRequirement interface {
 	wfrequirement()
@@ -234,7 +264,7 @@ func (o *OptOut) Clear() {
 	o.set = false
 }
 
-func (o *OptOut) Value() bool {
+func (o OptOut) Value() bool {
 	if !o.set {
 		return true
 	}
@@ -246,7 +276,19 @@ func (o *OptOut) Set(v bool) {
 	o.v = v
 }
 
-func (o *OptOut) MarshalText() ([]byte, error) {
+// IsSet returns true if the flag was set explicitly.
+func (o OptOut) IsSet() bool {
+	return o.set
+}
+
+func (o OptOut) ptr() *bool {
+	if !o.set {
+		return nil
+	}
+	return &o.v
+}
+
+func (o OptOut) MarshalText() ([]byte, error) {
 	return []byte(fmt.Sprintf("%t", o.Value())), nil
 }
 
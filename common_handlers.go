@@ -14,10 +14,15 @@ func (l *loader) MappingToDocument(n node) (Document, error) {
       return nil, errf("$graph must be a list of objects")
     }
     graph := Graph{}
+    l.inGraph = true
     err := l.load(n, &graph)
+    l.inGraph = false
     if err != nil {
       return nil, err
     }
+    if err := l.linkGraph(graph); err != nil {
+      return nil, err
+    }
     return graph, nil
   }
 
@@ -51,20 +56,52 @@ func (l *loader) MappingToDocument(n node) (Document, error) {
 }
 
 func (l *loader) ScalarToDocument(n node) (Document, error) {
-	if _, ok := l.resolver.(noResolver); ok {
+	// References to other processes in a $graph, e.g. "#main",
+	// are linked after the whole graph is loaded. See linkGraph.
+	if strings.HasPrefix(n.Value, "#") {
+		if !l.inGraph {
+			return nil, fmt.Errorf("can't resolve %q outside of a $graph document", n.Value)
+		}
 		return DocumentRef{Location: n.Value}, nil
 	}
-	b, base, err := l.resolver.Resolve(l.base, n.Value)
+	if l.noResolve() {
+		return DocumentRef{Location: n.Value}, nil
+	}
+
+	// Resolve relative to the document containing the reference,
+	// which might have been imported from a different location.
+	base := l.originOf(n).base
+	loc, frag := splitFragment(n.Value)
+	b, newBase, err := l.resolver.Resolve(base, loc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve document: %s", err)
+		return nil, fmt.Errorf("failed to resolve document %q: %w", n.Value, err)
+	}
+	d, err := loadDocumentBytes(b, newBase, joinLocation(base, loc), l.resolver, l.opts)
+	if err != nil || frag == "" {
+		return d, err
 	}
-	return LoadDocumentBytes(b, base, l.resolver)
+	return selectFragment(d, frag)
 }
 
 func (l *loader) ScalarToExpressionSlice(n node) ([]Expression, error) {
 	return []Expression{Expression(n.Value)}, nil
 }
 
+func (l *loader) ScalarToSecondaryFileSchema(n node) (SecondaryFileSchema, error) {
+	return SecondaryFileSchema{Pattern: Expression(n.Value)}, nil
+}
+
+func (l *loader) ScalarToSecondaryFileSchemaSlice(n node) ([]SecondaryFileSchema, error) {
+	s, err := l.ScalarToSecondaryFileSchema(n)
+	return []SecondaryFileSchema{s}, err
+}
+
+func (l *loader) MappingToSecondaryFileSchemaSlice(n node) ([]SecondaryFileSchema, error) {
+	s := SecondaryFileSchema{}
+	err := l.load(n, &s)
+	return []SecondaryFileSchema{s}, err
+}
+
 func (l *loader) MappingToExpressionMap(n node) (map[string]Expression, error) {
 	out := map[string]Expression{}
 	for _, kv := range itermap(n) {
@@ -100,7 +137,7 @@ func (l *loader) SeqToExpressionMap(n node) (map[string]Expression, error) {
 }
 
 func (l *loader) SeqToStringSlice(n node) ([]string, error) {
-	strs := []string{}
+	var strs []string
 	for _, c := range n.Children {
 		strs = append(strs, c.Value)
 	}
@@ -337,7 +374,7 @@ func (l *loader) scalarToType(name string, isInput bool) cwltype {
 	default:
 		// TODO possibly only create TypeRef for types staring with "#" or otherwise
 		//      looking like IRI/URI format?
-		return TypeRef{name}
+		return TypeRef{Name: name}
 	}
 
 	return t
@@ -3,14 +3,22 @@ package cwl
 import (
 	"fmt"
 	"github.com/commondream/yamlast"
+	"io/fs"
 	"io/ioutil"
 )
 
-func Load(loc string) (Document, error) {
-	return LoadWithResolver(loc, DefaultResolver{})
+func Load(loc string, opts ...LoadOption) (Document, error) {
+	return LoadWithResolver(loc, DefaultResolver{}, opts...)
 }
 
-func LoadWithResolver(loc string, r Resolver) (Document, error) {
+// LoadFS loads a document from a file system, e.g. an embed.FS. References
+// to other documents, such as "run" and $import, are resolved in "fsys".
+// Full IDs are relative to the root of "fsys", e.g. "cwl/wf.cwl#main".
+func LoadFS(fsys fs.FS, name string, opts ...LoadOption) (Document, error) {
+	return LoadWithResolver(name, FSResolver{FS: fsys}, opts...)
+}
+
+func LoadWithResolver(loc string, r Resolver, opts ...LoadOption) (Document, error) {
 	if r == nil {
 		r = NoResolve()
 	}
@@ -19,48 +27,95 @@ func LoadWithResolver(loc string, r Resolver) (Document, error) {
 	var base string
 	var err error
 
+	// A fragment selects a process from a $graph document,
+	// e.g. "packed.cwl#main".
+	file, frag := splitFragment(loc)
+
 	// If NoResolve() is being used, load the document bytes using
 	// the default resolver, but then continue with NoResolve().
-	if _, ok := r.(noResolver); ok {
+	if isNoResolve(r) {
 		d := DefaultResolver{}
-		b, base, err = d.Resolve("", loc)
+		b, base, err = d.Resolve("", file)
 	} else {
-		b, base, err = r.Resolve("", loc)
+		b, base, err = r.Resolve("", file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve document %q: %w", loc, err)
+	}
+
+	o := newLoadOptions(opts)
+	d, err := loadDocumentBytes(b, base, file, r, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.finish(); err != nil {
+		return nil, err
 	}
+	if frag != "" {
+		return selectFragment(d, frag)
+	}
+	return d, nil
+}
 
+// LoadDocumentBytes loads a document from YAML/JSON bytes. Relative
+// references in the document are resolved against `base`.
+//
+// Since the document location is unknown, source positions of the
+// loaded elements will not include a file name.
+func LoadDocumentBytes(b []byte, base string, r Resolver, opts ...LoadOption) (Document, error) {
+	o := newLoadOptions(opts)
+	d, err := loadDocumentBytes(b, base, "", r, o)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve document: %s", err)
+		return nil, err
+	}
+	if err := o.finish(); err != nil {
+		return nil, err
 	}
-	return LoadDocumentBytes(b, base, r)
+	return d, nil
 }
 
-func LoadDocumentBytes(b []byte, base string, r Resolver) (Document, error) {
+// loadDocumentBytes loads a document from bytes. `file` is the location of
+// the document, used for source positions and error messages.
+//
+// Nested documents, such as a step's "run" file, share the options of
+// the root document.
+func loadDocumentBytes(b []byte, base, file string, r Resolver, opts *loadOptions) (Document, error) {
 	if r == nil {
 		r = NoResolve()
 	}
 
-	l := loader{base, r}
+	l := loader{base: base, resolver: r, file: file, opts: opts}
 	// Parse the YAML into an AST
 	yamlnode, err := yamlast.Parse(b)
 	if err != nil {
-		return nil, fmt.Errorf("parsing yaml: %s", err)
+		return nil, l.fileError(fmt.Errorf("parsing yaml: %s", err))
 	}
 
 	if yamlnode == nil {
-		return nil, fmt.Errorf("empty yaml")
+		return nil, l.fileError(fmt.Errorf("empty yaml"))
 	}
 
 	if len(yamlnode.Children) > 1 {
-		return nil, fmt.Errorf("unexpected child count")
+		return nil, l.fileError(fmt.Errorf("unexpected child count"))
 	}
 
 	// Being recursively processing the tree.
 	var d Document
 	start := node(yamlnode.Children[0])
+	l.index(start, "")
 	start, err = l.preprocess(start)
 	if err != nil {
 		return nil, err
 	}
+	// Draft-3 documents are loaded in the v1.0 form. See Upgrade.
+	if isDraft3(start) {
+		start = convertDraft3(start)
+	}
+	// Preprocessing may have replaced nodes (e.g. $import),
+	// so rebuild the field path index.
+	l.paths = nil
+	l.index(start, "")
 
 	// Dump the tree for debugging.
 	//dump(start, "")
@@ -70,25 +125,64 @@ func LoadDocumentBytes(b []byte, base string, r Resolver) (Document, error) {
 		return nil, err
 	}
 	if d != nil {
+		ResolveIDs(d, documentURI(file, r))
 		return d, nil
 	}
 	return nil, nil
 }
 
+// LoadOption configures optional document loading behavior,
+// such as strict field checking.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	strict bool
+	warn   *[]UnknownField
+	// unknown fields collected from all documents being loaded.
+	unknown []UnknownField
+}
+
+func newLoadOptions(opts []LoadOption) *loadOptions {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// finish is called after the root document has loaded,
+// reporting the unknown fields collected while loading.
+func (o *loadOptions) finish() error {
+	if len(o.unknown) == 0 {
+		return nil
+	}
+	if o.warn != nil {
+		*o.warn = append(*o.warn, o.unknown...)
+	}
+	if o.strict {
+		return &UnknownFieldsError{Fields: o.unknown}
+	}
+	return nil
+}
+
 func LoadValuesFile(p string) (Values, error) {
 	b, err := ioutil.ReadFile(p)
 	if err != nil {
 		return nil, err
 	}
-	return LoadValuesBytes(b)
+	return loadValuesBytes(b, p)
 }
 
 func LoadValuesBytes(b []byte) (Values, error) {
-	l := loader{}
+	return loadValuesBytes(b, "")
+}
+
+func loadValuesBytes(b []byte, file string) (Values, error) {
+	l := loader{file: file}
 	// Parse the YAML into an AST
 	yamlnode, err := yamlast.Parse(b)
 	if err != nil {
-		return nil, fmt.Errorf("parsing yaml: %s", err)
+		return nil, l.fileError(fmt.Errorf("parsing yaml: %s", err))
 	}
 
 	v := Values{}
@@ -97,14 +191,19 @@ func LoadValuesBytes(b []byte) (Values, error) {
 	}
 
 	if len(yamlnode.Children) > 1 {
-		return nil, fmt.Errorf("unexpected child count")
+		return nil, l.fileError(fmt.Errorf("unexpected child count"))
 	}
 
 	start := node(yamlnode.Children[0])
+	l.index(start, "")
 	start, err = l.preprocess(start)
 	if err != nil {
 		return nil, err
 	}
+	// Draft-3 documents are loaded in the v1.0 form. See Upgrade.
+	if isDraft3(start) {
+		start = convertDraft3(start)
+	}
 
 	err = l.load(start, &v)
 	if err != nil {
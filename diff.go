@@ -0,0 +1,639 @@
+package cwl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is a difference between two versions of a document, found by Diff.
+type Change struct {
+	// Path locates the changed element by CWL field names and IDs,
+	// e.g. "inputs.reads.inputBinding.prefix".
+	Path string
+	// Breaking is true if callers of the process may need to change,
+	// e.g. because a required input was added or an output was removed.
+	Breaking bool
+	Message  string
+}
+
+func (c Change) String() string {
+	if c.Path == "" {
+		return c.Message
+	}
+	return c.Path + ": " + c.Message
+}
+
+// HasBreaking returns true if any of the changes are breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff compares two versions of a document field by field, ignoring
+// formatting and the order of inputs, outputs, requirements and steps.
+// Inputs, outputs, requirements, hints and steps are matched by ID or
+// class, and the processes run by steps are compared recursively.
+// The processes of $graph documents are matched by ID.
+//
+// Changes are reported in the order of the old document, followed by
+// additions, in the order of the new document.
+//
+// Changes are breaking if callers of the process may need to change:
+//   - an input or output is removed, or a required input is added
+//   - an input type accepts fewer values, e.g. "File?" becomes "File",
+//     or an output type has more values, e.g. "File" becomes "File?"
+//   - the default of an input without a null type is removed
+//   - a requirement which isn't part of the CWL spec is added
+//
+// Other changes, such as changes to bindings, Docker images, defaults,
+// or the steps of a workflow and the processes they run, don't change
+// how the process is called.
+func Diff(old, new Document) []Change {
+	d := differ{}
+	d.document("", old, new)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) report(path string, breaking bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Path:     path,
+		Breaking: breaking,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *differ) document(path string, old, new Document) {
+	oldDocs, oldGraph := graphMembers(old)
+	newDocs, newGraph := graphMembers(new)
+	if !oldGraph || !newGraph {
+		d.process(path, old, new)
+		return
+	}
+
+	ids := func(docs []Document) map[string]Document {
+		m := map[string]Document{}
+		for _, doc := range docs {
+			m[strings.TrimPrefix(processIDOf(doc), "#")] = doc
+		}
+		return m
+	}
+	oldIDs := ids(oldDocs)
+	newIDs := ids(newDocs)
+
+	for _, doc := range oldDocs {
+		id := strings.TrimPrefix(processIDOf(doc), "#")
+		p := joinPath(path, "#"+id)
+		if n, ok := newIDs[id]; ok {
+			d.process(p, doc, n)
+		} else {
+			d.report(p, true, "process removed")
+		}
+	}
+	for _, doc := range newDocs {
+		id := strings.TrimPrefix(processIDOf(doc), "#")
+		if _, ok := oldIDs[id]; !ok {
+			d.report(joinPath(path, "#"+id), false, "process added")
+		}
+	}
+}
+
+func graphMembers(doc Document) ([]Document, bool) {
+	switch z := doc.(type) {
+	case Graph:
+		return z.Docs, true
+	case *Graph:
+		return z.Docs, true
+	}
+	return nil, false
+}
+
+func (d *differ) process(path string, old, new Document) {
+	if old.Doctype() != new.Doctype() {
+		d.report(joinPath(path, "class"), false, "changed from %s to %s", old.Doctype(), new.Doctype())
+	}
+
+	// The fields of the process itself, e.g. baseCommand or expression.
+	d.fields(path, old, new, "class", "id", "inputs", "outputs", "requirements", "hints", "steps", "arguments")
+	d.arguments(path, old, new)
+
+	oldIns, oldOuts := diffParams(old)
+	newIns, newOuts := diffParams(new)
+	d.inputs(joinPath(path, "inputs"), oldIns, newIns)
+	d.outputs(joinPath(path, "outputs"), oldOuts, newOuts)
+
+	oldReqs, oldHints := processRequirements(old)
+	newReqs, newHints := processRequirements(new)
+	d.requirements(joinPath(path, "requirements"), oldReqs, newReqs, false)
+	d.requirements(joinPath(path, "hints"), oldHints, newHints, true)
+
+	oldWf, _ := old.(*Workflow)
+	newWf, _ := new.(*Workflow)
+	if oldWf != nil && newWf != nil {
+		d.steps(joinPath(path, "steps"), oldWf.Steps, newWf.Steps)
+	}
+}
+
+// diffParam holds the parts of an input or output compared by Diff.
+type diffParam struct {
+	id    string
+	types []cwltype
+	def   Value
+	// param is the input or output, for comparing the other fields.
+	param interface{}
+}
+
+func diffParams(doc Document) (inputs, outputs []diffParam) {
+	switch z := doc.(type) {
+	case *Tool:
+		for _, in := range z.Inputs {
+			inputs = append(inputs, diffParam{LocalID(in.ID), inputCWLTypes(in.Type), in.Default, in})
+		}
+		for _, out := range z.Outputs {
+			outputs = append(outputs, diffParam{LocalID(out.ID), outputCWLTypes(out.Type), nil, out})
+		}
+	case *ExpressionTool:
+		for _, in := range z.Inputs {
+			inputs = append(inputs, diffParam{LocalID(in.ID), inputCWLTypes(in.Type), in.Default, in})
+		}
+		for _, out := range z.Outputs {
+			outputs = append(outputs, diffParam{LocalID(out.ID), outputCWLTypes(out.Type), nil, out})
+		}
+	case *Workflow:
+		for _, in := range z.Inputs {
+			inputs = append(inputs, diffParam{LocalID(in.ID), inputCWLTypes(in.Type), in.Default, in})
+		}
+		for _, out := range z.Outputs {
+			outputs = append(outputs, diffParam{LocalID(out.ID), outputCWLTypes(out.Type), nil, out})
+		}
+	}
+	return
+}
+
+func (d *differ) inputs(path string, old, new []diffParam) {
+	newIDs := map[string]diffParam{}
+	for _, p := range new {
+		newIDs[p.id] = p
+	}
+	oldIDs := map[string]bool{}
+
+	for _, o := range old {
+		oldIDs[o.id] = true
+		p := joinPath(path, o.id)
+		n, ok := newIDs[o.id]
+		if !ok {
+			d.report(p, true, "input removed")
+			continue
+		}
+
+		if describeTypes(o.types) != describeTypes(n.types) {
+			d.report(joinPath(p, "type"), !accepts(n.types, o.types),
+				"changed from %s to %s", describeTypes(o.types), describeTypes(n.types))
+		}
+
+		switch {
+		case o.def == nil && n.def != nil:
+			d.report(joinPath(p, "default"), false, "set to %s", formatValue(n.def))
+		case o.def != nil && n.def == nil:
+			d.report(joinPath(p, "default"), !hasNullType(n.types), "removed, was %s", formatValue(o.def))
+		case !jsonEqual(o.def, n.def):
+			d.report(joinPath(p, "default"), false, "changed from %s to %s", formatValue(o.def), formatValue(n.def))
+		}
+
+		d.fields(p, o.param, n.param, "id", "type", "default")
+	}
+
+	for _, n := range new {
+		if oldIDs[n.id] {
+			continue
+		}
+		if n.def == nil && !hasNullType(n.types) {
+			d.report(joinPath(path, n.id), true, "required input added")
+		} else {
+			d.report(joinPath(path, n.id), false, "optional input added")
+		}
+	}
+}
+
+func (d *differ) outputs(path string, old, new []diffParam) {
+	newIDs := map[string]diffParam{}
+	for _, p := range new {
+		newIDs[p.id] = p
+	}
+	oldIDs := map[string]bool{}
+
+	for _, o := range old {
+		oldIDs[o.id] = true
+		p := joinPath(path, o.id)
+		n, ok := newIDs[o.id]
+		if !ok {
+			d.report(p, true, "output removed")
+			continue
+		}
+		if describeTypes(o.types) != describeTypes(n.types) {
+			d.report(joinPath(p, "type"), !accepts(o.types, n.types),
+				"changed from %s to %s", describeTypes(o.types), describeTypes(n.types))
+		}
+		d.fields(p, o.param, n.param, "id", "type")
+	}
+
+	for _, n := range new {
+		if !oldIDs[n.id] {
+			d.report(joinPath(path, n.id), false, "output added")
+		}
+	}
+}
+
+// arguments compares the arguments of two tools. Arguments are matched
+// by position, so they're compared field by field if only their fields
+// changed, and as a whole if arguments were added or removed.
+func (d *differ) arguments(path string, old, new Document) {
+	oldTool, _ := old.(*Tool)
+	newTool, _ := new.(*Tool)
+	if oldTool == nil || newTool == nil {
+		return
+	}
+	p := joinPath(path, "arguments")
+	o, n := oldTool.Arguments, newTool.Arguments
+	if len(o) != len(n) {
+		d.report(p, false, "changed from %s to %s", formatValue(o), formatValue(n))
+		return
+	}
+	for i := range o {
+		d.fields(fmt.Sprintf("%s[%d]", p, i), o[i], n[i])
+	}
+}
+
+func (d *differ) requirements(path string, old, new []Requirement, hints bool) {
+	kind := "requirement"
+	if hints {
+		kind = "hint"
+	}
+
+	newClasses := map[string]Requirement{}
+	for _, r := range new {
+		newClasses[requirementClass(r)] = r
+	}
+	oldClasses := map[string]bool{}
+
+	for _, o := range old {
+		class := requirementClass(o)
+		oldClasses[class] = true
+		p := joinPath(path, class)
+		n, ok := newClasses[class]
+		if !ok {
+			d.report(p, false, "%s removed", kind)
+			continue
+		}
+
+		od, isDocker := o.(DockerRequirement)
+		if nd, _ := n.(DockerRequirement); isDocker && od.Pull != nd.Pull {
+			d.report(joinPath(p, "dockerPull"), false, "docker image changed from %q to %q", od.Pull, nd.Pull)
+			d.fields(p, o, n, "class", "dockerPull")
+			continue
+		}
+		d.fields(p, o, n, "class")
+	}
+
+	for _, n := range new {
+		class := requirementClass(n)
+		if oldClasses[class] {
+			continue
+		}
+		// Runners may not support requirements outside of the spec,
+		// but are free to ignore hints.
+		_, unknown := n.(UnknownRequirement)
+		d.report(joinPath(path, class), unknown && !hints, "%s added", kind)
+	}
+}
+
+// requirementClass returns the class of a requirement, e.g. "DockerRequirement".
+func requirementClass(r Requirement) string {
+	if u, ok := r.(UnknownRequirement); ok {
+		return u.Name
+	}
+	return reflect.TypeOf(r).Name()
+}
+
+func (d *differ) steps(path string, old, new []Step) {
+	newIDs := map[string]Step{}
+	for _, s := range new {
+		newIDs[LocalID(s.ID)] = s
+	}
+	oldIDs := map[string]bool{}
+
+	for _, o := range old {
+		id := LocalID(o.ID)
+		oldIDs[id] = true
+		p := joinPath(path, id)
+		n, ok := newIDs[id]
+		if !ok {
+			d.report(p, false, "step removed")
+			continue
+		}
+		d.fields(p, o, n, "id", "run")
+
+		oldRun, oldOK := o.Run.(Document)
+		newRun, newOK := n.Run.(Document)
+		if oldOK && newOK && isProcess(oldRun) && isProcess(newRun) {
+			// The workflow's callers don't call its steps, so changes
+			// to the processes they run aren't breaking.
+			sub := differ{}
+			sub.process(joinPath(p, "run"), oldRun, newRun)
+			for _, c := range sub.changes {
+				c.Breaking = false
+				d.changes = append(d.changes, c)
+			}
+		}
+	}
+
+	for _, n := range new {
+		if id := LocalID(n.ID); !oldIDs[id] {
+			d.report(joinPath(path, id), false, "step added")
+		}
+	}
+}
+
+// fields compares the fields of two values, e.g. two bindings, as they're
+// marshaled to JSON. Nested objects are compared field by field.
+// Fields named in "skip" are ignored.
+func (d *differ) fields(path string, old, new interface{}, skip ...string) {
+	o := jsonObject(old)
+	n := jsonObject(new)
+	for _, s := range skip {
+		delete(o, s)
+		delete(n, s)
+	}
+
+	var keys []string
+	for k := range o {
+		keys = append(keys, k)
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ov, inOld := o[k]
+		nv, inNew := n[k]
+		p := joinPath(path, k)
+
+		om, oldObj := ov.(map[string]interface{})
+		nm, newObj := nv.(map[string]interface{})
+
+		switch {
+		case !inOld:
+			d.report(p, false, "set to %s", formatValue(nv))
+		case !inNew:
+			d.report(p, false, "removed, was %s", formatValue(ov))
+		case oldObj && newObj:
+			d.fields(p, om, nm)
+		case !reflect.DeepEqual(ov, nv):
+			d.report(p, false, "changed from %s to %s", formatValue(ov), formatValue(nv))
+		}
+	}
+}
+
+// jsonObject returns the fields of a value marshaled to a JSON object,
+// or an empty map if it isn't an object, e.g. a nil binding.
+func jsonObject(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	return m
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, errx := json.Marshal(a)
+	y, erry := json.Marshal(b)
+	return errx == nil && erry == nil && string(x) == string(y)
+}
+
+// formatValue formats a value as compact JSON, abbreviated if it's long.
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return abbrev(string(b), 60)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func inputCWLTypes(types []InputType) []cwltype {
+	var out []cwltype
+	for _, t := range types {
+		out = append(out, t)
+	}
+	return out
+}
+
+func outputCWLTypes(types []OutputType) []cwltype {
+	var out []cwltype
+	for _, t := range types {
+		out = append(out, t)
+	}
+	return out
+}
+
+func hasNullType(types []cwltype) bool {
+	for _, t := range types {
+		if _, ok := t.(Null); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordField is a field of an input or output record type.
+type recordField struct {
+	name  string
+	types []cwltype
+}
+
+func enumSymbols(t cwltype) ([]string, bool) {
+	switch z := t.(type) {
+	case InputEnum:
+		return z.Symbols, true
+	case OutputEnum:
+		return z.Symbols, true
+	}
+	return nil, false
+}
+
+func recordFields(t cwltype) ([]recordField, bool) {
+	var out []recordField
+	switch z := t.(type) {
+	case InputRecord:
+		for _, f := range z.Fields {
+			out = append(out, recordField{f.Name, inputCWLTypes(f.Type)})
+		}
+	case OutputRecord:
+		for _, f := range z.Fields {
+			out = append(out, recordField{f.Name, outputCWLTypes(f.Type)})
+		}
+	default:
+		return nil, false
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].name < out[j].name
+	})
+	return out, true
+}
+
+func arrayItems(t cwltype) ([]cwltype, bool) {
+	switch z := t.(type) {
+	case InputArray:
+		return inputCWLTypes(z.Items), true
+	case OutputArray:
+		return outputCWLTypes(z.Items), true
+	}
+	return nil, false
+}
+
+// describeTypes formats a type union, like typeString, including the
+// symbols of enums and the fields of records, e.g. "enum(a, b)?".
+func describeTypes(types []cwltype) string {
+	var names []string
+	optional := false
+	for _, t := range types {
+		if _, ok := t.(Null); ok {
+			optional = true
+			continue
+		}
+		names = append(names, describeType(t))
+	}
+	switch {
+	case len(names) == 0:
+		return "null"
+	case optional && len(names) == 1:
+		return names[0] + "?"
+	case optional:
+		names = append([]string{"null"}, names...)
+	}
+	return strings.Join(names, " | ")
+}
+
+func describeType(t cwltype) string {
+	if s, ok := enumSymbols(t); ok {
+		return "enum(" + strings.Join(s, ", ") + ")"
+	}
+	if fields, ok := recordFields(t); ok {
+		var parts []string
+		for _, f := range fields {
+			parts = append(parts, f.name+": "+describeTypes(f.types))
+		}
+		return "record(" + strings.Join(parts, ", ") + ")"
+	}
+	if items, ok := arrayItems(t); ok {
+		s := describeTypes(items)
+		if strings.Contains(s, " | ") {
+			s = "(" + s + ")"
+		}
+		return s + "[]"
+	}
+	return paramTypeOf(t).String()
+}
+
+// accepts returns true if every value of the types "old" is also
+// a value of the types "new", e.g. "File?" accepts "File", and "long"
+// accepts "int".
+func accepts(new, old []cwltype) bool {
+	for _, o := range old {
+		ok := false
+		for _, n := range new {
+			if typeAccepts(n, o) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func typeAccepts(n, o cwltype) bool {
+	switch z := n.(type) {
+	case Any:
+		_, null := o.(Null)
+		return !null
+	case TypeRef:
+		r, ok := o.(TypeRef)
+		return ok && r.Name == z.Name
+	}
+	switch o.(type) {
+	case Any, TypeRef:
+		return false
+	}
+
+	if ns, ok := enumSymbols(n); ok {
+		os, ok := enumSymbols(o)
+		if !ok {
+			return false
+		}
+		have := map[string]bool{}
+		for _, s := range ns {
+			have[LocalID(s)] = true
+		}
+		for _, s := range os {
+			if !have[LocalID(s)] {
+				return false
+			}
+		}
+		return true
+	}
+
+	if nf, ok := recordFields(n); ok {
+		of, ok := recordFields(o)
+		if !ok {
+			return false
+		}
+		oldFields := map[string][]cwltype{}
+		for _, f := range of {
+			oldFields[f.name] = f.types
+		}
+		for _, f := range nf {
+			ot, ok := oldFields[f.name]
+			if !ok {
+				if !hasNullType(f.types) {
+					return false
+				}
+				continue
+			}
+			if !accepts(f.types, ot) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if ni, ok := arrayItems(n); ok {
+		oi, ok := arrayItems(o)
+		return ok && accepts(ni, oi)
+	}
+	return typeAssignable(paramTypeOf(o), paramTypeOf(n))
+}
@@ -0,0 +1,217 @@
+package cwl
+
+import (
+	"strings"
+	"testing"
+)
+
+const diffOld = `
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: [bwa, mem]
+requirements:
+  - class: DockerRequirement
+    dockerPull: biocontainers/bwa:0.7.15
+  - class: ResourceRequirement
+    coresMin: 2
+hints:
+  - class: ShellCommandRequirement
+inputs:
+  reads:
+    type: File
+    inputBinding: {position: 2}
+  index: File
+  threads:
+    type: int
+    default: 1
+    inputBinding: {prefix: -t, position: 1}
+  mode:
+    type: {type: enum, symbols: [fast, slow]}
+  extra: string
+outputs:
+  sam: stdout
+  log: File?
+`
+
+// The same tool, reformatted and reordered, with changes.
+const diffNew = `
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: [bwa, mem]
+hints:
+  ShellCommandRequirement: {}
+requirements:
+  ResourceRequirement:
+    coresMin: 4
+  DockerRequirement:
+    dockerPull: biocontainers/bwa:0.7.17
+  example:Custom: {}
+inputs:
+  threads:
+    type: long
+    inputBinding:
+      position: 1
+      prefix: --threads
+  reads:
+    inputBinding: {position: 2}
+    type: File
+  index: File?
+  mode:
+    type: {type: enum, symbols: [slow]}
+  ref: File
+  verbose: boolean?
+outputs:
+  sam: stdout
+  log: File
+  metrics: File
+`
+
+func loadDiffDoc(t *testing.T, src string) Document {
+	t.Helper()
+	doc, err := LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func changeStrings(changes []Change) string {
+	var out []string
+	for _, c := range changes {
+		s := c.String()
+		if c.Breaking {
+			s += " (breaking)"
+		}
+		out = append(out, s)
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestDiff(t *testing.T) {
+	changes := Diff(loadDiffDoc(t, diffOld), loadDiffDoc(t, diffNew))
+
+	expect := `inputs.index.type: changed from File to File?
+inputs.threads.type: changed from int to long
+inputs.threads.default: removed, was 1 (breaking)
+inputs.threads.inputBinding.prefix: changed from "-t" to "--threads"
+inputs.mode.type: changed from enum(fast, slow) to enum(slow) (breaking)
+inputs.extra: input removed (breaking)
+inputs.ref: required input added (breaking)
+inputs.verbose: optional input added
+outputs.log.type: changed from File? to File
+outputs.metrics: output added
+requirements.DockerRequirement.dockerPull: docker image changed from "biocontainers/bwa:0.7.15" to "biocontainers/bwa:0.7.17"
+requirements.ResourceRequirement.coresMin: changed from "2" to "4"
+requirements.example:Custom: requirement added (breaking)`
+
+	if got := changeStrings(changes); got != expect {
+		t.Errorf("unexpected changes:\n%s", got)
+	}
+	if !HasBreaking(changes) {
+		t.Error("expected breaking changes")
+	}
+}
+
+func TestDiffSame(t *testing.T) {
+	changes := Diff(loadDiffDoc(t, diffOld), loadDiffDoc(t, diffOld))
+	if len(changes) != 0 {
+		t.Errorf("unexpected changes:\n%s", changeStrings(changes))
+	}
+}
+
+func TestDiffWorkflow(t *testing.T) {
+	old := loadDiffDoc(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  x: int
+outputs:
+  out:
+    type: int
+    outputSource: double/out
+steps:
+  double:
+    run:
+      class: ExpressionTool
+      inputs: {x: int}
+      outputs: {out: int}
+      expression: "$({out: inputs.x * 2})"
+    in: {x: x}
+    out: [out]
+  check:
+    run:
+      class: ExpressionTool
+      inputs: {x: int}
+      outputs: {out: int}
+      expression: "$({out: inputs.x})"
+    in: {x: x}
+    out: [out]
+`)
+	new := loadDiffDoc(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  x: int
+outputs:
+  out:
+    type: int
+    outputSource: triple/out
+steps:
+  triple:
+    run:
+      class: ExpressionTool
+      inputs: {x: int}
+      outputs: {out: int}
+      expression: "$({out: inputs.x * 3})"
+    in: {x: x}
+    out: [out]
+  check:
+    run:
+      class: ExpressionTool
+      inputs: {x: int}
+      outputs: {ok: boolean}
+      expression: "$({ok: true})"
+    in: {x: x}
+    out: [ok]
+`)
+
+	// Changes to the processes run by steps aren't breaking.
+	expect := `outputs.out.outputSource: changed from ["double/out"] to ["triple/out"]
+steps.double: step removed
+steps.check.out: changed from [{"id":"out"}] to [{"id":"ok"}]
+steps.check.run.expression: changed from "$({out: inputs.x})" to "$({ok: true})"
+steps.check.run.outputs.out: output removed
+steps.check.run.outputs.ok: output added
+steps.triple: step added`
+	if got := changeStrings(Diff(old, new)); got != expect {
+		t.Errorf("unexpected changes:\n%s", got)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	types := func(src string) []cwltype {
+		doc := loadDiffDoc(t, "cwlVersion: v1.0\nclass: CommandLineTool\ninputs:\n  x:\n    type: "+src+"\noutputs: []\n")
+		return inputCWLTypes(doc.(*Tool).Inputs[0].Type)
+	}
+
+	for _, c := range []struct {
+		new, old string
+		ok       bool
+	}{
+		{"File?", "File", true},
+		{"File", "File?", false},
+		{"long", "int", true},
+		{"int", "long", false},
+		{"Any", "File", true},
+		{"File", "Any", false},
+		{"string", "{type: enum, symbols: [a]}", true},
+		{"File[]", "File", false},
+		{"'File?[]'", "File[]", true},
+		{"{type: record, fields: [{name: a, type: int}, {name: b, type: 'File?'}]}", "{type: record, fields: [{name: a, type: int}]}", true},
+		{"{type: record, fields: [{name: a, type: int}, {name: b, type: File}]}", "{type: record, fields: [{name: a, type: int}]}", false},
+	} {
+		if got := accepts(types(c.new), types(c.old)); got != c.ok {
+			t.Errorf("expected accepts(%s, %s) to be %v", c.new, c.old, c.ok)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package cwl
+
+import (
+	"github.com/commondream/yamlast"
+	"strconv"
+	"strings"
+)
+
+// isDraft3 returns true if "n" is the root of a draft-3 document,
+// i.e. it has cwlVersion "draft-3" or "cwl:draft-3".
+func isDraft3(n node) bool {
+	if n.Kind != yamlast.MappingNode {
+		return false
+	}
+	v, ok := findValue(n, "cwlVersion")
+	return ok && v.Kind == yamlast.ScalarNode && strings.TrimPrefix(v.Value, "cwl:") == "draft-3"
+}
+
+// convertDraft3 rewrites the YAML of a draft-3 document in the v1.0 form,
+// so that it can be loaded, and upgraded by Upgrade:
+//   - IDs lose their "#" prefix, e.g. "#reads" is "reads", and the IDs of
+//     step inputs and outputs lose the step prefix, e.g. "#align/reads".
+//   - secondaryFiles move from inputBinding and outputBinding to the parameter.
+//   - step "inputs" and "outputs" are "in" and "out".
+//   - the "source" of a workflow output is its "outputSource", and sources
+//     refer to step outputs as "step/output" rather than "#step.output".
+//   - "description" is "doc".
+//   - expressions such as {engine: cwl:JsonPointer, script: job/reads} are
+//     parameter references or JavaScript, e.g. "$(inputs.reads)", and
+//     ExpressionEngineRequirement is InlineJavascriptRequirement.
+//   - CreateFileRequirement is InitialWorkDirRequirement.
+//
+// The cwlVersion is left as draft-3. The nodes are modified in place,
+// so positions in the document are kept.
+func convertDraft3(n node) node {
+	if g, ok := findValue(n, "$graph"); ok {
+		for _, d := range draft3Entries(g) {
+			convertDraft3Process(d)
+		}
+	} else {
+		convertDraft3Process(n)
+	}
+	return convertDraft3Expressions(n)
+}
+
+func convertDraft3Process(n node) {
+	if n.Kind != yamlast.MappingNode {
+		return
+	}
+	trimDraft3ID(n, "")
+	renameDraft3Key(n, "description", "doc")
+
+	workflow := draft3Class(n) == "Workflow"
+
+	if v, ok := findValue(n, "inputs"); ok {
+		for _, p := range draft3Entries(v) {
+			convertDraft3Param(p, false)
+		}
+	}
+	if v, ok := findValue(n, "outputs"); ok {
+		for _, p := range draft3Entries(v) {
+			convertDraft3Param(p, workflow)
+		}
+	}
+	convertDraft3Requirements(n)
+
+	if v, ok := findValue(n, "steps"); ok {
+		for _, s := range draft3Entries(v) {
+			convertDraft3Step(s)
+		}
+	}
+}
+
+func convertDraft3Param(p node, workflowOutput bool) {
+	if p.Kind != yamlast.MappingNode {
+		return
+	}
+	trimDraft3ID(p, "")
+	renameDraft3Key(p, "description", "doc")
+
+	for _, b := range []string{"inputBinding", "outputBinding"} {
+		binding, ok := findValue(p, b)
+		if !ok || binding.Kind != yamlast.MappingNode {
+			continue
+		}
+		k, v := removeDraft3Key(binding, "secondaryFiles")
+		if k == nil {
+			continue
+		}
+		if _, ok := findValue(p, "secondaryFiles"); !ok {
+			p.Children = append(p.Children, k, v)
+		}
+	}
+
+	if workflowOutput && renameDraft3Key(p, "source", "outputSource") {
+		src, _ := findValue(p, "outputSource")
+		convertDraft3Sources(src)
+	}
+}
+
+func convertDraft3Step(s node) {
+	if s.Kind != yamlast.MappingNode {
+		return
+	}
+	id := trimDraft3ID(s, "")
+	renameDraft3Key(s, "description", "doc")
+
+	if renameDraft3Key(s, "inputs", "in") {
+		in, _ := findValue(s, "in")
+		for _, e := range draft3Entries(in) {
+			if e.Kind != yamlast.MappingNode {
+				continue
+			}
+			trimDraft3ID(e, id)
+			if src, ok := findValue(e, "source"); ok {
+				convertDraft3Sources(src)
+			}
+		}
+	}
+	if renameDraft3Key(s, "outputs", "out") {
+		out, _ := findValue(s, "out")
+		for _, e := range draft3Entries(out) {
+			if e.Kind == yamlast.MappingNode {
+				trimDraft3ID(e, id)
+			} else {
+				e.Value = draft3ParamID(id, e.Value)
+			}
+		}
+	}
+	if v, ok := findValue(s, "scatter"); ok {
+		if v.Kind == yamlast.ScalarNode {
+			v.Value = draft3ParamID(id, v.Value)
+		}
+		for _, c := range draft3Entries(v) {
+			c.Value = draft3ParamID(id, c.Value)
+		}
+	}
+	convertDraft3Requirements(s)
+
+	if run, ok := findValue(s, "run"); ok {
+		convertDraft3Process(run)
+	}
+}
+
+// convertDraft3Requirements converts the requirements and hints of "n".
+func convertDraft3Requirements(n node) {
+	for _, key := range []string{"requirements", "hints"} {
+		reqs, ok := findValue(n, key)
+		if !ok {
+			continue
+		}
+		hasJS := false
+		for _, r := range draft3Entries(reqs) {
+			if draft3Class(r) == "InlineJavascriptRequirement" {
+				hasJS = true
+			}
+		}
+
+		var keep []*yamlast.Node
+		for _, r := range draft3Entries(reqs) {
+			if draft3Class(r) == "" {
+				keep = append(keep, r)
+				continue
+			}
+			class, _ := findValue(r, "class")
+			switch class.Value {
+			case "CreateFileRequirement":
+				class.Value = "InitialWorkDirRequirement"
+				if renameDraft3Key(r, "fileDef", "listing") {
+					listing, _ := findValue(r, "listing")
+					for _, e := range draft3Entries(listing) {
+						renameDraft3Key(e, "filename", "entryname")
+						renameDraft3Key(e, "fileContent", "entry")
+					}
+				}
+
+			case "ExpressionEngineRequirement":
+				if hasJS {
+					continue
+				}
+				hasJS = true
+				class.Value = "InlineJavascriptRequirement"
+				renameDraft3Key(r, "engineConfig", "expressionLib")
+				for _, k := range []string{"id", "requirements", "engineCommand"} {
+					removeDraft3Key(r, k)
+				}
+			}
+			keep = append(keep, r)
+		}
+		if reqs.Kind == yamlast.SequenceNode {
+			reqs.Children = keep
+		}
+	}
+}
+
+// convertDraft3Expressions replaces expression objects in the tree "n",
+// e.g. {engine: cwl:JsonPointer, script: job/reads}, with expressions.
+func convertDraft3Expressions(n node) node {
+	switch n.Kind {
+	case yamlast.MappingNode:
+		engine, ok := findValue(n, "engine")
+		script, ok2 := findValue(n, "script")
+		if ok && ok2 && len(n.Children) == 4 && script.Kind == yamlast.ScalarNode {
+			script.Value = draft3Expression(engine.Value, script.Value)
+			return script
+		}
+		for i := 1; i < len(n.Children); i += 2 {
+			n.Children[i] = convertDraft3Expressions(n.Children[i])
+		}
+	case yamlast.SequenceNode:
+		for i, c := range n.Children {
+			n.Children[i] = convertDraft3Expressions(c)
+		}
+	}
+	return n
+}
+
+// draft3Expression returns the expression for a draft-3 expression
+// object, e.g. "$(inputs.reads.path)" for the JSON pointer "job/reads/path".
+func draft3Expression(engine, script string) string {
+	if engine != "cwl:JsonPointer" {
+		script = strings.TrimSpace(script)
+		if strings.HasPrefix(script, "{") {
+			return "$" + script
+		}
+		return "$(" + script + ")"
+	}
+
+	parts := strings.Split(strings.Trim(script, "/"), "/")
+	switch parts[0] {
+	case "job":
+		parts[0] = "inputs"
+	case "context":
+		parts[0] = "self"
+	}
+	expr := parts[0]
+	for _, p := range parts[1:] {
+		if _, err := strconv.Atoi(p); err == nil {
+			expr += "[" + p + "]"
+		} else {
+			expr += "." + p
+		}
+	}
+	return "$(" + expr + ")"
+}
+
+// convertDraft3Sources converts a source, or a list of sources,
+// e.g. "#align.bam" to "align/bam".
+func convertDraft3Sources(n node) {
+	if n.Kind == yamlast.ScalarNode {
+		n.Value = strings.Replace(strings.TrimPrefix(n.Value, "#"), ".", "/", -1)
+	}
+	if n.Kind == yamlast.SequenceNode {
+		for _, c := range n.Children {
+			convertDraft3Sources(c)
+		}
+	}
+}
+
+// trimDraft3ID converts the "id" field of mapping "n", and returns it.
+// See draft3ParamID.
+func trimDraft3ID(n node, step string) string {
+	v, ok := findValue(n, "id")
+	if !ok || v.Kind != yamlast.ScalarNode {
+		return ""
+	}
+	v.Value = draft3ParamID(step, v.Value)
+	return v.Value
+}
+
+// draft3ParamID returns "id" without the "#" prefix, and without the
+// prefix of "step", if any, e.g. "reads" for "#align/reads" or "#align.reads".
+func draft3ParamID(step, id string) string {
+	id = strings.TrimPrefix(id, "#")
+	if step != "" {
+		for _, sep := range []string{"/", "."} {
+			if strings.HasPrefix(id, step+sep) {
+				return id[len(step)+1:]
+			}
+		}
+	}
+	return id
+}
+
+// draft3Class returns the class of mapping "n", if any.
+func draft3Class(n node) string {
+	if n.Kind != yamlast.MappingNode {
+		return ""
+	}
+	if c, ok := findValue(n, "class"); ok {
+		return c.Value
+	}
+	return ""
+}
+
+// draft3Entries returns the entries of a list, or the values of a map.
+func draft3Entries(n node) []*yamlast.Node {
+	switch n.Kind {
+	case yamlast.SequenceNode:
+		return n.Children
+	case yamlast.MappingNode:
+		var out []*yamlast.Node
+		for _, kv := range itermap(n) {
+			out = append(out, kv.v)
+		}
+		return out
+	}
+	return nil
+}
+
+// renameDraft3Key renames field "from" of mapping "n" to "to",
+// returning false if "n" has no such field.
+func renameDraft3Key(n node, from, to string) bool {
+	if n.Kind != yamlast.MappingNode {
+		return false
+	}
+	for i := 0; i < len(n.Children)-1; i += 2 {
+		if n.Children[i].Value == from {
+			n.Children[i].Value = to
+			return true
+		}
+	}
+	return false
+}
+
+// removeDraft3Key removes field "key" of mapping "n",
+// returning its key and value nodes, if found.
+func removeDraft3Key(n node, key string) (node, node) {
+	for i := 0; i < len(n.Children)-1; i += 2 {
+		if n.Children[i].Value == key {
+			k, v := n.Children[i], n.Children[i+1]
+			n.Children = append(n.Children[:i:i], n.Children[i+2:]...)
+			return k, v
+		}
+	}
+	return nil, nil
+}
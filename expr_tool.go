@@ -15,6 +15,13 @@ type ExpressionTool struct {
 	Outputs []CommandOutput `json:"outputs,omitempty"`
 
 	Expression Expression `json:"expression,omitempty"`
+
+	Extensions map[string]Value `json:"-" yaml:"-"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 /*
@@ -0,0 +1,102 @@
+package cwl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const extensionsDoc = `
+cwlVersion: v1.0
+class: Workflow
+$namespaces:
+  s: https://schema.org/
+  sbg: https://sevenbridges.com
+$schemas:
+  - https://schema.org/version/latest/schema.rdf
+s:author:
+  - class: s:Person
+    s:name: Jane Doe
+sbg:toolkit: bwa
+hints:
+  - class: sbg:AWSInstanceType
+    value: c4.2xlarge
+inputs: []
+outputs: []
+steps:
+  align:
+    sbg:x: 100
+    run:
+      class: CommandLineTool
+      sbg:revision: 3
+      sbg:public: true
+      sbg:price: 0.5
+      sbg:project: null
+      sbg:version: "3"
+      inputs: []
+      outputs: []
+    in: []
+    out: []
+`
+
+func TestExtensions(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(extensionsDoc), "", nil, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExtensions(t, doc.(*Workflow))
+
+	b, err := MarshalYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"sbg:revision: 3\n", "sbg:public: true\n", `sbg:version: "3"`} {
+		if !strings.Contains(string(b), line) {
+			t.Errorf("expected marshaled document to contain %q:\n%s", line, b)
+		}
+	}
+	doc, err = LoadDocumentBytes(b, "", nil, Strict())
+	if err != nil {
+		t.Fatalf("loading marshaled document: %s\n%s", err, b)
+	}
+	checkExtensions(t, doc.(*Workflow))
+}
+
+func checkExtensions(t *testing.T, wf *Workflow) {
+	expect := map[string]Value{
+		"$namespaces": map[string]Value{
+			"s":   "https://schema.org/",
+			"sbg": "https://sevenbridges.com",
+		},
+		"$schemas": []Value{"https://schema.org/version/latest/schema.rdf"},
+		"s:author": []Value{
+			map[string]Value{"class": "s:Person", "s:name": "Jane Doe"},
+		},
+		"sbg:toolkit": "bwa",
+	}
+	if !reflect.DeepEqual(wf.Extensions, expect) {
+		t.Errorf("unexpected workflow extensions:\n%#v", wf.Extensions)
+	}
+
+	hint := wf.Hints[0].(UnknownRequirement)
+	if hint.Name != "sbg:AWSInstanceType" ||
+		!reflect.DeepEqual(hint.Extensions, map[string]Value{"value": "c4.2xlarge"}) {
+		t.Errorf("unexpected unknown requirement: %#v", hint)
+	}
+
+	step := wf.Steps[0]
+	if !reflect.DeepEqual(step.Extensions, map[string]Value{"sbg:x": 100}) {
+		t.Errorf("unexpected step extensions: %#v", step.Extensions)
+	}
+	tool := step.Run.(*Tool)
+	expect = map[string]Value{
+		"sbg:revision": 3,
+		"sbg:public":   true,
+		"sbg:price":    0.5,
+		"sbg:project":  nil,
+		"sbg:version":  "3",
+	}
+	if !reflect.DeepEqual(tool.Extensions, expect) {
+		t.Errorf("unexpected tool extensions: %#v", tool.Extensions)
+	}
+}
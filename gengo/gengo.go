@@ -0,0 +1,713 @@
+// Package gengo generates Go code with typed inputs and outputs for a CWL
+// process, so that programs which run the process are checked by the
+// compiler, instead of building cwl.Values by hand. See Generate.
+package gengo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/buchanae/cwl"
+	"go/format"
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// Options configures Generate.
+type Options struct {
+	// Package is the name of the generated package, e.g. "bwa".
+	Package string
+	// Source is the path of the document, which is noted in the header
+	// of the generated code.
+	Source string
+}
+
+// Generate returns the source of a Go file which declares an Inputs struct
+// and an Outputs struct for the inputs and outputs of a process, which is
+// a *cwl.Tool, *cwl.ExpressionTool or *cwl.Workflow.
+//
+// CWL types are mapped to Go types:
+//   - boolean, int, long, float, double and string to bool, int, int64,
+//     float32, float64 and string
+//   - File and Directory, and stdout and stderr outputs, to cwl.File
+//     and cwl.Directory
+//   - arrays to slices
+//   - enums to a string type, with a constant for each symbol
+//   - records to structs
+//   - optional types, and inputs with a default value, to pointers,
+//     except for arrays, which may be nil
+//   - Any, and unions of several types, to cwl.Value
+//
+// Enums and records are named after the input, output or field which
+// has the type, e.g. a record input "read_group" is a ReadGroup struct.
+//
+// The generated code converts Inputs and Outputs to and from cwl.Values,
+// with Inputs.Values and InputsFromValues, and Outputs.Values and
+// OutputsFromValues, which return an error if a required value is
+// missing or a value has the wrong type.
+//
+// Types defined by a SchemaDefRequirement are resolved first, which
+// modifies the document. See cwl.ResolveSchemaDefs.
+func Generate(doc cwl.Document, opts Options) ([]byte, error) {
+	if !token.IsIdentifier(opts.Package) {
+		return nil, fmt.Errorf("invalid package name %q", opts.Package)
+	}
+
+	if err := cwl.ResolveSchemaDefs(doc); err != nil {
+		return nil, err
+	}
+
+	var inputs, outputs []param
+	switch z := doc.(type) {
+	case *cwl.Tool:
+		inputs, outputs = commandParams(z.Inputs, z.Outputs)
+	case *cwl.ExpressionTool:
+		inputs, outputs = commandParams(z.Inputs, z.Outputs)
+	case *cwl.Workflow:
+		for _, in := range z.Inputs {
+			inputs = append(inputs, inputParam(in.ID, in.Label, in.Doc, in.Default, in.Type))
+		}
+		for _, out := range z.Outputs {
+			outputs = append(outputs, outputParam(out.ID, out.Label, out.Doc, out.Type))
+		}
+	default:
+		return nil, fmt.Errorf("can't generate code for a %s", doc.Doctype())
+	}
+
+	g := newGenerator()
+	in, err := g.record("Inputs", "input", inputs)
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.record("Outputs", "output", outputs)
+	if err != nil {
+		return nil, err
+	}
+	return g.file(opts, in, out)
+}
+
+// param describes an input, output or record field.
+type param struct {
+	name     string
+	doc      string
+	types    []fmt.Stringer
+	optional bool
+}
+
+func commandParams(ins []cwl.CommandInput, outs []cwl.CommandOutput) (inputs, outputs []param) {
+	for _, in := range ins {
+		inputs = append(inputs, inputParam(in.ID, in.Label, in.Doc, in.Default, in.Type))
+	}
+	for _, out := range outs {
+		outputs = append(outputs, outputParam(out.ID, out.Label, out.Doc, out.Type))
+	}
+	return
+}
+
+// inputParam describes an input. Inputs with a default value
+// are optional, since callers may omit them.
+func inputParam(id, label, doc string, def cwl.Value, types []cwl.InputType) param {
+	return param{
+		name:     cwl.LocalID(id),
+		doc:      docOf(label, doc),
+		types:    inputTypes(types),
+		optional: def != nil,
+	}
+}
+
+func outputParam(id, label, doc string, types []cwl.OutputType) param {
+	return param{
+		name:  cwl.LocalID(id),
+		doc:   docOf(label, doc),
+		types: outputTypes(types),
+	}
+}
+
+func docOf(label, doc string) string {
+	if doc != "" {
+		return doc
+	}
+	return label
+}
+
+func inputTypes(types []cwl.InputType) []fmt.Stringer {
+	out := make([]fmt.Stringer, len(types))
+	for i, t := range types {
+		out[i] = t
+	}
+	return out
+}
+
+func outputTypes(types []cwl.OutputType) []fmt.Stringer {
+	out := make([]fmt.Stringer, len(types))
+	for i, t := range types {
+		out[i] = t
+	}
+	return out
+}
+
+type kind int
+
+const (
+	basicKind kind = iota
+	anyKind
+	enumKind
+	recordKind
+	arrayKind
+	optionalKind
+)
+
+// goType describes the Go type of a CWL type.
+type goType struct {
+	kind kind
+	// name is the name of basic, enum and record types,
+	// e.g. "int64", "cwl.File" or "ReadGroup".
+	name string
+	// key names the functions which convert the type to and from
+	// a cwl.Value, e.g. "int64ArrayFromValue".
+	key string
+	// elem is the item type of an array, or the type of an optional.
+	elem *goType
+	// from is the function which converts a cwl.Value to a basic type.
+	from string
+
+	symbols []string
+	fields  []field
+	// what names the fields of a record in errors, e.g. "input".
+	what string
+	doc  string
+}
+
+type field struct {
+	name   string
+	goName string
+	doc    string
+	typ    *goType
+	// optional is true if the CWL type includes null, or the input has
+	// a default value. Required fields may have a Go type which can be nil,
+	// e.g. a slice or cwl.Value, but a nil value isn't valid.
+	optional bool
+}
+
+// expr returns the Go type expression of the type, e.g. "[]*int".
+func (t *goType) expr() string {
+	switch t.kind {
+	case arrayKind:
+		return "[]" + t.elem.expr()
+	case optionalKind:
+		return "*" + t.elem.expr()
+	}
+	return t.name
+}
+
+// nullable returns true if a nil value of the type means null.
+func (t *goType) nullable() bool {
+	return t.kind == arrayKind || t.kind == optionalKind || t.kind == anyKind
+}
+
+var basicTypes = map[string]*goType{
+	"boolean":   {name: "bool", key: "bool", from: "cast.ToBoolE"},
+	"int":       {name: "int", key: "int", from: "cast.ToIntE"},
+	"long":      {name: "int64", key: "int64", from: "cast.ToInt64E"},
+	"float":     {name: "float32", key: "float32", from: "cast.ToFloat32E"},
+	"double":    {name: "float64", key: "float64", from: "cast.ToFloat64E"},
+	"string":    {name: "string", key: "string", from: "cast.ToStringE"},
+	"File":      {name: "cwl.File", key: "file"},
+	"Directory": {name: "cwl.Directory", key: "directory"},
+}
+
+var anyType = &goType{kind: anyKind, name: "cwl.Value", key: "any"}
+
+type generator struct {
+	// names holds the names of the declared types.
+	names map[string]bool
+	// decls holds the enums and records, in the order they're declared.
+	decls []*goType
+	// funcs holds the types which need conversion functions, by key.
+	funcs map[string]*goType
+	order []string
+}
+
+func newGenerator() *generator {
+	g := &generator{
+		names: map[string]bool{},
+		funcs: map[string]*goType{},
+	}
+	// Reserve the names used by conversion functions of other types.
+	for _, t := range basicTypes {
+		g.names[exportName(t.key)] = true
+	}
+	g.names["Any"] = true
+	return g
+}
+
+// typeName returns a unique name for a type named after "name".
+func (g *generator) typeName(name string) string {
+	name = unique(exportName(name), g.names)
+	g.names[name] = true
+	return name
+}
+
+// use registers the conversion functions of a type.
+func (g *generator) use(t *goType) *goType {
+	if _, ok := g.funcs[t.key]; !ok {
+		g.funcs[t.key] = t
+		g.order = append(g.order, t.key)
+	}
+	return t
+}
+
+// paramType returns the Go type of a union of CWL types.
+func (g *generator) paramType(name string, types []fmt.Stringer, optional bool) (*goType, error) {
+	var nonNull []fmt.Stringer
+	for _, t := range types {
+		if _, ok := t.(cwl.Null); ok {
+			optional = true
+			continue
+		}
+		nonNull = append(nonNull, t)
+	}
+
+	t := anyType
+	if len(nonNull) == 1 {
+		var err error
+		t, err = g.single(name, nonNull[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if optional && !t.nullable() {
+		t = &goType{kind: optionalKind, key: t.key + "Opt", elem: t}
+	}
+	return g.use(t), nil
+}
+
+func (g *generator) single(name string, t fmt.Stringer) (*goType, error) {
+	switch z := t.(type) {
+	case cwl.Stdout, cwl.Stderr:
+		return g.use(basicTypes["File"]), nil
+	case cwl.Any:
+		return g.use(anyType), nil
+	case cwl.TypeRef:
+		return nil, fmt.Errorf("unknown type %q", z.Name)
+
+	case cwl.InputEnum:
+		return g.enum(name, z.Symbols), nil
+	case cwl.OutputEnum:
+		return g.enum(name, z.Symbols), nil
+
+	case cwl.InputArray:
+		return g.array(name, inputTypes(z.Items))
+	case cwl.OutputArray:
+		return g.array(name, outputTypes(z.Items))
+
+	case cwl.InputRecord:
+		var fields []param
+		for _, f := range z.Fields {
+			fields = append(fields, param{name: f.Name, doc: docOf(f.Label, f.Doc), types: inputTypes(f.Type)})
+		}
+		return g.record(name, "field", fields)
+	case cwl.OutputRecord:
+		var fields []param
+		for _, f := range z.Fields {
+			fields = append(fields, param{name: f.Name, doc: f.Doc, types: outputTypes(f.Type)})
+		}
+		return g.record(name, "field", fields)
+	}
+
+	if b, ok := basicTypes[t.String()]; ok {
+		return g.use(b), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", t)
+}
+
+func (g *generator) enum(name string, symbols []string) *goType {
+	name = g.typeName(name)
+	t := &goType{kind: enumKind, name: name, key: lowerFirst(name)}
+	for _, s := range symbols {
+		t.symbols = append(t.symbols, cwl.LocalID(s))
+	}
+	t.doc = fmt.Sprintf("%s is one of %s.", name, strings.Join(t.symbols, ", "))
+	g.decls = append(g.decls, t)
+	return g.use(t)
+}
+
+func (g *generator) array(name string, items []fmt.Stringer) (*goType, error) {
+	elem, err := g.paramType(name+"Item", items, false)
+	if err != nil {
+		return nil, err
+	}
+	return g.use(&goType{kind: arrayKind, key: elem.key + "Array", elem: elem}), nil
+}
+
+// record returns a struct type for a record, or for Inputs and Outputs,
+// whose fields are described as "what" in errors, e.g. "input".
+func (g *generator) record(name, what string, params []param) (*goType, error) {
+	name = g.typeName(name)
+	t := &goType{kind: recordKind, name: name, key: lowerFirst(name), what: what}
+	g.decls = append(g.decls, t)
+
+	// The types of fields are named after the record, e.g. "SampleReads",
+	// except for Inputs and Outputs, which have a Values method.
+	prefix := name
+	goNames := map[string]bool{}
+	if what != "field" {
+		prefix = ""
+		goNames["Values"] = true
+	}
+	for _, p := range params {
+		ft, err := g.paramType(prefix+exportName(p.name), p.types, p.optional)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %s", what, p.name, err)
+		}
+		goName := unique(exportName(p.name), goNames)
+		goNames[goName] = true
+		t.fields = append(t.fields, field{
+			name:     p.name,
+			goName:   goName,
+			doc:      p.doc,
+			typ:      ft,
+			optional: p.optional || hasNull(p.types),
+		})
+	}
+	return g.use(t), nil
+}
+
+func hasNull(types []fmt.Stringer) bool {
+	for _, t := range types {
+		if _, ok := t.(cwl.Null); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// file writes the generated code.
+func (g *generator) file(opts Options, in, out *goType) ([]byte, error) {
+	var body bytes.Buffer
+	w := &body
+
+	fmt.Fprintln(w, "// Inputs are the inputs of the process.")
+	g.writeStruct(w, in)
+	fmt.Fprintln(w, "// Outputs are the outputs of the process.")
+	g.writeStruct(w, out)
+
+	for _, t := range g.decls {
+		switch {
+		case t.kind == enumKind:
+			g.writeEnum(w, t)
+		case t != in && t != out:
+			fmt.Fprintf(w, "// %s is a record.\n", t.name)
+			g.writeStruct(w, t)
+		}
+	}
+
+	fmt.Fprint(w, `// Values converts the inputs to a job order.
+// Optional inputs which are nil are omitted.
+func (in Inputs) Values() cwl.Values {
+	return inputsToValue(in)
+}
+
+// InputsFromValues converts a job order to Inputs. It returns an error
+// if a required input is missing or a value has the wrong type.
+func InputsFromValues(vals cwl.Values) (Inputs, error) {
+	return inputsFromValue(vals)
+}
+
+// Values converts the outputs to cwl.Values.
+// Optional outputs which are nil are omitted.
+func (out Outputs) Values() cwl.Values {
+	return outputsToValue(out)
+}
+
+// OutputsFromValues converts the outputs of the process to Outputs.
+// It returns an error if a required output is missing or a value has
+// the wrong type.
+func OutputsFromValues(vals cwl.Values) (Outputs, error) {
+	return outputsFromValue(vals)
+}
+
+`)
+
+	for _, key := range g.order {
+		g.writeFuncs(w, g.funcs[key])
+	}
+	w.WriteString(helpers)
+
+	var src bytes.Buffer
+	if opts.Source != "" {
+		fmt.Fprintf(&src, "// Code generated by cwl gen-go from %s. DO NOT EDIT.\n\n", opts.Source)
+	} else {
+		fmt.Fprint(&src, "// Code generated by cwl gen-go. DO NOT EDIT.\n\n")
+	}
+	fmt.Fprintf(&src, "package %s\n\nimport (\n", opts.Package)
+	if bytes.Contains(body.Bytes(), []byte("fmt.")) {
+		fmt.Fprintln(&src, `"fmt"`)
+	}
+	fmt.Fprintln(&src, `"github.com/buchanae/cwl"`)
+	if bytes.Contains(body.Bytes(), []byte("cast.")) {
+		fmt.Fprintln(&src, `"github.com/spf13/cast"`)
+	}
+	fmt.Fprint(&src, ")\n\n")
+	src.Write(body.Bytes())
+
+	b, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %s", err)
+	}
+	return b, nil
+}
+
+func (g *generator) writeStruct(w *bytes.Buffer, t *goType) {
+	fmt.Fprintf(w, "type %s struct {\n", t.name)
+	for i, f := range t.fields {
+		if f.doc != "" {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			writeDoc(w, f.doc)
+		}
+		tag := f.name
+		if f.optional {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(w, "%s %s `json:%q`\n", f.goName, f.typ.expr(), tag)
+	}
+	fmt.Fprint(w, "}\n\n")
+}
+
+func (g *generator) writeEnum(w *bytes.Buffer, t *goType) {
+	writeDoc(w, t.doc)
+	fmt.Fprintf(w, "type %s string\n\n", t.name)
+	if len(t.symbols) == 0 {
+		return
+	}
+	names := map[string]bool{}
+	fmt.Fprint(w, "const (\n")
+	for _, s := range t.symbols {
+		name := unique(t.name+exportName(s), names)
+		names[name] = true
+		fmt.Fprintf(w, "%s %s = %q\n", name, t.name, s)
+	}
+	fmt.Fprint(w, ")\n\n")
+}
+
+func writeDoc(w *bytes.Buffer, doc string) {
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		fmt.Fprintln(w, strings.TrimRight("// "+line, " \t"))
+	}
+}
+
+// writeFuncs writes the functions which convert a type to and from
+// a cwl.Value, named after its key, e.g. "intFromValue" and "intToValue".
+func (g *generator) writeFuncs(w *bytes.Buffer, t *goType) {
+	from := t.key + "FromValue"
+	to := t.key + "ToValue"
+	typ := t.expr()
+
+	switch t.kind {
+	case basicKind:
+		if t.from != "" {
+			fmt.Fprintf(w, `func %s(v cwl.Value) (%s, error) {
+	return %s(v)
+}
+
+`, from, typ, t.from)
+		} else {
+			// File or Directory
+			fmt.Fprintf(w, `func %s(v cwl.Value) (%s, error) {
+	switch z := v.(type) {
+	case %s:
+		return z, nil
+	case *%s:
+		return *z, nil
+	}
+	return %s{}, fmt.Errorf("expected a %s, got %%T", v)
+}
+
+`, from, typ, typ, typ, typ, strings.TrimPrefix(typ, "cwl."))
+		}
+		fmt.Fprintf(w, "func %s(x %s) cwl.Value {\n\treturn x\n}\n\n", to, typ)
+
+	case anyKind:
+		fmt.Fprintf(w, `func %s(v cwl.Value) (cwl.Value, error) {
+	return v, nil
+}
+
+func %s(x cwl.Value) cwl.Value {
+	return x
+}
+
+`, from, to)
+
+	case enumKind:
+		fmt.Fprintf(w, "func %s(v cwl.Value) (%s, error) {\n", from, typ)
+		fmt.Fprintf(w, "s, err := cast.ToStringE(v)\nif err != nil {\nreturn \"\", err\n}\n")
+		if len(t.symbols) > 0 {
+			var quoted []string
+			for _, s := range t.symbols {
+				quoted = append(quoted, fmt.Sprintf("%q", s))
+			}
+			fmt.Fprintf(w, "switch s {\ncase %s:\nreturn %s(s), nil\n}\n", strings.Join(quoted, ", "), typ)
+		}
+		fmt.Fprintf(w, "return \"\", fmt.Errorf(\"%%q is not one of %s\", s)\n}\n\n", strings.Join(t.symbols, ", "))
+		fmt.Fprintf(w, "func %s(x %s) cwl.Value {\n\treturn string(x)\n}\n\n", to, typ)
+
+	case arrayKind:
+		fmt.Fprintf(w, `func %s(v cwl.Value) (%s, error) {
+	list, err := valueList(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make(%s, len(list))
+	for i, x := range list {
+		if out[i], err = %sFromValue(x); err != nil {
+			return nil, fmt.Errorf("[%%d]: %%s", i, err)
+		}
+	}
+	return out, nil
+}
+
+func %s(x %s) cwl.Value {
+	out := make([]cwl.Value, len(x))
+	for i, y := range x {
+		out[i] = %sToValue(y)
+	}
+	return out
+}
+
+`, from, typ, typ, t.elem.key, to, typ, t.elem.key)
+
+	case optionalKind:
+		fmt.Fprintf(w, `func %s(v cwl.Value) (%s, error) {
+	if v == nil {
+		return nil, nil
+	}
+	x, err := %sFromValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return &x, nil
+}
+
+func %s(x %s) cwl.Value {
+	if x == nil {
+		return nil
+	}
+	return %sToValue(*x)
+}
+
+`, from, typ, t.elem.key, to, typ, t.elem.key)
+
+	case recordKind:
+		fmt.Fprintf(w, "func %s(v cwl.Value) (%s, error) {\n", from, typ)
+		fmt.Fprintf(w, "var r %s\n", typ)
+		if len(t.fields) == 0 {
+			fmt.Fprint(w, "_, err := valueMap(v)\nreturn r, err\n}\n\n")
+		} else {
+			fmt.Fprint(w, "m, err := valueMap(v)\nif err != nil {\nreturn r, err\n}\n")
+			for _, f := range t.fields {
+				fmt.Fprintf(w, "if x := m[%q]; x != nil {\n", f.name)
+				fmt.Fprintf(w, "if r.%s, err = %sFromValue(x); err != nil {\n", f.goName, f.typ.key)
+				fmt.Fprintf(w, "return r, fmt.Errorf(\"%s %%q: %%s\", %q, err)\n}\n", t.what, f.name)
+				if f.optional {
+					fmt.Fprint(w, "}\n")
+				} else {
+					fmt.Fprintf(w, "} else {\nreturn r, fmt.Errorf(\"missing required %s %%q\", %q)\n}\n", t.what, f.name)
+				}
+			}
+			fmt.Fprint(w, "return r, nil\n}\n\n")
+		}
+
+		fmt.Fprintf(w, "func %s(r %s) map[string]cwl.Value {\n", to, typ)
+		fmt.Fprint(w, "m := map[string]cwl.Value{}\n")
+		for _, f := range t.fields {
+			if f.optional {
+				fmt.Fprintf(w, "if r.%s != nil {\nm[%q] = %sToValue(r.%s)\n}\n", f.goName, f.name, f.typ.key, f.goName)
+			} else {
+				fmt.Fprintf(w, "m[%q] = %sToValue(r.%s)\n", f.name, f.typ.key, f.goName)
+			}
+		}
+		fmt.Fprint(w, "return m\n}\n\n")
+	}
+}
+
+// helpers are written at the end of the generated code.
+const helpers = `func valueMap(v cwl.Value) (map[string]cwl.Value, error) {
+	switch z := v.(type) {
+	case cwl.Values:
+		return z, nil
+	case map[string]cwl.Value:
+		return z, nil
+	case map[string]interface{}:
+		m := map[string]cwl.Value{}
+		for k, x := range z {
+			m[k] = x
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("expected a record, got %T", v)
+}
+
+func valueList(v cwl.Value) ([]cwl.Value, error) {
+	switch z := v.(type) {
+	case []cwl.Value:
+		return z, nil
+	case []interface{}:
+		list := make([]cwl.Value, len(z))
+		for i, x := range z {
+			list[i] = x
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("expected an array, got %T", v)
+}
+`
+
+// initialisms are written in upper case in Go names, e.g. "SampleID".
+var initialisms = map[string]bool{
+	"id": true, "uri": true, "url": true, "json": true, "cpu": true,
+}
+
+// exportName returns an exported Go name for a CWL name,
+// e.g. "ReadGroup" for "read_group".
+func exportName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// unique returns "name", or "name" with a number, e.g. "Reads2",
+// which isn't in "names".
+func unique(name string, names map[string]bool) string {
+	if !names[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		n := fmt.Sprintf("%s%d", name, i)
+		if !names[n] {
+			return n
+		}
+	}
+}
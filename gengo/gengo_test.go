@@ -0,0 +1,161 @@
+package gengo
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+	"strings"
+	"testing"
+)
+
+const genTool = `
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: align
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - name: Mode
+        type: enum
+        symbols: [fast, very-slow]
+inputs:
+  reads:
+    type: File[]
+    doc: reads to align
+  index: Directory
+  threads:
+    type: int
+    default: 1
+  mode: Mode?
+  read_group:
+    type:
+      type: record
+      fields:
+        - name: id
+          type: string
+        - name: library
+          type: string?
+  scores: "float[]?"
+  extra:
+    type: [int, string]
+  values: boolean
+  anything: Any
+  maybe: Any?
+outputs:
+  bam: stdout
+  stats:
+    type:
+      type: array
+      items:
+        type: record
+        fields:
+          - name: count
+            type: long
+          - name: mean
+            type: double
+`
+
+func generate(t *testing.T, src string) string {
+	t.Helper()
+	doc, err := cwl.LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Generate(doc, Options{Package: "align", Source: "align.cwl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGenerate(t *testing.T) {
+	src := generate(t, genTool)
+
+	for _, expect := range []string{
+		"// Code generated by cwl gen-go from align.cwl. DO NOT EDIT.",
+		"package align",
+		"type Inputs struct {\n\t// reads to align\n\tReads     []cwl.File    `json:\"reads\"`\n",
+		"\tIndex     cwl.Directory `json:\"index\"`",
+		"\tThreads   *int          `json:\"threads,omitempty\"`",
+		"\tMode      *Mode         `json:\"mode,omitempty\"`",
+		"\tReadGroup ReadGroup     `json:\"read_group\"`",
+		"\tScores    []float32     `json:\"scores,omitempty\"`",
+		"\tExtra     cwl.Value     `json:\"extra\"`",
+		"\tValues2   bool          `json:\"values\"`",
+		"\tAnything  cwl.Value     `json:\"anything\"`",
+		"\tMaybe     cwl.Value     `json:\"maybe,omitempty\"`",
+		"\tBam   cwl.File    `json:\"bam\"`",
+		"\tStats []StatsItem `json:\"stats\"`",
+		"type Mode string",
+		"\tModeFast     Mode = \"fast\"",
+		"\tModeVerySlow Mode = \"very-slow\"",
+		"type ReadGroup struct {\n\tID      string  `json:\"id\"`\n\tLibrary *string `json:\"library,omitempty\"`\n}",
+		"type StatsItem struct {\n\tCount int64   `json:\"count\"`\n\tMean  float64 `json:\"mean\"`\n}",
+		"func (in Inputs) Values() cwl.Values {",
+		"func InputsFromValues(vals cwl.Values) (Inputs, error) {",
+		"func (out Outputs) Values() cwl.Values {",
+		"func OutputsFromValues(vals cwl.Values) (Outputs, error) {",
+		`return r, fmt.Errorf("missing required input %q", "index")`,
+		`return r, fmt.Errorf("missing required output %q", "stats")`,
+		`return "", fmt.Errorf("%q is not one of fast, very-slow", s)`,
+	} {
+		if !strings.Contains(src, expect) {
+			t.Errorf("expected generated code to contain:\n%s\n\ngot:\n%s", expect, src)
+			return
+		}
+	}
+}
+
+// Required arrays, unions and Any values must be given, even though
+// their Go types may be nil.
+func TestGenerateRequired(t *testing.T) {
+	src := generate(t, genTool)
+
+	for _, name := range []string{"reads", "extra", "anything"} {
+		expect := fmt.Sprintf("return r, fmt.Errorf(\"missing required input %%q\", %q)", name)
+		if !strings.Contains(src, expect) {
+			t.Errorf("expected input %q to be required", name)
+		}
+	}
+	for _, name := range []string{"threads", "mode", "scores", "maybe"} {
+		expect := fmt.Sprintf("return r, fmt.Errorf(\"missing required input %%q\", %q)", name)
+		if strings.Contains(src, expect) {
+			t.Errorf("expected input %q to be optional", name)
+		}
+	}
+
+	for _, expect := range []string{
+		`m["reads"] = fileArrayToValue(r.Reads)`,
+		`m["extra"] = anyToValue(r.Extra)`,
+		`m["anything"] = anyToValue(r.Anything)`,
+		"if r.Scores != nil {\n\t\tm[\"scores\"] = float32ArrayToValue(r.Scores)",
+		"if r.Maybe != nil {\n\t\tm[\"maybe\"] = anyToValue(r.Maybe)",
+	} {
+		if !strings.Contains(src, expect) {
+			t.Errorf("expected generated code to contain:\n%s", expect)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(genTool), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(doc, Options{Package: "not-a-name"}); err == nil {
+		t.Error("expected an error for an invalid package name")
+	}
+
+	doc, err = cwl.LoadDocumentBytes([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  x: Unknown
+outputs: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(doc, Options{Package: "x"}); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
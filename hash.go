@@ -0,0 +1,340 @@
+package cwl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Hash returns a fingerprint of a document, e.g. "sha256:5e8f...", which
+// changes when the document changes in a way that matters to running it,
+// so it may be used as a cache key.
+//
+// The document is canonicalized before hashing:
+//   - it's marshaled to JSON after loading, so formatting, key order, YAML vs.
+//     JSON, and shortcut forms (e.g. "inputs" as a map or a list)
+//     don't matter
+//   - "doc" and "label" fields are removed, except in default values
+//   - IDs are reduced to their local name, e.g. "#main/reads" to "reads",
+//     and the leading "#" is removed from sources
+//   - inputs, outputs, steps, step inputs and outputs, and record fields
+//     are sorted by ID or name, and requirements and hints by class
+//   - the members of union types are sorted, so "File?" and ["null", "File"]
+//     are the same
+//
+// Documents run by steps are hashed as part of the document, since they're
+// embedded in it when it's loaded.
+func Hash(doc Document) (string, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("marshaling document: %s", err)
+	}
+	v, err := decodeJSON(b)
+	if err != nil {
+		return "", err
+	}
+	return hashJSON(canonicalDoc(v))
+}
+
+// HashValues returns a fingerprint of a job order, e.g. "sha256:0a1b...",
+// which changes when the input values change.
+//
+// The values are canonicalized before hashing. Files are identified by
+// their checksum, basename, format and secondary files, not their location,
+// so moving a file to a different directory doesn't change the hash.
+// Files without a checksum are hashed from their contents, or read from
+// their path or location, which must be a local file; relative paths are
+// relative to the working directory. Directories are identified by their
+// basename and listing, which is read from disk if it's empty.
+// Map keys are sorted, and numbers are hashed as they're formatted in JSON.
+func HashValues(vals Values) (string, error) {
+	v, err := canonicalValue(vals)
+	if err != nil {
+		return "", err
+	}
+	return hashJSON(v)
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %s", err)
+	}
+	return v, nil
+}
+
+// hashJSON hashes the JSON encoding of "v", which encoding/json
+// writes with sorted map keys.
+func hashJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// sortKeys lists the fields whose items are sorted by Hash,
+// and the key each item is sorted by.
+var sortKeys = map[string]string{
+	"inputs":       "id",
+	"outputs":      "id",
+	"steps":        "id",
+	"in":           "id",
+	"out":          "id",
+	"fields":       "name",
+	"requirements": "class",
+	"hints":        "class",
+}
+
+// canonicalDoc canonicalizes a document decoded from JSON. See Hash.
+func canonicalDoc(v interface{}) interface{} {
+	switch z := v.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, x := range z {
+			switch k {
+			case "doc", "label":
+				continue
+			case "default":
+				out[k] = x
+			case "id":
+				if s, ok := x.(string); ok {
+					out[k] = LocalID(s)
+				}
+			case "source", "outputSource":
+				out[k] = trimSources(x)
+			case "type":
+				x = canonicalDoc(x)
+				sortUnion(x)
+				out[k] = x
+			default:
+				x = canonicalDoc(x)
+				if by, ok := sortKeys[k]; ok {
+					sortItems(x, by)
+				}
+				out[k] = x
+			}
+		}
+		return out
+
+	case []interface{}:
+		out := make([]interface{}, len(z))
+		for i, x := range z {
+			out[i] = canonicalDoc(x)
+		}
+		return out
+	}
+	return v
+}
+
+func trimSources(v interface{}) interface{} {
+	switch z := v.(type) {
+	case string:
+		return strings.TrimPrefix(z, "#")
+	case []interface{}:
+		out := make([]interface{}, len(z))
+		for i, x := range z {
+			out[i] = trimSources(x)
+		}
+		return out
+	}
+	return v
+}
+
+// sortItems sorts a list of objects by the string value of field "by".
+func sortItems(v interface{}, by string) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	key := func(x interface{}) string {
+		if m, ok := x.(map[string]interface{}); ok {
+			s, _ := m[by].(string)
+			return s
+		}
+		return ""
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return key(list[i]) < key(list[j])
+	})
+}
+
+// sortUnion sorts the members of a union type by their JSON encoding.
+func sortUnion(v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	key := func(x interface{}) string {
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return key(list[i]) < key(list[j])
+	})
+}
+
+// canonicalValue canonicalizes an input value. See HashValues.
+func canonicalValue(v Value) (interface{}, error) {
+	switch z := v.(type) {
+	case File:
+		return canonicalFile(z)
+	case *File:
+		return canonicalFile(*z)
+	case Directory:
+		return canonicalDirectory(z)
+	case *Directory:
+		return canonicalDirectory(*z)
+
+	case Values:
+		return canonicalValue(map[string]Value(z))
+	case map[string]Value:
+		out := map[string]interface{}{}
+		for k, x := range z {
+			c, err := canonicalValue(x)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", k, err)
+			}
+			out[k] = c
+		}
+		return out, nil
+	case []Value:
+		out := make([]interface{}, len(z))
+		for i, x := range z {
+			c, err := canonicalValue(x)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %s", i, err)
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+
+	// Other values, e.g. []string, are canonicalized through JSON,
+	// which sorts map keys.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(b)
+}
+
+func canonicalFile(f File) (interface{}, error) {
+	checksum := f.Checksum
+	if checksum == "" {
+		var b []byte
+		if f.Contents != "" {
+			b = []byte(f.Contents)
+		} else {
+			p, err := localPath(f.Path, f.Location)
+			if err != nil {
+				return nil, err
+			}
+			b, err = ioutil.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("calculating checksum: %s", err)
+			}
+		}
+		checksum = fmt.Sprintf("sha1$%x", sha1.Sum(b))
+	}
+
+	out := map[string]interface{}{
+		"class":    "File",
+		"basename": baseName(f.Basename, f.Path, f.Location),
+		"checksum": checksum,
+	}
+	if f.Format != "" {
+		out["format"] = f.Format
+	}
+	if len(f.SecondaryFiles) > 0 {
+		sec, err := canonicalListing(f.SecondaryFiles)
+		if err != nil {
+			return nil, err
+		}
+		out["secondaryFiles"] = sec
+	}
+	return out, nil
+}
+
+func canonicalDirectory(d Directory) (interface{}, error) {
+	listing := d.Listing
+	if len(listing) == 0 {
+		p, err := localPath(d.Path, d.Location)
+		if err != nil {
+			return nil, err
+		}
+		infos, err := ioutil.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("listing directory: %s", err)
+		}
+		for _, info := range infos {
+			entry := filepath.Join(p, info.Name())
+			if info.IsDir() {
+				listing = append(listing, Directory{Path: entry})
+			} else {
+				listing = append(listing, File{Path: entry})
+			}
+		}
+	}
+
+	l, err := canonicalListing(listing)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"class":    "Directory",
+		"basename": baseName(d.Basename, d.Path, d.Location),
+		"listing":  l,
+	}, nil
+}
+
+// canonicalListing canonicalizes a list of Files and Directories,
+// sorted by basename.
+func canonicalListing(list []FileDir) ([]interface{}, error) {
+	out := []interface{}{}
+	for _, fd := range list {
+		c, err := canonicalValue(fd)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	sortItems(out, "basename")
+	return out, nil
+}
+
+// localPath returns the local path of a File or Directory,
+// from its path or a "file://" location.
+func localPath(p, location string) (string, error) {
+	if p != "" {
+		return p, nil
+	}
+	if strings.HasPrefix(location, "file://") {
+		return strings.TrimPrefix(location, "file://"), nil
+	}
+	if location != "" && !strings.Contains(location, "://") {
+		return location, nil
+	}
+	return "", fmt.Errorf("can't read %q: no checksum, and not a local file", location)
+}
+
+func baseName(basename, p, location string) string {
+	if basename != "" {
+		return basename
+	}
+	if p != "" {
+		return filepath.Base(p)
+	}
+	return path.Base(location)
+}
@@ -0,0 +1,138 @@
+package cwl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const hashTool = `
+cwlVersion: v1.0
+class: CommandLineTool
+label: Aligns reads
+baseCommand: bwa
+requirements:
+  - class: DockerRequirement
+    dockerPull: biocontainers/bwa:0.7.17
+  - class: InlineJavascriptRequirement
+inputs:
+  reads:
+    type: File?
+    doc: reads to align
+    inputBinding: {prefix: -i}
+  threads:
+    type: int
+    default: {doc: kept}
+outputs:
+  sam: stdout
+`
+
+// hashToolList is hashTool in JSON, with list forms, "#" IDs,
+// different key order, and different docs.
+const hashToolList = `{
+  "class": "CommandLineTool",
+  "cwlVersion": "v1.0",
+  "baseCommand": "bwa",
+  "requirements": {
+    "InlineJavascriptRequirement": {},
+    "DockerRequirement": {"dockerPull": "biocontainers/bwa:0.7.17"}
+  },
+  "outputs": [{"id": "#sam", "type": "stdout"}],
+  "inputs": [
+    {"id": "#threads", "type": "int", "default": {"doc": "kept"}, "label": "Threads"},
+    {"id": "#reads", "type": ["null", "File"], "inputBinding": {"prefix": "-i"}}
+  ]
+}`
+
+func hashString(t *testing.T, src string) string {
+	t.Helper()
+	doc, err := LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := Hash(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHash(t *testing.T) {
+	h := hashString(t, hashTool)
+	if !strings.HasPrefix(h, "sha256:") || len(h) != len("sha256:")+64 {
+		t.Errorf("unexpected hash: %s", h)
+	}
+	if other := hashString(t, hashToolList); other != h {
+		t.Errorf("expected equivalent documents to have the same hash: %s, %s", h, other)
+	}
+
+	for _, change := range [][2]string{
+		{"prefix: -i", "prefix: -r"},
+		{"bwa:0.7.17", "bwa:0.7.18"},
+		{"type: File?", "type: File"},
+		{"default: {doc: kept}", "default: {doc: changed}"},
+	} {
+		src := strings.Replace(hashTool, change[0], change[1], 1)
+		if hashString(t, src) == h {
+			t.Errorf("expected changing %q to %q to change the hash", change[0], change[1])
+		}
+	}
+}
+
+func TestHashValues(t *testing.T) {
+	hash := func(vals Values) string {
+		t.Helper()
+		h, err := HashValues(vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	file := func(loc, checksum string) File {
+		return File{Location: loc, Checksum: checksum}
+	}
+
+	a := hash(Values{"reads": file("/data/a/reads.fq", "sha1$aa"), "n": 1, "opts": map[string]Value{"x": "y", "z": []Value{1, 2}}})
+	b := hash(Values{"opts": map[string]Value{"z": []Value{1, 2}, "x": "y"}, "n": 1, "reads": file("s3://bucket/reads.fq", "sha1$aa")})
+	if a != b {
+		t.Errorf("expected files with the same checksum to have the same hash")
+	}
+	if c := hash(Values{"reads": file("/data/a/reads.fq", "sha1$bb"), "n": 1, "opts": map[string]Value{"x": "y", "z": []Value{1, 2}}}); c == a {
+		t.Errorf("expected different checksums to change the hash")
+	}
+	if c := hash(Values{"reads": file("/data/a/other.fq", "sha1$aa"), "n": 1, "opts": map[string]Value{"x": "y", "z": []Value{1, 2}}}); c == a {
+		t.Errorf("expected a different basename to change the hash")
+	}
+
+	// Files without checksums are read.
+	dir, err := ioutil.TempDir("", "cwl-hash-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "reads.fq")
+	if err := ioutil.WriteFile(p, []byte("ACGT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := hash(Values{"reads": File{Location: "file://" + p}})
+	contents := hash(Values{"reads": File{Basename: "reads.fq", Contents: "ACGT"}})
+	checksum := hash(Values{"reads": File{Location: "reads.fq", Checksum: "sha1$2108994e17f6cca9ff2352ada92b6511db076034"}})
+	if read != contents || read != checksum {
+		t.Errorf("expected the same hash for the file, its contents and its checksum")
+	}
+
+	dirHash := hash(Values{"dir": Directory{Location: dir}})
+	listed := hash(Values{"dir": Directory{Location: "/elsewhere/" + filepath.Base(dir), Listing: []FileDir{
+		File{Basename: "reads.fq", Checksum: "sha1$2108994e17f6cca9ff2352ada92b6511db076034"},
+	}}})
+	if dirHash != listed {
+		t.Errorf("expected the same hash for the directory and its listing")
+	}
+
+	if _, err := HashValues(Values{"reads": File{Location: "https://example.com/reads.fq"}}); err == nil {
+		t.Errorf("expected an error for a remote file without a checksum")
+	}
+}
@@ -0,0 +1,309 @@
+package cwl
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveIDs sets the fully qualified ID (FullID) and the short local name
+// (LocalID) of the processes, inputs, outputs and steps in "doc",
+// and resolves the sources of step inputs and workflow outputs
+// against their enclosing workflow (FullSource, FullOutputSource).
+//
+// For example, in "file:///x/wf.cwl", step "step1" has output
+// "file:///x/wf.cwl#step1/out", which is also the full source of
+// "source: step1/out". In a $graph document, the same output of
+// workflow "#main" is "file:///x/wf.cwl#main/step1/out".
+//
+// "uri" is the URI of the document, which may be empty if unknown.
+// Load calls ResolveIDs, so it only needs to be called for documents
+// which are created or modified in code.
+//
+// Embedded documents which were loaded from a different document,
+// e.g. "run: tool.cwl", keep the IDs resolved against their own document.
+func ResolveIDs(doc Document, uri string) {
+	r := idResolver{uri: uri, graph: map[Document]bool{}}
+
+	switch z := doc.(type) {
+	case Graph:
+		r.graphDocs(z.Docs)
+	case *Graph:
+		r.graphDocs(z.Docs)
+	default:
+		r.process(doc, uri, uri)
+	}
+}
+
+// LocalID returns the short name of a (fully qualified) ID, which is
+// the last part of its fragment, e.g. "out" for "file:///x/wf.cwl#step1/out".
+func LocalID(id string) string {
+	if i := strings.LastIndex(id, "#"); i != -1 {
+		id = id[i+1:]
+	}
+	if i := strings.LastIndex(id, "/"); i != -1 {
+		id = id[i+1:]
+	}
+	return id
+}
+
+type idResolver struct {
+	uri string
+	// graph holds the documents of a $graph, which are only resolved
+	// as members of the graph, not as the "run" of a step.
+	graph map[Document]bool
+}
+
+func (r idResolver) graphDocs(docs []Document) {
+	for _, d := range docs {
+		r.graph[d] = true
+	}
+	for _, d := range docs {
+		r.process(d, r.uri, r.uri)
+	}
+}
+
+// process resolves the IDs of a process, where "scope" is the ID of the
+// enclosing element, and "anon" is the ID used when the process has no ID.
+func (r idResolver) process(doc Document, scope, anon string) {
+	switch z := doc.(type) {
+	case *Tool:
+		z.FullID = processID(scope, z.ID, anon)
+		z.LocalID = LocalID(z.ID)
+		r.params(z.FullID, z.Inputs, z.Outputs)
+
+	case *ExpressionTool:
+		z.FullID = processID(scope, z.ID, anon)
+		z.LocalID = LocalID(z.ID)
+		r.params(z.FullID, z.Inputs, z.Outputs)
+
+	case *Workflow:
+		z.FullID = processID(scope, z.ID, anon)
+		z.LocalID = LocalID(z.ID)
+		wfid := z.FullID
+
+		for i := range z.Inputs {
+			in := &z.Inputs[i]
+			in.FullID = resolveID(wfid, in.ID)
+			in.LocalID = LocalID(in.FullID)
+		}
+		for i := range z.Outputs {
+			out := &z.Outputs[i]
+			out.FullID = resolveID(wfid, out.ID)
+			out.LocalID = LocalID(out.FullID)
+			out.FullOutputSource = resolveSources(wfid, out.OutputSource)
+		}
+
+		for i := range z.Steps {
+			step := &z.Steps[i]
+			step.FullID = resolveID(wfid, step.ID)
+			step.LocalID = LocalID(step.FullID)
+
+			for j := range step.In {
+				in := &step.In[j]
+				in.FullID = resolveID(step.FullID, in.ID)
+				in.LocalID = LocalID(in.FullID)
+				in.FullSource = resolveSources(wfid, in.Source)
+			}
+			for j := range step.Out {
+				out := &step.Out[j]
+				out.FullID = resolveID(step.FullID, out.ID)
+				out.LocalID = LocalID(out.FullID)
+			}
+
+			if r.embedded(step.Run) {
+				r.process(step.Run, step.FullID, step.FullID+"/run")
+			}
+		}
+	}
+}
+
+func (r idResolver) params(scope string, inputs []CommandInput, outputs []CommandOutput) {
+	for i := range inputs {
+		in := &inputs[i]
+		in.FullID = resolveID(scope, in.ID)
+		in.LocalID = LocalID(in.FullID)
+	}
+	for i := range outputs {
+		out := &outputs[i]
+		out.FullID = resolveID(scope, out.ID)
+		out.LocalID = LocalID(out.FullID)
+	}
+}
+
+// embedded returns true if the "run" document of a step should be resolved
+// as part of this document. Documents of a $graph are resolved as members
+// of the graph, and documents loaded from a different document keep their IDs.
+func (r idResolver) embedded(doc Document) bool {
+	id := ""
+	switch z := doc.(type) {
+	case *Tool:
+		id = z.FullID
+	case *ExpressionTool:
+		id = z.FullID
+	case *Workflow:
+		id = z.FullID
+	default:
+		return false
+	}
+	if r.graph[doc] {
+		return false
+	}
+	return id == "" || documentPart(id) == r.uri
+}
+
+func processID(scope, id, anon string) string {
+	if id == "" {
+		return anon
+	}
+	return resolveID(scope, id)
+}
+
+// resolveID resolves "id" against the ID of the enclosing element, "scope".
+// IDs starting with "#" are relative to the document. Absolute URIs
+// are returned unchanged.
+func resolveID(scope, id string) string {
+	switch {
+	case id == "":
+		return ""
+	case strings.HasPrefix(id, "#"):
+		return documentPart(scope) + id
+	case strings.Contains(id, "://"), strings.HasPrefix(id, "_:"):
+		return id
+	case strings.Contains(scope, "#"):
+		return scope + "/" + id
+	default:
+		return scope + "#" + id
+	}
+}
+
+func resolveSources(scope string, sources []string) []string {
+	var out []string
+	for _, src := range sources {
+		out = append(out, resolveID(scope, src))
+	}
+	return out
+}
+
+// documentPart returns the part of an ID before the fragment.
+func documentPart(id string) string {
+	if i := strings.Index(id, "#"); i != -1 {
+		return id[:i]
+	}
+	return id
+}
+
+// splitFragment splits a location such as "tools.cwl#main"
+// into "tools.cwl" and "main".
+func splitFragment(loc string) (string, string) {
+	if i := strings.Index(loc, "#"); i != -1 {
+		return loc[:i], loc[i+1:]
+	}
+	return loc, ""
+}
+
+// documentURI returns the URI of a document location, a file path or URL,
+// loaded by resolver "r". Documents in an fs.FS (see FSResolver) have
+// URIs relative to the root of the file system, e.g. "cwl/wf.cwl".
+func documentURI(loc string, r Resolver) string {
+	if loc == "" {
+		return ""
+	}
+	switch r.(type) {
+	case FSResolver, *FSResolver:
+		return strings.TrimPrefix(path.Clean(filepath.ToSlash(loc)), "/")
+	}
+	// Single letter schemes are probably Windows drive letters.
+	if u, err := url.Parse(loc); err == nil && len(u.Scheme) > 1 {
+		return loc
+	}
+	if abs, err := filepath.Abs(loc); err == nil {
+		loc = abs
+	}
+	return "file://" + filepath.ToSlash(loc)
+}
+
+// selectFragment returns the process identified by fragment "frag",
+// e.g. "main", from a $graph document, or the document itself,
+// if its ID matches.
+func selectFragment(doc Document, frag string) (Document, error) {
+	var docs []Document
+	switch z := doc.(type) {
+	case Graph:
+		docs = z.Docs
+	case *Graph:
+		docs = z.Docs
+	default:
+		docs = []Document{doc}
+	}
+	if d := findProcess(docs, frag); d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("process %q not found", "#"+frag)
+}
+
+// findProcess finds the document with the given ID.
+// IDs are compared without the leading "#".
+func findProcess(docs []Document, id string) Document {
+	id = strings.TrimPrefix(id, "#")
+	for _, d := range docs {
+		if strings.TrimPrefix(processIDOf(d), "#") == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func processIDOf(doc Document) string {
+	switch z := doc.(type) {
+	case *Tool:
+		return z.ID
+	case *ExpressionTool:
+		return z.ID
+	case *Workflow:
+		return z.ID
+	}
+	return ""
+}
+
+// linkGraph replaces the "run: #id" references of the steps in a $graph
+// document with the referenced processes.
+func (l *loader) linkGraph(g Graph) error {
+	var link func(wf *Workflow) error
+	link = func(wf *Workflow) error {
+		for i := range wf.Steps {
+			step := &wf.Steps[i]
+			switch run := step.Run.(type) {
+			case DocumentRef:
+				if !strings.HasPrefix(run.Location, "#") {
+					continue
+				}
+				d := findProcess(g.Docs, run.Location)
+				if d == nil {
+					return &LoadError{
+						Pos: run.Pos,
+						Err: fmt.Errorf("step %q: process %q not found in $graph", step.ID, run.Location),
+						src: l,
+					}
+				}
+				step.Run = d
+			case *Workflow:
+				if err := link(run); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, d := range g.Docs {
+		if wf, ok := d.(*Workflow); ok {
+			if err := link(wf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
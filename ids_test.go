@@ -0,0 +1,118 @@
+package cwl
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const packedDoc = `
+cwlVersion: v1.0
+$graph:
+  - id: echo
+    class: CommandLineTool
+    inputs:
+      msg: string
+    outputs:
+      out: stdout
+  - id: main
+    class: Workflow
+    inputs:
+      - id: "#main/msg"
+        type: string
+    outputs:
+      - id: "#main/out"
+        type: File
+        outputSource: "#main/step1/out"
+    steps:
+      - id: "#main/step1"
+        run: "#echo"
+        in:
+          - id: "#main/step1/msg"
+            source: "#main/msg"
+        out: ["#main/step1/out"]
+`
+
+func TestResolveIDs(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(positionsDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*Workflow)
+
+	expect := func(name, got, want string) {
+		if got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+
+	expect("input", wf.Inputs[0].FullID, "#reads")
+	step := wf.Steps[0]
+	expect("step", step.FullID, "#align")
+	expect("step local ID", step.LocalID, "align")
+	expect("step input", step.In[0].FullID, "#align/reads")
+	expect("step input local ID", step.In[0].LocalID, "reads")
+	expect("step input source", step.In[0].FullSource[0], "#reads")
+
+	tool := step.Run.(*Tool)
+	expect("embedded tool", tool.FullID, "#align/run")
+	expect("embedded tool input", tool.Inputs[0].FullID, "#align/run/reads")
+}
+
+func TestGraphFragments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"packed.cwl": packedDoc})
+	path := filepath.Join(dir, "packed.cwl")
+	uri := "file://" + filepath.ToSlash(path)
+
+	doc, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := doc.(Graph)
+	echo := g.Docs[0].(*Tool)
+	wf := g.Docs[1].(*Workflow)
+
+	if wf.Steps[0].Run != Document(echo) {
+		t.Errorf(`expected "run: #echo" to resolve to the echo tool, got %#v`, wf.Steps[0].Run)
+	}
+
+	expect := func(name, got, want string) {
+		if got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+	expect("tool", echo.FullID, uri+"#echo")
+	expect("tool input", echo.Inputs[0].FullID, uri+"#echo/msg")
+	expect("workflow", wf.FullID, uri+"#main")
+	expect("workflow output source", wf.Outputs[0].FullOutputSource[0], uri+"#main/step1/out")
+	expect("step output", wf.Steps[0].Out[0].FullID, uri+"#main/step1/out")
+	expect("step output local ID", wf.Steps[0].Out[0].LocalID, "out")
+	expect("step input source", wf.Steps[0].In[0].FullSource[0], uri+"#main/msg")
+
+	// The reference is kept when marshaling.
+	b, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"run":"#echo"`) {
+		t.Errorf("expected step to refer to #echo:\n%s", b)
+	}
+
+	// A fragment selects a process from the graph.
+	doc, err = Load(path + "#main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main, ok := doc.(*Workflow); !ok || main.ID != "main" {
+		t.Errorf("expected the main workflow, got %#v", doc)
+	}
+}
+
+func TestGraphMissingFragment(t *testing.T) {
+	doc := strings.Replace(packedDoc, `run: "#echo"`, `run: "#missing"`, 1)
+	_, err := LoadDocumentBytes([]byte(doc), "", nil)
+	if err == nil || !strings.Contains(err.Error(), `"#missing" not found`) {
+		t.Errorf("expected missing process error, got %v", err)
+	}
+}
@@ -1,7 +1,11 @@
 package cwl
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"github.com/commondream/yamlast"
+	"strings"
 )
 
 // A bunch of tedious wrappers for fields like "class" and "type"
@@ -54,18 +58,26 @@ func (i OutputRecord) MarshalJSON() ([]byte, error) {
 }
 func (x Workflow) MarshalJSON() ([]byte, error) {
 	type Wrap Workflow
-	return json.Marshal(struct {
+	b, err := json.Marshal(struct {
 		Class string `json:"class"`
 		Wrap
 	}{"Workflow", Wrap(x)})
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtensions(b, x.Extensions)
 }
 
 func (x Tool) MarshalJSON() ([]byte, error) {
 	type Wrap Tool
-	return json.Marshal(struct {
+	b, err := json.Marshal(struct {
 		Class string `json:"class"`
 		Wrap
 	}{"CommandLineTool", Wrap(x)})
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtensions(b, x.Extensions)
 }
 
 func (x DockerRequirement) MarshalJSON() ([]byte, error) {
@@ -124,6 +136,38 @@ func (x InitialWorkDirRequirement) MarshalJSON() ([]byte, error) {
 		Wrap
 	}{"InitialWorkDirRequirement", Wrap(x)})
 }
+func (x InitialWorkDirListing) MarshalJSON() ([]byte, error) {
+	if x.Expression != "" {
+		return json.Marshal(x.Expression)
+	}
+	return json.Marshal(x.Entries)
+}
+
+// MarshalJSON marshals a pattern without "required" as a string,
+// e.g. ".bai", which is the only form allowed by CWL v1.0.
+func (x SecondaryFileSchema) MarshalJSON() ([]byte, error) {
+	if x.Required == "" {
+		return json.Marshal(x.Pattern)
+	}
+	type Wrap SecondaryFileSchema
+	return json.Marshal(Wrap(x))
+}
+
+// MarshalYAML marshals the listing via JSON, so that the File and Directory
+// entries keep their "class" field.
+func (x InitialWorkDirListing) MarshalYAML() (interface{}, error) {
+	if x.Expression != "" {
+		return string(x.Expression), nil
+	}
+	b, err := json.Marshal(x.Entries)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
 func (x SubworkflowFeatureRequirement) MarshalJSON() ([]byte, error) {
 	type Wrap SubworkflowFeatureRequirement
 	return json.Marshal(struct {
@@ -152,3 +196,298 @@ func (x StepInputExpressionRequirement) MarshalJSON() ([]byte, error) {
 		Wrap
 	}{"StepInputExpressionRequirement", Wrap(x)})
 }
+func (x ToolTimeLimit) MarshalJSON() ([]byte, error) {
+	type Wrap ToolTimeLimit
+	return json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+	}{"ToolTimeLimit", Wrap(x)})
+}
+func (x WorkReuse) MarshalJSON() ([]byte, error) {
+	type Wrap WorkReuse
+	return json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+	}{"WorkReuse", Wrap(x)})
+}
+func (x NetworkAccess) MarshalJSON() ([]byte, error) {
+	type Wrap NetworkAccess
+	// networkAccess is a boolean, unless it's an expression.
+	var access interface{} = x.NetworkAccess
+	switch x.NetworkAccess {
+	case "true":
+		access = true
+	case "false":
+		access = false
+	}
+	return json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+		NetworkAccess interface{} `json:"networkAccess,omitempty"`
+	}{"NetworkAccess", Wrap(x), access})
+}
+func (x InplaceUpdateRequirement) MarshalJSON() ([]byte, error) {
+	type Wrap InplaceUpdateRequirement
+	return json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+	}{"InplaceUpdateRequirement", Wrap(x)})
+}
+func (x LoadListingRequirement) MarshalJSON() ([]byte, error) {
+	type Wrap LoadListingRequirement
+	return json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+	}{"LoadListingRequirement", Wrap(x)})
+}
+func (x ExpressionTool) MarshalJSON() ([]byte, error) {
+	type Wrap ExpressionTool
+	b, err := json.Marshal(struct {
+		Class string `json:"class"`
+		Wrap
+	}{"ExpressionTool", Wrap(x)})
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtensions(b, x.Extensions)
+}
+func (x UnknownRequirement) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(struct {
+		Class string `json:"class"`
+	}{x.Name})
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtensions(b, x.Extensions)
+}
+func (x Step) MarshalJSON() ([]byte, error) {
+	type Wrap Step
+	b, err := json.Marshal(Wrap(x))
+	if err != nil {
+		return nil, err
+	}
+	return marshalExtensions(b, x.Extensions)
+}
+func (i InputEnum) MarshalJSON() ([]byte, error) {
+	type Wrap InputEnum
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Wrap
+	}{"enum", Wrap(i)})
+}
+func (i OutputEnum) MarshalJSON() ([]byte, error) {
+	type Wrap OutputEnum
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Wrap
+	}{"enum", Wrap(i)})
+}
+
+// MarshalJSON marshals the schema def as its type, with a "name" field added,
+// e.g. {"name": "Stage", "type": "record", "fields": [...]}
+func (x SchemaDef) MarshalJSON() ([]byte, error) {
+	name, err := json.Marshal(x.Name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := json.Marshal(x.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(t) < 2 || t[0] != '{' {
+		return nil, fmt.Errorf("schema def %s: type must marshal to an object", x.Name)
+	}
+	b := append([]byte(`{"name":`), name...)
+	return joinObjects(append(b, '}'), t), nil
+}
+
+// marshalExtensions adds the extension fields "ext" to the JSON object "b".
+func marshalExtensions(b []byte, ext map[string]Value) ([]byte, error) {
+	if len(ext) == 0 {
+		return b, nil
+	}
+	e, err := json.Marshal(ext)
+	if err != nil {
+		return nil, err
+	}
+	return joinObjects(b, e), nil
+}
+
+// joinObjects joins the fields of two marshaled JSON objects.
+func joinObjects(a, b []byte) []byte {
+	a = bytes.TrimSpace(a)
+	b = bytes.TrimSpace(b)
+	if len(b) <= 2 {
+		return a
+	}
+	if len(a) <= 2 {
+		return b
+	}
+	out := append([]byte{}, a[:len(a)-1]...)
+	out = append(out, ',')
+	return append(out, b[1:]...)
+}
+
+// MarshalJSON omits the "separate" and "shellQuote" fields unless they
+// were set explicitly.
+func (x CommandLineBinding) MarshalJSON() ([]byte, error) {
+	type Wrap CommandLineBinding
+	return json.Marshal(struct {
+		Wrap
+		Separate   *bool `json:"separate,omitempty"`
+		ShellQuote *bool `json:"shellQuote,omitempty"`
+	}{Wrap(x), x.Separate.ptr(), x.ShellQuote.ptr()})
+}
+
+func (o OptOut) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value())
+}
+
+// UnmarshalJSON implementations use the document loader, so that documents
+// can be decoded with encoding/json. The loader handles the union types,
+// shortcuts, etc. which encoding/json can't.
+//
+// References to other documents are not resolved.
+
+func (x *Tool) UnmarshalJSON(b []byte) error {
+	d, err := unmarshalDocument(b)
+	if err != nil {
+		return err
+	}
+	t, ok := d.(*Tool)
+	if !ok {
+		return fmt.Errorf("expected CommandLineTool, got %s", d.Doctype())
+	}
+	*x = *t
+	return nil
+}
+func (x *Workflow) UnmarshalJSON(b []byte) error {
+	d, err := unmarshalDocument(b)
+	if err != nil {
+		return err
+	}
+	wf, ok := d.(*Workflow)
+	if !ok {
+		return fmt.Errorf("expected Workflow, got %s", d.Doctype())
+	}
+	*x = *wf
+	return nil
+}
+func (x *ExpressionTool) UnmarshalJSON(b []byte) error {
+	d, err := unmarshalDocument(b)
+	if err != nil {
+		return err
+	}
+	t, ok := d.(*ExpressionTool)
+	if !ok {
+		return fmt.Errorf("expected ExpressionTool, got %s", d.Doctype())
+	}
+	*x = *t
+	return nil
+}
+func (x *Graph) UnmarshalJSON(b []byte) error {
+	d, err := unmarshalDocument(b)
+	if err != nil {
+		return err
+	}
+	g, ok := d.(Graph)
+	if !ok {
+		return fmt.Errorf("expected $graph, got %s", d.Doctype())
+	}
+	*x = g
+	return nil
+}
+
+func (x *File) UnmarshalJSON(b []byte) error                        { return unmarshalJSON(b, x) }
+func (x *Directory) UnmarshalJSON(b []byte) error                   { return unmarshalJSON(b, x) }
+func (x *InputRecord) UnmarshalJSON(b []byte) error                 { return unmarshalJSON(b, x) }
+func (x *InputEnum) UnmarshalJSON(b []byte) error                   { return unmarshalJSON(b, x) }
+func (x *InputArray) UnmarshalJSON(b []byte) error                  { return unmarshalJSON(b, x) }
+func (x *OutputRecord) UnmarshalJSON(b []byte) error                { return unmarshalJSON(b, x) }
+func (x *OutputEnum) UnmarshalJSON(b []byte) error                  { return unmarshalJSON(b, x) }
+func (x *OutputArray) UnmarshalJSON(b []byte) error                 { return unmarshalJSON(b, x) }
+func (x *SchemaDef) UnmarshalJSON(b []byte) error                   { return unmarshalJSON(b, x) }
+func (x *CommandLineBinding) UnmarshalJSON(b []byte) error          { return unmarshalJSON(b, x) }
+func (x *OptOut) UnmarshalJSON(b []byte) error                      { return unmarshalJSON(b, x) }
+func (x *DockerRequirement) UnmarshalJSON(b []byte) error           { return unmarshalJSON(b, x) }
+func (x *ResourceRequirement) UnmarshalJSON(b []byte) error         { return unmarshalJSON(b, x) }
+func (x *EnvVarRequirement) UnmarshalJSON(b []byte) error           { return unmarshalJSON(b, x) }
+func (x *SchemaDefRequirement) UnmarshalJSON(b []byte) error        { return unmarshalJSON(b, x) }
+func (x *ShellCommandRequirement) UnmarshalJSON(b []byte) error     { return unmarshalJSON(b, x) }
+func (x *InlineJavascriptRequirement) UnmarshalJSON(b []byte) error { return unmarshalJSON(b, x) }
+func (x *SoftwareRequirement) UnmarshalJSON(b []byte) error         { return unmarshalJSON(b, x) }
+func (x *InitialWorkDirRequirement) UnmarshalJSON(b []byte) error   { return unmarshalJSON(b, x) }
+func (x *InitialWorkDirListing) UnmarshalJSON(b []byte) error       { return unmarshalJSON(b, x) }
+func (x *ToolTimeLimit) UnmarshalJSON(b []byte) error               { return unmarshalJSON(b, x) }
+func (x *WorkReuse) UnmarshalJSON(b []byte) error                   { return unmarshalJSON(b, x) }
+func (x *NetworkAccess) UnmarshalJSON(b []byte) error               { return unmarshalJSON(b, x) }
+func (x *InplaceUpdateRequirement) UnmarshalJSON(b []byte) error    { return unmarshalJSON(b, x) }
+func (x *LoadListingRequirement) UnmarshalJSON(b []byte) error      { return unmarshalJSON(b, x) }
+
+func unmarshalDocument(b []byte) (Document, error) {
+	return loadDocumentBytes(b, "", "", NoResolve(), newLoadOptions(nil))
+}
+
+// unmarshalJSON loads JSON bytes into "t", which must be a pointer.
+func unmarshalJSON(b []byte, t interface{}) error {
+	l := loader{resolver: NoResolve(), opts: newLoadOptions(nil)}
+	yamlnode, err := yamlast.Parse(b)
+	if err != nil {
+		return err
+	}
+	if yamlnode == nil || len(yamlnode.Children) != 1 {
+		return fmt.Errorf("unexpected JSON")
+	}
+	n := node(yamlnode.Children[0])
+	l.index(n, "")
+	return l.load(n, t)
+}
+
+// MarshalJSON marshals the steps which run other processes of the graph
+// as references, e.g. "run: #main". See linkGraph.
+func (g Graph) MarshalJSON() ([]byte, error) {
+	members := map[Document]bool{}
+	for _, d := range g.Docs {
+		if isProcess(d) {
+			members[d] = true
+		}
+	}
+
+	type Wrap Graph
+	w := Wrap(g)
+	w.Docs = nil
+	for _, d := range g.Docs {
+		if wf, ok := d.(*Workflow); ok {
+			d = graphRefs(wf, members)
+		}
+		w.Docs = append(w.Docs, d)
+	}
+	return json.Marshal(w)
+}
+
+// graphRefs returns a copy of the workflow where the steps which run
+// a member of the graph refer to it by ID.
+func graphRefs(wf *Workflow, members map[Document]bool) *Workflow {
+	c := *wf
+	c.Steps = append([]Step(nil), wf.Steps...)
+	for i, step := range c.Steps {
+		if !isProcess(step.Run) {
+			continue
+		}
+		if members[step.Run] {
+			id := "#" + strings.TrimPrefix(processIDOf(step.Run), "#")
+			c.Steps[i].Run = DocumentRef{Location: id}
+		} else if sub, ok := step.Run.(*Workflow); ok {
+			c.Steps[i].Run = graphRefs(sub, members)
+		}
+	}
+	return &c
+}
+
+func isProcess(d Document) bool {
+	switch d.(type) {
+	case *Tool, *Workflow, *ExpressionTool:
+		return true
+	}
+	return false
+}
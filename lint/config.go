@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+	"github.com/go-yaml/yaml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ConfigFile is the name of the config file found by FindConfig.
+const ConfigFile = ".cwl-lint.yml"
+
+// Off is the severity of disabled rules.
+const Off cwl.Severity = "off"
+
+// Config configures the rules used by Lint. A config file looks like:
+//
+//	rules:
+//	  input-doc: off
+//	  file-format: false
+//	  docker-tag: error
+//
+// where a rule is disabled by "off" or false, enabled with its default
+// severity (a warning) by true, or enabled with the given severity
+// by "warning" or "error". Rules which aren't listed are enabled.
+type Config struct {
+	// Rules maps rule names to their severity, or Off.
+	Rules map[string]cwl.Severity
+}
+
+func (c Config) severity(rule string) cwl.Severity {
+	if s, ok := c.Rules[rule]; ok {
+		return s
+	}
+	return cwl.SeverityWarning
+}
+
+// ParseConfig parses a config file. Unknown rules and severities are errors,
+// so that typos don't silently leave a rule enabled.
+func ParseConfig(b []byte) (Config, error) {
+	var raw struct {
+		Rules yaml.MapSlice `yaml:"rules"`
+	}
+	if err := yaml.UnmarshalStrict(b, &raw); err != nil {
+		return Config{}, err
+	}
+
+	conf := Config{Rules: map[string]cwl.Severity{}}
+	for _, item := range raw.Rules {
+		name := fmt.Sprint(item.Key)
+		if findRule(name) == nil {
+			return Config{}, fmt.Errorf("unknown rule %q", name)
+		}
+
+		switch v := item.Value.(type) {
+		case bool:
+			conf.Rules[name] = Off
+			if v {
+				conf.Rules[name] = cwl.SeverityWarning
+			}
+		case string:
+			switch s := cwl.Severity(v); s {
+			case Off, cwl.SeverityWarning, cwl.SeverityError:
+				conf.Rules[name] = s
+			default:
+				return Config{}, fmt.Errorf("rule %q: unknown severity %q", name, v)
+			}
+		default:
+			return Config{}, fmt.Errorf("rule %q: unknown severity %v", name, item.Value)
+		}
+	}
+	return conf, nil
+}
+
+// LoadConfig loads a config file.
+func LoadConfig(path string) (Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	conf, err := ParseConfig(b)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %s", path, err)
+	}
+	return conf, nil
+}
+
+// FindConfig looks for a config file (ConfigFile) in "dir" and its parents,
+// stopping at the root of the repository, the first directory containing
+// ".git". It returns an empty path if there's no config file.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,99 @@
+// Package lint checks CWL documents against best practices, such as
+// documenting inputs and pinning Docker images. Unlike cwl.Validate,
+// which finds documents which are invalid or won't run, the rules of
+// the linter are opinions, which can be enabled or disabled per
+// repository with a config file. See Config.
+package lint
+
+import (
+	"fmt"
+	"github.com/buchanae/cwl"
+)
+
+// Rule describes a lint rule.
+type Rule struct {
+	// Name identifies the rule in findings and config files,
+	// e.g. "docker-tag".
+	Name string
+	// Description describes what the rule checks.
+	Description string
+	// Check checks a process, a *cwl.Tool, *cwl.ExpressionTool or
+	// *cwl.Workflow, calling "report" for each finding. The documents
+	// run by the steps of a workflow are checked separately.
+	Check func(doc cwl.Document, report Reporter)
+}
+
+// Reporter records a finding of a rule at the given position.
+type Reporter func(pos cwl.Position, format string, args ...interface{})
+
+// Lint checks "doc" with the rules enabled by "conf", including the
+// documents run by steps and the members of a $graph. Findings are
+// reported with the rule's severity from the config, which is a warning
+// by default.
+func Lint(doc cwl.Document, conf Config) []cwl.Diagnostic {
+	l := linter{conf: conf, seen: map[cwl.Document]bool{}}
+	switch z := doc.(type) {
+	case cwl.Graph:
+		for _, d := range z.Docs {
+			l.process(d)
+		}
+	case *cwl.Graph:
+		for _, d := range z.Docs {
+			l.process(d)
+		}
+	default:
+		l.process(doc)
+	}
+	return l.diags
+}
+
+type linter struct {
+	conf  Config
+	diags []cwl.Diagnostic
+	// seen holds the processes which have been checked, since the same
+	// process may be run by multiple steps.
+	seen map[cwl.Document]bool
+}
+
+func (l *linter) process(doc cwl.Document) {
+	var pos cwl.Position
+	var steps []cwl.Step
+
+	switch z := doc.(type) {
+	case *cwl.Tool:
+		pos = z.Pos
+	case *cwl.ExpressionTool:
+		pos = z.Pos
+	case *cwl.Workflow:
+		pos, steps = z.Pos, z.Steps
+	default:
+		return
+	}
+	if l.seen[doc] {
+		return
+	}
+	l.seen[doc] = true
+
+	for _, rule := range Rules {
+		sev := l.conf.severity(rule.Name)
+		if sev == Off {
+			continue
+		}
+		name := rule.Name
+		rule.Check(doc, func(p cwl.Position, format string, args ...interface{}) {
+			if !p.IsValid() {
+				p = pos
+			}
+			l.diags = append(l.diags, cwl.Diagnostic{
+				Severity: sev,
+				Rule:     name,
+				Message:  fmt.Sprintf(format, args...),
+				Pos:      p,
+			})
+		})
+	}
+
+	for _, step := range steps {
+		l.process(step.Run)
+	}
+}
@@ -0,0 +1,194 @@
+package lint
+
+import (
+	"github.com/buchanae/cwl"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func lintString(t *testing.T, src string, conf Config) []cwl.Diagnostic {
+	t.Helper()
+	doc, err := cwl.LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Lint(doc, conf)
+}
+
+// only enables the given rule.
+func only(rule string) Config {
+	conf := Config{Rules: map[string]cwl.Severity{}}
+	for _, r := range Rules {
+		if r.Name != rule {
+			conf.Rules[r.Name] = Off
+		}
+	}
+	return conf
+}
+
+func expectFindings(t *testing.T, diags []cwl.Diagnostic, expect ...string) {
+	t.Helper()
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Message)
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+const lintTool = `
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: bwa
+hints:
+  - class: DockerRequirement
+    dockerPull: biocontainers/bwa
+requirements:
+  - class: ShellCommandRequirement
+  - class: DockerRequirement
+    dockerPull: quay.io:443/org/bwa:latest
+  - class: InlineJavascriptRequirement
+    expressionLib:
+      - "function prefix() { return inputs.prefix; }"
+inputs:
+  reads:
+    type: File
+    doc: reads to align
+    format: edam:format_1930
+    inputBinding:
+      shellQuote: false
+  index:
+    type: File[]
+    label: index files
+  threads:
+    type: int
+    doc: number of threads
+  prefix:
+    type: string
+    doc: output prefix
+  unused:
+    type: string?
+arguments:
+  - valueFrom: $(inputs['threads'])
+outputs: []
+`
+
+func TestRules(t *testing.T) {
+	expectFindings(t, lintString(t, lintTool, only("input-doc")),
+		`input "unused" has no doc or label`)
+
+	expectFindings(t, lintString(t, lintTool, only("unused-input")),
+		`input "index" is never used`,
+		`input "unused" is never used`)
+
+	expectFindings(t, lintString(t, lintTool, only("docker-hint")),
+		`DockerRequirement is a hint, so the process may run without the container; make it a requirement`)
+
+	expectFindings(t, lintString(t, lintTool, only("docker-tag")),
+		`dockerPull image "quay.io:443/org/bwa:latest" uses the "latest" tag; pin it to a version or digest`,
+		`dockerPull image "biocontainers/bwa" has no tag, so it uses "latest"; pin it to a version or digest`)
+
+	expectFindings(t, lintString(t, lintTool, only("shell-quote")),
+		`input "reads" is added to the shell command without quoting`)
+
+	expectFindings(t, lintString(t, lintTool, only("file-format")),
+		`File input "index" has no format`)
+}
+
+func TestUnusedWorkflowInput(t *testing.T) {
+	diags := lintString(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  reads: File
+  extra: File
+outputs:
+  out:
+    type: File
+    outputSource: "#reads"
+steps: []
+`, only("unused-input"))
+
+	expectFindings(t, diags, `input "extra" is never used`)
+	if d := diags[0]; d.Pos.Line != 6 || d.Severity != cwl.SeverityWarning || d.Rule != "unused-input" {
+		t.Errorf("unexpected diagnostic: %s", d)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	conf, err := ParseConfig([]byte(`
+rules:
+  input-doc: off
+  file-format: false
+  docker-tag: error
+  docker-hint: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := lintString(t, lintTool, conf)
+	for _, d := range diags {
+		switch d.Rule {
+		case "input-doc", "file-format":
+			t.Errorf("unexpected finding of disabled rule: %s", d)
+		case "docker-tag":
+			if d.Severity != cwl.SeverityError {
+				t.Errorf("expected error, got %s", d)
+			}
+		default:
+			if d.Severity != cwl.SeverityWarning {
+				t.Errorf("expected warning, got %s", d)
+			}
+		}
+	}
+
+	for _, src := range []string{
+		"rules:\n  input-docs: off\n",
+		"rules:\n  input-doc: fatal\n",
+		"rule:\n  input-doc: off\n",
+	} {
+		if _, err := ParseConfig([]byte(src)); err == nil {
+			t.Errorf("expected error for config %q", src)
+		}
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cwl-lint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tools := filepath.Join(dir, "repo", "tools")
+	if err := os.MkdirAll(tools, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "repo", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Outside of the repository, so it isn't found.
+	outside := filepath.Join(dir, ConfigFile)
+	if err := ioutil.WriteFile(outside, []byte("rules: {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := FindConfig(tools)
+	if err != nil || path != "" {
+		t.Errorf("expected no config, got %q, %v", path, err)
+	}
+
+	inside := filepath.Join(dir, "repo", ConfigFile)
+	if err := ioutil.WriteFile(inside, []byte("rules: {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err = FindConfig(tools)
+	if err != nil || path != inside {
+		t.Errorf("expected %q, got %q, %v", inside, path, err)
+	}
+}
@@ -0,0 +1,323 @@
+package lint
+
+import (
+	"github.com/buchanae/cwl"
+	"regexp"
+	"strings"
+)
+
+// Rules lists the rules checked by Lint, in order.
+var Rules = []Rule{
+	{
+		Name:        "input-doc",
+		Description: "inputs should have a doc or label",
+		Check:       checkInputDoc,
+	},
+	{
+		Name:        "unused-input",
+		Description: "inputs should be used by a binding, an expression or a step",
+		Check:       checkUnusedInput,
+	},
+	{
+		Name:        "docker-hint",
+		Description: "DockerRequirement should be a requirement, since tools may run without the container when it's a hint",
+		Check:       checkDockerHint,
+	},
+	{
+		Name:        "docker-tag",
+		Description: "dockerPull images should be pinned to a tag other than \"latest\", or a digest",
+		Check:       checkDockerTag,
+	},
+	{
+		Name:        "shell-quote",
+		Description: "inputs shouldn't be added to shell commands without quoting",
+		Check:       checkShellQuote,
+	},
+	{
+		Name:        "file-format",
+		Description: "File inputs should have a format",
+		Check:       checkFileFormat,
+	},
+}
+
+func findRule(name string) *Rule {
+	for i := range Rules {
+		if Rules[i].Name == name {
+			return &Rules[i]
+		}
+	}
+	return nil
+}
+
+// input holds the fields of the inputs of any process
+// which are used by the rules.
+type input struct {
+	id, doc, label string
+	types          []cwl.InputType
+	binding        *cwl.CommandLineBinding
+	format         []cwl.Expression
+	pos            cwl.Position
+}
+
+func inputsOf(doc cwl.Document) []input {
+	var out []input
+	switch z := doc.(type) {
+	case *cwl.Tool:
+		for _, in := range z.Inputs {
+			out = append(out, input{in.ID, in.Doc, in.Label, in.Type, in.InputBinding, in.Format, in.Pos})
+		}
+	case *cwl.ExpressionTool:
+		for _, in := range z.Inputs {
+			out = append(out, input{in.ID, in.Doc, in.Label, in.Type, in.InputBinding, in.Format, in.Pos})
+		}
+	case *cwl.Workflow:
+		for _, in := range z.Inputs {
+			out = append(out, input{in.ID, in.Doc, in.Label, in.Type, in.InputBinding, in.Format, in.Pos})
+		}
+	}
+	return out
+}
+
+func requirementsOf(doc cwl.Document) (reqs, hints []cwl.Requirement) {
+	switch z := doc.(type) {
+	case *cwl.Tool:
+		return z.Requirements, z.Hints
+	case *cwl.ExpressionTool:
+		return z.Requirements, z.Hints
+	case *cwl.Workflow:
+		return z.Requirements, z.Hints
+	}
+	return nil, nil
+}
+
+func checkInputDoc(doc cwl.Document, report Reporter) {
+	for _, in := range inputsOf(doc) {
+		if in.doc == "" && in.label == "" {
+			report(in.pos, "input %q has no doc or label", cwl.LocalID(in.id))
+		}
+	}
+}
+
+func checkUnusedInput(doc cwl.Document, report Reporter) {
+	var used func(in input) bool
+
+	switch z := doc.(type) {
+	case *cwl.Tool, *cwl.ExpressionTool:
+		exprs := expressions(z)
+		used = func(in input) bool {
+			return isBound(in.binding, in.types) || referenced(exprs, cwl.LocalID(in.id))
+		}
+
+	case *cwl.Workflow:
+		sources := map[string]bool{}
+		add := func(src, full []string) {
+			for i, s := range src {
+				sources[strings.TrimPrefix(s, "#")] = true
+				if i < len(full) {
+					sources[full[i]] = true
+				}
+			}
+		}
+		for _, step := range z.Steps {
+			for _, in := range step.In {
+				add(in.Source, in.FullSource)
+			}
+		}
+		for _, out := range z.Outputs {
+			add(out.OutputSource, out.FullOutputSource)
+		}
+		full := map[string]string{}
+		for _, in := range z.Inputs {
+			full[in.ID] = in.FullID
+		}
+		used = func(in input) bool {
+			return sources[strings.TrimPrefix(in.id, "#")] || (full[in.id] != "" && sources[full[in.id]])
+		}
+
+	default:
+		return
+	}
+
+	for _, in := range inputsOf(doc) {
+		if !used(in) {
+			report(in.pos, "input %q is never used", cwl.LocalID(in.id))
+		}
+	}
+}
+
+// isBound returns true if an input, or any part of its type,
+// has an input binding.
+func isBound(b *cwl.CommandLineBinding, types []cwl.InputType) bool {
+	if b != nil {
+		return true
+	}
+	for _, t := range types {
+		switch z := t.(type) {
+		case cwl.InputArray:
+			if isBound(z.InputBinding, z.Items) {
+				return true
+			}
+		case cwl.InputEnum:
+			if z.InputBinding != nil {
+				return true
+			}
+		case cwl.InputRecord:
+			for _, f := range z.Fields {
+				if isBound(f.InputBinding, f.Type) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// allInputs matches expressions which use the whole "inputs" object,
+// e.g. "${ return inputs; }", which may use any input.
+var allInputs = regexp.MustCompile(`\binputs\b\s*([^.\[\s]|$)`)
+
+// referenced returns true if any of the expressions refers to input "id",
+// e.g. "$(inputs.id)" or "$(inputs['id'])".
+func referenced(exprs []string, id string) bool {
+	q := regexp.QuoteMeta(id)
+	rx := regexp.MustCompile(`\binputs\s*(\.\s*` + q + `\b|\[\s*['"]` + q + `['"]\s*\])`)
+	for _, e := range exprs {
+		if rx.MatchString(e) || allInputs.MatchString(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// expressions returns the expressions of a tool, and its expression library.
+func expressions(doc cwl.Document) []string {
+	var out []string
+	cwl.Walk(doc, cwl.VisitorFunc(func(c *cwl.Cursor) bool {
+		if e, ok := c.Node().(cwl.Expression); ok {
+			out = append(out, string(e))
+		}
+		return true
+	}))
+
+	reqs, hints := requirementsOf(doc)
+	for _, r := range append(append([]cwl.Requirement{}, reqs...), hints...) {
+		if js, ok := r.(cwl.InlineJavascriptRequirement); ok {
+			out = append(out, js.ExpressionLib...)
+		}
+	}
+	return out
+}
+
+func checkDockerHint(doc cwl.Document, report Reporter) {
+	_, hints := requirementsOf(doc)
+	for _, h := range hints {
+		if d, ok := h.(cwl.DockerRequirement); ok {
+			report(d.Pos, "DockerRequirement is a hint, so the process may run without the container; make it a requirement")
+		}
+	}
+}
+
+func checkDockerTag(doc cwl.Document, report Reporter) {
+	reqs, hints := requirementsOf(doc)
+	for _, r := range append(append([]cwl.Requirement{}, reqs...), hints...) {
+		d, ok := r.(cwl.DockerRequirement)
+		if !ok || d.Pull == "" || strings.Contains(d.Pull, "@") {
+			continue
+		}
+		switch tag := imageTag(d.Pull); tag {
+		case "":
+			report(d.Pos, "dockerPull image %q has no tag, so it uses \"latest\"; pin it to a version or digest", d.Pull)
+		case "latest":
+			report(d.Pos, "dockerPull image %q uses the \"latest\" tag; pin it to a version or digest", d.Pull)
+		}
+	}
+}
+
+// imageTag returns the tag of a Docker image name, e.g. "1.2" for
+// "quay.io/org/image:1.2". The registry may include a port.
+func imageTag(image string) string {
+	name := image
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+func checkShellQuote(doc cwl.Document, report Reporter) {
+	tool, ok := doc.(*cwl.Tool)
+	if !ok {
+		return
+	}
+	shell := false
+	for _, r := range append(append([]cwl.Requirement{}, tool.Requirements...), tool.Hints...) {
+		if _, ok := r.(cwl.ShellCommandRequirement); ok {
+			shell = true
+		}
+	}
+	if !shell {
+		return
+	}
+
+	unquoted := func(b *cwl.CommandLineBinding) bool {
+		return b != nil && b.ShellQuote.IsSet() && !b.ShellQuote.Value()
+	}
+
+	var checkTypes func(id string, pos cwl.Position, types []cwl.InputType)
+	checkTypes = func(id string, pos cwl.Position, types []cwl.InputType) {
+		for _, t := range types {
+			switch z := t.(type) {
+			case cwl.InputArray:
+				if unquoted(z.InputBinding) {
+					report(pos, "input %q is added to the shell command without quoting", id)
+				}
+				checkTypes(id, pos, z.Items)
+			case cwl.InputRecord:
+				for _, f := range z.Fields {
+					if unquoted(f.InputBinding) {
+						report(f.Pos, "field %q of input %q is added to the shell command without quoting", f.Name, id)
+					}
+					checkTypes(id, pos, f.Type)
+				}
+			}
+		}
+	}
+
+	for _, in := range tool.Inputs {
+		id := cwl.LocalID(in.ID)
+		if unquoted(in.InputBinding) {
+			report(in.Pos, "input %q is added to the shell command without quoting", id)
+		}
+		checkTypes(id, in.Pos, in.Type)
+	}
+	for i, arg := range tool.Arguments {
+		if unquoted(arg) && strings.Contains(string(arg.ValueFrom), "inputs") {
+			report(arg.Pos, "argument %d adds inputs to the shell command without quoting", i+1)
+		}
+	}
+}
+
+func checkFileFormat(doc cwl.Document, report Reporter) {
+	for _, in := range inputsOf(doc) {
+		if len(in.format) == 0 && hasFile(in.types) {
+			report(in.pos, "File input %q has no format", cwl.LocalID(in.id))
+		}
+	}
+}
+
+// hasFile returns true if the types include File, or an array of Files.
+func hasFile(types []cwl.InputType) bool {
+	for _, t := range types {
+		switch z := t.(type) {
+		case cwl.FileType:
+			return true
+		case cwl.InputArray:
+			if hasFile(z.Items) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -18,6 +18,21 @@ import (
 type loader struct {
 	base     string
 	resolver Resolver
+	// file is the location of the document being loaded,
+	// used for source positions.
+	file string
+	// paths maps each YAML node to its field path,
+	// e.g. "steps[2].in.reads.source", used for error messages.
+	paths map[node]string
+	// origins records the document of nodes which were loaded
+	// from another document, e.g. by $import.
+	origins map[node]origin
+	// opts holds optional loading behavior, such as strict mode.
+	// opts is nil when loading input values.
+	opts *loadOptions
+	// inGraph is true while loading the documents of a $graph,
+	// where steps may refer to other processes by ID, e.g. "run: #main".
+	inGraph bool
 }
 
 // load is given a YAML node and a destination type,
@@ -75,10 +90,11 @@ func (l *loader) load(n node, t interface{}) error {
 		outv := m.Call([]reflect.Value{nval})
 		errv := outv[1]
 		if !errv.IsNil() {
-			return errv.Interface().(error)
+			return l.errorAt(n, errv.Interface().(error))
 		}
 		resv := outv[0]
 		val.Set(resv)
+		l.setPos(val, n)
 		return nil
 	}
 
@@ -102,7 +118,12 @@ func (l *loader) load(n node, t interface{}) error {
 
 	// Try to automatically load a YAML mapping into a struct.
 	case typ.Kind() == reflect.Struct && n.Kind == yamlast.MappingNode:
-		return l.loadMappingToStruct(n, t)
+		err := l.loadMappingToStruct(n, t)
+		if err != nil {
+			return l.errorAt(n, err)
+		}
+		l.setPos(val, n)
+		return nil
 
 		// Try to automatically load a YAML sequence into a slice type,
 		// without a defined handler.
@@ -116,7 +137,7 @@ func (l *loader) load(n node, t interface{}) error {
 			item := reflect.New(el)
 			err := l.load(c, item.Interface())
 			if err != nil {
-				return err
+				return l.errorAt(n, err)
 			}
 
 			if typ.Elem().Kind() == reflect.Ptr {
@@ -129,7 +150,7 @@ func (l *loader) load(n node, t interface{}) error {
 	}
 
 	// No handler found.
-	return fmt.Errorf("unhandled type at line %d, col %d. looking for %s", n.Line+1, n.Column+1, handlerName)
+	return l.errorAt(n, fmt.Errorf("unhandled type, looking for %s", handlerName))
 }
 
 // loadMappingToStruct essentially unmarshals a YAML mapping
@@ -158,7 +179,7 @@ func (l *loader) loadMappingToStruct(n node, t interface{}) error {
 		name := strings.ToLower(k.Value)
 
 		if _, ok := already[name]; ok {
-			return fmt.Errorf("duplicate field found while loading mapping")
+			return l.errorAt(k, fmt.Errorf("duplicate field %q", k.Value))
 		}
 		already[name] = true
 
@@ -171,8 +192,15 @@ func (l *loader) loadMappingToStruct(n node, t interface{}) error {
 
 			n := f.Name
 			if alt, ok := f.Tag.Lookup("json"); ok {
-				sp := strings.Split(alt, ",")
-				n = sp[0]
+				// An empty JSON name, e.g. `json:",omitempty"`,
+				// means the field name is used.
+				if sp := strings.Split(alt, ","); sp[0] != "" {
+					n = sp[0]
+				}
+			}
+			// Fields such as Pos are never loaded from the document.
+			if n == "-" {
+				continue
 			}
 
 			if strings.ToLower(n) == name {
@@ -183,6 +211,13 @@ func (l *loader) loadMappingToStruct(n node, t interface{}) error {
 		}
 
 		if !found {
+			ok, err := l.loadExtension(val, k, v)
+			if err != nil {
+				return l.errorAt(v, err)
+			}
+			if !ok {
+				l.unknownField(typ, k)
+			}
 			continue
 		}
 
@@ -202,7 +237,7 @@ func (l *loader) loadMappingToStruct(n node, t interface{}) error {
 
 		err := l.load(v, val.Interface())
 		if err != nil {
-			return err
+			return l.errorAt(v, err)
 		}
 	}
 	return nil
@@ -243,3 +278,36 @@ func coerceSet(dest interface{}, val interface{}) error {
 	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(casted))
 	return nil
 }
+
+// loadExtension loads the value of key "k" into the Extensions map of struct
+// "val", if the struct has one and "k" is an extension field,
+// e.g. "sbg:toolkit" or "$namespaces". All fields of an UnknownRequirement
+// are loaded as extensions.
+//
+// Returns false if the field was not loaded.
+func (l *loader) loadExtension(val reflect.Value, k, v node) (bool, error) {
+	ext := val.FieldByName("Extensions")
+	if !ext.IsValid() || ext.Type() != extensionsType {
+		return false, nil
+	}
+
+	_, unknownReq := val.Interface().(UnknownRequirement)
+	if !isExtensionField(k.Value) && !unknownReq {
+		return false, nil
+	}
+	if unknownReq && strings.ToLower(k.Value) == "class" {
+		return true, nil
+	}
+
+	var x Value
+	if err := l.load(v, &x); err != nil {
+		return false, err
+	}
+	if ext.IsNil() {
+		ext.Set(reflect.MakeMap(extensionsType))
+	}
+	ext.SetMapIndex(reflect.ValueOf(k.Value), reflect.ValueOf(&x).Elem())
+	return true, nil
+}
+
+var extensionsType = reflect.TypeOf(map[string]Value{})
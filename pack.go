@@ -0,0 +1,361 @@
+package cwl
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Pack returns a $graph document holding "doc" and every process it
+// references, so that the workflow can be published as a single file.
+//
+// The root process gets the ID "#main". The processes referenced by steps,
+// e.g. "run: tool.cwl", become members of the graph, named after their ID
+// or file name, and the steps refer to them by ID, e.g. "run: #tool".
+// All IDs and sources are rewritten as fragments of their graph member,
+// e.g. "#main/step1/out". Processes embedded in a step stay embedded.
+//
+// "doc" must be loaded with a resolver (e.g. by Load), so that the documents
+// referenced by "run", $import and $mixin have been loaded. References to
+// schema types in other documents, e.g. "types.yml#Sample", become local
+// references, e.g. "#Sample", since the types are imported into the document.
+//
+// Like Upgrade, Pack modifies "doc" and the documents it references in place.
+// The members of the graph must have the same cwlVersion (see Upgrade).
+func Pack(doc Document) (Graph, error) {
+	if !isProcess(doc) {
+		return Graph{}, fmt.Errorf("can't pack document of type %s", doc.Doctype())
+	}
+	ResolveIDs(doc, documentPart(processFullID(doc)))
+
+	p := packer{
+		version: docVersion(doc),
+		byID:    map[string]Document{},
+		member:  map[Document]bool{},
+		name:    map[Document]string{},
+		names:   map[string]bool{},
+	}
+	if err := p.add(doc, "main"); err != nil {
+		return Graph{}, err
+	}
+
+	g := Graph{CWLVersion: p.version}
+	for _, m := range p.members {
+		full, name := processFullID(m), p.name[m]
+		rename := func(scope, id string) string {
+			rest, ok := trimID(id, full)
+			switch {
+			case !ok:
+				return id
+			case rest == "":
+				return "#" + name
+			default:
+				return "#" + name + "/" + rest
+			}
+		}
+		rewriteIDs(m, rename, p.member)
+		setProcessID(m, "#"+name)
+		setVersion(m, "")
+		g.Docs = append(g.Docs, m)
+	}
+	ResolveIDs(g, "")
+	return g, nil
+}
+
+type packer struct {
+	version string
+	members []Document
+	// byID holds the members by their (original) fully qualified ID,
+	// so that a document referenced by multiple steps is packed once.
+	byID   map[string]Document
+	member map[Document]bool
+	// name holds the names of the members, and names the names in use.
+	name  map[Document]string
+	names map[string]bool
+}
+
+func (p *packer) add(doc Document, name string) error {
+	p.members = append(p.members, doc)
+	p.byID[processFullID(doc)] = doc
+	p.member[doc] = true
+	p.name[doc] = name
+	p.names[name] = true
+	return p.process(doc)
+}
+
+// process packs the schema types and steps of a process.
+func (p *packer) process(doc Document) error {
+	packDocTypes(doc)
+
+	wf, ok := doc.(*Workflow)
+	if !ok {
+		return nil
+	}
+
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+		if ref, ok := step.Run.(DocumentRef); ok {
+			return fmt.Errorf("step %q: can't pack unresolved document %q", step.ID, ref.Location)
+		}
+		if !isProcess(step.Run) {
+			return fmt.Errorf("step %q: can't pack document of type %s", step.ID, step.Run.Doctype())
+		}
+
+		full := processFullID(step.Run)
+		if _, ok := trimID(full, step.FullID); ok {
+			// Embedded in the step.
+			if err := p.process(step.Run); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if d, ok := p.byID[full]; ok {
+			step.Run = d
+			continue
+		}
+		if v := docVersion(step.Run); v != "" && v != p.version {
+			return fmt.Errorf("step %q: can't pack %s: cwlVersion %s differs from %s",
+				step.ID, documentPart(full), v, p.version)
+		}
+		if err := p.add(step.Run, p.uniqueName(step.Run)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueName returns the name of a graph member, which is the ID
+// of the process, or the name of its file, e.g. "wc" for "wc.cwl".
+func (p *packer) uniqueName(doc Document) string {
+	base := LocalID(processIDOf(doc))
+	if base == "" {
+		base = path.Base(documentPart(processFullID(doc)))
+		base = strings.TrimSuffix(base, path.Ext(base))
+	}
+	if base == "" || base == "." || base == "/" {
+		base = "process"
+	}
+
+	name := base
+	for i := 2; p.names[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	return name
+}
+
+// Unpack splits a $graph document into one document per process,
+// keyed by file name, e.g. "main.cwl" for the process with ID "#main".
+// Steps which run a member of the graph refer to its file, e.g. "run: tool.cwl",
+// and IDs and sources are made relative to their process again.
+//
+// The documents get the cwlVersion of the graph. Like Pack, Unpack modifies
+// the documents of the graph in place.
+func Unpack(doc Document) (map[string]Document, error) {
+	var g Graph
+	switch z := doc.(type) {
+	case Graph:
+		g = z
+	case *Graph:
+		g = *z
+	default:
+		return nil, fmt.Errorf("can't unpack document of type %s, expected $graph", doc.Doctype())
+	}
+
+	files := map[Document]string{}
+	member := map[Document]bool{}
+	out := map[string]Document{}
+	for _, d := range g.Docs {
+		if !isProcess(d) {
+			return nil, fmt.Errorf("can't unpack $graph member of type %s", d.Doctype())
+		}
+		name := LocalID(processIDOf(d))
+		if name == "" {
+			return nil, fmt.Errorf("can't unpack $graph member without an ID")
+		}
+		file := name + ".cwl"
+		if _, ok := out[file]; ok {
+			return nil, fmt.Errorf("can't unpack $graph: duplicate ID %q", name)
+		}
+		files[d] = file
+		member[d] = true
+		out[file] = d
+	}
+
+	rename := func(scope, id string) string {
+		if rest, ok := trimID(id, scope); ok && rest != "" {
+			return rest
+		}
+		return id
+	}
+	for _, d := range g.Docs {
+		rewriteIDs(d, rename, member)
+	}
+
+	for _, d := range g.Docs {
+		setProcessID(d, "")
+		if docVersion(d) == "" {
+			setVersion(d, g.CWLVersion)
+		}
+		unpackRefs(d, files)
+		ResolveIDs(d, "")
+	}
+	return out, nil
+}
+
+// unpackRefs replaces the steps which run a member of the graph
+// with a reference to the file of the member.
+func unpackRefs(doc Document, files map[Document]string) {
+	wf, ok := doc.(*Workflow)
+	if !ok {
+		return
+	}
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+		if !isProcess(step.Run) {
+			continue
+		}
+		if file, ok := files[step.Run]; ok {
+			step.Run = DocumentRef{Location: file}
+		} else {
+			unpackRefs(step.Run, files)
+		}
+	}
+}
+
+// rewriteIDs sets the IDs of the inputs, outputs and steps of a process,
+// and the sources of a workflow, to rename(scope, id), where "id" is the
+// fully qualified ID and "scope" is the fully qualified ID it's relative to.
+//
+// Processes embedded in steps are rewritten too, except for those in "skip".
+// Their FullIDs are cleared, so that ResolveIDs resolves them again.
+func rewriteIDs(doc Document, rename func(scope, id string) string, skip map[Document]bool) {
+	switch z := doc.(type) {
+	case *Tool:
+		rewriteParamIDs(z.FullID, z.Inputs, z.Outputs, rename)
+
+	case *ExpressionTool:
+		rewriteParamIDs(z.FullID, z.Inputs, z.Outputs, rename)
+
+	case *Workflow:
+		wfid := z.FullID
+		for i := range z.Inputs {
+			in := &z.Inputs[i]
+			in.ID = rename(wfid, in.FullID)
+		}
+		for i := range z.Outputs {
+			out := &z.Outputs[i]
+			out.ID = rename(wfid, out.FullID)
+			out.OutputSource = renameAll(wfid, out.FullOutputSource, rename)
+		}
+
+		for i := range z.Steps {
+			step := &z.Steps[i]
+			step.ID = rename(wfid, step.FullID)
+
+			for j := range step.In {
+				in := &step.In[j]
+				in.ID = rename(step.FullID, in.FullID)
+				in.Source = renameAll(wfid, in.FullSource, rename)
+			}
+			for j := range step.Out {
+				out := &step.Out[j]
+				out.ID = rename(step.FullID, out.FullID)
+			}
+
+			if !isProcess(step.Run) || skip[step.Run] {
+				continue
+			}
+			if processIDOf(step.Run) != "" {
+				setProcessID(step.Run, rename(step.FullID, processFullID(step.Run)))
+			}
+			rewriteIDs(step.Run, rename, skip)
+			setProcessFullID(step.Run, "")
+		}
+	}
+}
+
+func rewriteParamIDs(scope string, inputs []CommandInput, outputs []CommandOutput, rename func(string, string) string) {
+	for i := range inputs {
+		in := &inputs[i]
+		in.ID = rename(scope, in.FullID)
+	}
+	for i := range outputs {
+		out := &outputs[i]
+		out.ID = rename(scope, out.FullID)
+	}
+}
+
+func renameAll(scope string, ids []string, rename func(string, string) string) []string {
+	var out []string
+	for _, id := range ids {
+		out = append(out, rename(scope, id))
+	}
+	return out
+}
+
+// trimID returns the part of "id" following "scope", without the leading
+// "#" or "/", if "id" is "scope" or one of its children.
+func trimID(id, scope string) (string, bool) {
+	if !strings.HasPrefix(id, scope) {
+		return "", false
+	}
+	rest := id[len(scope):]
+	if rest != "" && rest[0] != '#' && rest[0] != '/' {
+		return "", false
+	}
+	return strings.TrimLeft(rest, "#/"), true
+}
+
+// packDocTypes replaces references to schema types in other documents,
+// e.g. "types.yml#Sample", with local references, e.g. "#Sample", in the
+// types of inputs, outputs, record fields and SchemaDefRequirements.
+// The documents run by steps are packed separately.
+func packDocTypes(doc Document) {
+	Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		switch z := c.Node().(type) {
+		case *Step:
+			return false
+		case TypeRef:
+			if j := strings.Index(z.Name, "#"); j > 0 {
+				z.Name = z.Name[j:]
+				c.Replace(z)
+			}
+		}
+		return true
+	}))
+}
+
+func processFullID(doc Document) string {
+	switch z := doc.(type) {
+	case *Tool:
+		return z.FullID
+	case *ExpressionTool:
+		return z.FullID
+	case *Workflow:
+		return z.FullID
+	}
+	return ""
+}
+
+func setProcessFullID(doc Document, id string) {
+	switch z := doc.(type) {
+	case *Tool:
+		z.FullID = id
+	case *ExpressionTool:
+		z.FullID = id
+	case *Workflow:
+		z.FullID = id
+	}
+}
+
+func setProcessID(doc Document, id string) {
+	switch z := doc.(type) {
+	case *Tool:
+		z.ID = id
+	case *ExpressionTool:
+		z.ID = id
+	case *Workflow:
+		z.ID = id
+	}
+}
@@ -0,0 +1,185 @@
+package cwl
+
+import (
+	"github.com/kr/pretty"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var packFiles = map[string]string{
+	"wf.cwl": `
+cwlVersion: v1.0
+class: Workflow
+requirements:
+  - $import: types.yml
+inputs:
+  sample: "types.yml#Sample"
+outputs:
+  out:
+    type: File
+    outputSource: b/out
+  sample_out:
+    type: "types.yml#Sample"
+    outputSource: sample
+steps:
+  a:
+    run: tools/echo.cwl
+    in:
+      msg: sample
+    out: [out]
+  b:
+    run: tools/echo.cwl
+    in:
+      msg: a/out
+    out: [out]
+  c:
+    run:
+      class: CommandLineTool
+      inputs:
+        msg: string
+      outputs:
+        samples:
+          type:
+            type: array
+            items: "types.yml#Sample"
+    in:
+      msg: sample
+    out: []
+`,
+	"tools/echo.cwl": `
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  msg: Any
+outputs:
+  out: stdout
+`,
+	"types.yml": `
+class: SchemaDefRequirement
+types:
+  - name: Sample
+    type: record
+    fields:
+      - name: id
+        type: string
+`,
+}
+
+func TestPack(t *testing.T) {
+	dir := writeFiles(t, packFiles)
+	doc, err := Load(filepath.Join(dir, "wf.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Pack(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalYAML(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err = LoadDocumentBytes(b, "", nil, Strict())
+	if err != nil {
+		t.Fatalf("loading packed document: %s\n%s", err, b)
+	}
+	g = doc.(Graph)
+	if len(g.Docs) != 2 || g.CWLVersion != "v1.0" {
+		t.Fatalf("expected two v1.0 processes in the graph:\n%s", b)
+	}
+	wf := g.Docs[0].(*Workflow)
+	echo := g.Docs[1].(*Tool)
+
+	expect := func(name, got, want string) {
+		if got != want {
+			t.Errorf("expected %s %q, got %q", name, want, got)
+		}
+	}
+	expect("workflow ID", wf.ID, "#main")
+	expect("tool ID", echo.ID, "#echo")
+	expect("tool input", echo.Inputs[0].ID, "#echo/msg")
+	expect("step", wf.Steps[1].ID, "#main/b")
+	expect("step input", wf.Steps[1].In[0].ID, "#main/b/msg")
+	expect("step source", wf.Steps[1].In[0].Source[0], "#main/a/out")
+	expect("output source", wf.Outputs[0].OutputSource[0], "#main/b/out")
+	expect("schema reference", wf.Inputs[0].Type[0].String(), "#Sample")
+
+	if wf.Steps[0].Run != Document(echo) || wf.Steps[1].Run != Document(echo) {
+		t.Errorf("expected both steps to run the echo tool")
+	}
+	inline, ok := wf.Steps[2].Run.(*Tool)
+	if !ok {
+		t.Fatalf("expected the embedded tool to stay embedded, got %#v", wf.Steps[2].Run)
+	}
+	expect("embedded tool input", inline.Inputs[0].ID, "#main/c/run/msg")
+
+	expect("output schema reference", wf.Outputs[1].Type[0].String(), "#Sample")
+	items := inline.Outputs[0].Type[0].(OutputArray).Items
+	expect("embedded output schema reference", items[0].String(), "#Sample")
+}
+
+func TestPackUnresolved(t *testing.T) {
+	dir := writeFiles(t, packFiles)
+	doc, err := LoadWithResolver(filepath.Join(dir, "wf.cwl"), NoResolve())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pack(doc); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// TestUnpack checks that a packed workflow can be unpacked
+// into the original documents.
+func TestUnpack(t *testing.T) {
+	path := "examples/023-count-lines1-wf/tool.cwl"
+	doc, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Pack(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := Unpack(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range docs {
+		names = append(names, name)
+	}
+	if len(names) != 3 || docs["main.cwl"] == nil || docs["wc-tool.cwl"] == nil {
+		t.Fatalf("unexpected documents: %v", names)
+	}
+
+	dir := writeFiles(t, nil)
+	for name, d := range docs {
+		b, err := MarshalYAML(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Load(filepath.Join(dir, "main.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearLocations(&got)
+	clearLocations(&expected)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("unpacked workflow differs:\n%s", pretty.Diff(expected, got))
+	}
+}
@@ -0,0 +1,183 @@
+package cwl
+
+import (
+	"fmt"
+	"github.com/commondream/yamlast"
+	"reflect"
+	"strings"
+)
+
+// Position describes where an element was found in a CWL source document.
+// Line and Column are 1-based. A zero Line means the position is unknown,
+// e.g. the element was created in code instead of being loaded.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String formats the position as "file:line:col", omitting unknown parts.
+func (p Position) String() string {
+	var parts []string
+	if p.File != "" {
+		parts = append(parts, p.File)
+	}
+	if p.Line > 0 {
+		parts = append(parts, fmt.Sprintf("%d:%d", p.Line, p.Column))
+	}
+	return strings.Join(parts, ":")
+}
+
+// IsValid returns true if the position refers to a line in a document.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// LoadError describes an error which occurred while loading a document.
+// Pos and Path locate the YAML node which failed to load, where Path
+// is a field path such as "steps[2].in.reads.source".
+//
+// Errors from nested documents (e.g. a step's "run" file) are wrapped,
+// so Err may itself be a *LoadError.
+type LoadError struct {
+	Pos  Position
+	Path string
+	Err  error
+
+	// the loader which created the error, so that each loader
+	// wraps an error at most once.
+	src *loader
+}
+
+func (e *LoadError) Error() string {
+	var parts []string
+	if s := e.Pos.String(); s != "" {
+		parts = append(parts, s)
+	}
+	if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	parts = append(parts, e.Err.Error())
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns Err, so that errors.As finds the errors of resolvers
+// (e.g. an *HTTPError) through the errors of nested documents.
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// posType is used to find Position fields while loading.
+var posType = reflect.TypeOf(Position{})
+
+// pos returns the position of the given node in the document
+// being loaded, or in the document it was imported from.
+func (l *loader) pos(n node) Position {
+	return Position{
+		File:   l.originOf(n).file,
+		Line:   n.Line + 1,
+		Column: n.Column + 1,
+	}
+}
+
+// errorAt wraps "err" with the position and field path of "n".
+//
+// Nodes created by the loader (e.g. by transformTypeNode) are not indexed,
+// in which case the error is returned unchanged so that the nearest
+// indexed parent will wrap it instead.
+func (l *loader) errorAt(n node, err error) error {
+	if e, ok := err.(*LoadError); ok && e.src == l {
+		return err
+	}
+	path, ok := l.paths[n]
+	if !ok {
+		return err
+	}
+	return &LoadError{Pos: l.pos(n), Path: path, Err: err, src: l}
+}
+
+// fileError wraps an error which applies to the document as a whole,
+// such as a YAML syntax error.
+func (l *loader) fileError(err error) error {
+	return &LoadError{Pos: Position{File: l.file}, Err: err, src: l}
+}
+
+// index records the field path of every node in the tree, e.g.
+// "inputs.reads.type" or "steps[2].in". Map keys are given the
+// same path as their values, so that errors about the key itself
+// (e.g. duplicate fields) point to the key.
+func (l *loader) index(n node, path string) {
+	if l.paths == nil {
+		l.paths = map[node]string{}
+	}
+	l.paths[n] = path
+
+	switch n.Kind {
+	case yamlast.MappingNode:
+		for i := 0; i < len(n.Children)-1; i += 2 {
+			k := n.Children[i]
+			v := n.Children[i+1]
+			p := k.Value
+			if path != "" {
+				p = path + "." + k.Value
+			}
+			l.paths[k] = p
+			l.index(v, p)
+		}
+
+	case yamlast.SequenceNode:
+		for i, c := range n.Children {
+			l.index(c, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// setPos sets the Position field of loaded structs, if they have one and
+// it hasn't been set yet. "v" may be a struct, a pointer to a struct,
+// an interface holding either, or a slice of any of these.
+func (l *loader) setPos(v reflect.Value, n node) {
+	switch v.Kind() {
+
+	case reflect.Ptr:
+		if !v.IsNil() {
+			l.setPos(v.Elem(), n)
+		}
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Ptr {
+			l.setPos(e, n)
+			return
+		}
+		if e.Kind() != reflect.Struct || !v.CanSet() {
+			return
+		}
+		if _, ok := e.Type().FieldByName("Pos"); !ok {
+			return
+		}
+		// Values held by an interface aren't addressable, so set the field
+		// on a copy and store the copy.
+		c := reflect.New(e.Type()).Elem()
+		c.Set(e)
+		l.setPos(c, n)
+		v.Set(c)
+
+	case reflect.Struct:
+		f := v.FieldByName("Pos")
+		if !f.IsValid() || f.Type() != posType || !f.CanSet() {
+			return
+		}
+		if f.Interface().(Position).IsValid() {
+			return
+		}
+		f.Set(reflect.ValueOf(l.pos(n)))
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			l.setPos(v.Index(i), n)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cwl
+
+import (
+	"strings"
+	"testing"
+)
+
+const positionsDoc = `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  reads: File
+outputs: []
+steps:
+  - id: align
+    run:
+      class: CommandLineTool
+      inputs:
+        reads:
+          type: File
+          inputBinding:
+            prefix: -r
+      outputs: []
+    in:
+      reads: reads
+    out: []
+`
+
+func TestLoadPositions(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(positionsDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*Workflow)
+
+	expect := func(name string, pos Position, line, col int) {
+		if pos.Line != line || pos.Column != col {
+			t.Errorf("expected %s at %d:%d, got %s", name, line, col, pos)
+		}
+	}
+
+	expect("workflow", wf.Pos, 2, 1)
+	expect("input", wf.Inputs[0].Pos, 5, 10)
+	expect("step", wf.Steps[0].Pos, 8, 5)
+	expect("step input", wf.Steps[0].In[0].Pos, 18, 14)
+
+	tool := wf.Steps[0].Run.(*Tool)
+	expect("tool", tool.Pos, 10, 7)
+	expect("tool input", tool.Inputs[0].Pos, 13, 11)
+	expect("input binding", tool.Inputs[0].InputBinding.Pos, 15, 13)
+}
+
+func TestLoadErrorPath(t *testing.T) {
+	doc := strings.Replace(positionsDoc, "prefix: -r", "position: foo", 1)
+	_, err := LoadDocumentBytes([]byte(doc), "", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	e, ok := err.(*LoadError)
+	if !ok {
+		t.Fatalf("expected *LoadError, got %T", err)
+	}
+	if e.Path != "steps[0].run.inputs.reads.inputBinding.position" {
+		t.Errorf("unexpected path: %s", e.Path)
+	}
+	if e.Pos.Line != 15 || e.Pos.Column != 23 {
+		t.Errorf("unexpected position: %s", e.Pos)
+	}
+}
+
+func TestLoadErrorDuplicateField(t *testing.T) {
+	doc := strings.Replace(positionsDoc, "outputs: []\nsteps", "outputs: []\nOutputs: []\nsteps", 1)
+	_, err := LoadDocumentBytes([]byte(doc), "", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.HasPrefix(err.Error(), "7:1: Outputs: duplicate field") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
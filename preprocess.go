@@ -2,61 +2,66 @@ package cwl
 
 import (
 	"github.com/commondream/yamlast"
+	"strings"
 )
 
+// importScope describes the document which a node was loaded from,
+// so that references such as $import are resolved relative to the
+// document containing the reference, not the root document.
+type importScope struct {
+	// base is used to resolve references found in this document.
+	base string
+	// stack holds the locations of the documents being imported,
+	// from the root document to this one, used to detect import cycles.
+	stack []string
+}
+
+// origin records the document a YAML node was loaded from.
+// See loader.origins.
+type origin struct {
+	file string
+	base string
+}
+
+// preprocess handles the schema salad directives $import, $include and $mixin,
+// which replace nodes in the tree with (parts of) other documents.
+// Imported documents are preprocessed recursively.
+//
+// http://www.commonwl.org/v1.0/SchemaSalad.html#Document_preprocessing
 func (l *loader) preprocess(n node) (node, error) {
+	s := importScope{base: l.base}
+	if l.file != "" {
+		s.stack = []string{joinLocation("", l.file)}
+	}
+	return l.preprocessIn(n, s)
+}
+
+func (l *loader) preprocessIn(n node, s importScope) (node, error) {
 	switch n.Kind {
 
 	case yamlast.MappingNode:
+		if v, ok := findValue(n, "$import"); ok {
+			return l.importNode(n, v, s)
+		}
+		if v, ok := findValue(n, "$include"); ok {
+			return l.includeNode(n, v, s)
+		}
+
 		for i := 0; i < len(n.Children)-1; i += 2 {
-			k := n.Children[i]
-			v := n.Children[i+1]
-			switch k.Value {
-			case "$import":
-	      if _, ok := l.resolver.(noResolver); ok {
-          return n, nil
-        }
-				b, _, err := l.resolver.Resolve(l.base, v.Value)
-				if err != nil {
-					return nil, err
-				}
-				yamlnode, err := yamlast.Parse(b)
-				if err != nil {
-					return nil, err
-				}
-				// TODO set line/col/file of the new nodes
-				return yamlnode.Children[0], nil
-
-			case "$include":
-	      if _, ok := l.resolver.(noResolver); ok {
-          return n, nil
-        }
-				b, _, err := l.resolver.Resolve(l.base, v.Value)
-				if err != nil {
-					return nil, err
-				}
-				// TODO check line/col of the new node is correct
-				return node(&yamlast.Node{
-					Kind:   yamlast.ScalarNode,
-					Line:   n.Line,
-					Column: n.Column,
-					Value:  string(b),
-				}), nil
-
-			// TODO $mixin
-
-			default:
-				x, err := l.preprocess(v)
-				if err != nil {
-					return nil, err
-				}
-				n.Children[i+1] = x
+			x, err := l.preprocessIn(n.Children[i+1], s)
+			if err != nil {
+				return nil, err
 			}
+			n.Children[i+1] = x
+		}
+
+		if v, ok := findValue(n, "$mixin"); ok {
+			return l.mixinNode(n, v, s)
 		}
 
 	case yamlast.SequenceNode:
 		for i, c := range n.Children {
-			x, err := l.preprocess(c)
+			x, err := l.preprocessIn(c, s)
 			if err != nil {
 				return nil, err
 			}
@@ -65,3 +70,144 @@ func (l *loader) preprocess(n node) (node, error) {
 	}
 	return n, nil
 }
+
+// importNode replaces the mapping "n" with the document referenced by
+// its $import field "v".
+func (l *loader) importNode(n, v node, s importScope) (node, error) {
+	if l.noResolve() {
+		return n, nil
+	}
+	root, scope, err := l.resolveNode(n, v, s, "$import")
+	if err != nil {
+		return nil, err
+	}
+	return l.preprocessIn(root, scope)
+}
+
+// includeNode replaces the mapping "n" with a string holding the contents of
+// the document referenced by its $include field "v".
+func (l *loader) includeNode(n, v node, s importScope) (node, error) {
+	if l.noResolve() {
+		return n, nil
+	}
+	b, _, err := l.resolver.Resolve(s.base, v.Value)
+	if err != nil {
+		return nil, l.errorAt(v, errf("resolving $include %q: %w", v.Value, err))
+	}
+	return node(&yamlast.Node{
+		Kind:   yamlast.ScalarNode,
+		Line:   n.Line,
+		Column: n.Column,
+		Value:  string(b),
+	}), nil
+}
+
+// mixinNode merges the fields of the mapping referenced by $mixin field "v"
+// into the mapping "n". Fields of "n" override fields of the mixin.
+func (l *loader) mixinNode(n, v node, s importScope) (node, error) {
+	if l.noResolve() {
+		return n, nil
+	}
+	root, scope, err := l.resolveNode(n, v, s, "$mixin")
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yamlast.MappingNode {
+		return nil, l.errorAt(v, errf("$mixin %q must refer to a mapping", v.Value))
+	}
+	root, err = l.preprocessIn(root, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &yamlast.Node{
+		Kind:   yamlast.MappingNode,
+		Line:   n.Line,
+		Column: n.Column,
+	}
+	have := map[string]bool{}
+	for _, kv := range itermap(n) {
+		k := strings.ToLower(kv.k)
+		if k == "$mixin" {
+			continue
+		}
+		have[k] = true
+	}
+	for i := 0; i < len(n.Children)-1; i += 2 {
+		if strings.ToLower(n.Children[i].Value) == "$mixin" {
+			continue
+		}
+		merged.Children = append(merged.Children, n.Children[i], n.Children[i+1])
+	}
+	for i := 0; i < len(root.Children)-1; i += 2 {
+		if have[strings.ToLower(root.Children[i].Value)] {
+			continue
+		}
+		merged.Children = append(merged.Children, root.Children[i], root.Children[i+1])
+	}
+	if l.origins == nil {
+		l.origins = map[node]origin{}
+	}
+	l.origins[merged] = l.originOf(n)
+	return merged, nil
+}
+
+// resolveNode resolves and parses the document referenced by the
+// $import or $mixin field "v" of mapping "n", returning the document's
+// root node and the scope for preprocessing it.
+func (l *loader) resolveNode(n, v node, s importScope, directive string) (node, importScope, error) {
+	loc := joinLocation(s.base, v.Value)
+	for _, x := range s.stack {
+		if x == loc {
+			cycle := strings.Join(append(s.stack, loc), " -> ")
+			return nil, s, l.errorAt(v, errf("%s cycle: %s", directive, cycle))
+		}
+	}
+
+	b, base, err := l.resolver.Resolve(s.base, v.Value)
+	if err != nil {
+		return nil, s, l.errorAt(v, errf("resolving %s %q: %w", directive, v.Value, err))
+	}
+	yamlnode, err := yamlast.Parse(b)
+	if err != nil {
+		return nil, s, l.errorAt(v, errf("parsing %s %q: %s", directive, v.Value, err))
+	}
+	if yamlnode == nil || len(yamlnode.Children) == 0 {
+		return nil, s, l.errorAt(v, errf("%s %q is empty", directive, v.Value))
+	}
+
+	root := node(yamlnode.Children[0])
+	l.setOrigin(root, origin{file: loc, base: base})
+	// Index the imported nodes under the path of the node they replace,
+	// so that errors while preprocessing them have a path.
+	l.index(root, l.paths[n])
+
+	stack := append(append([]string{}, s.stack...), loc)
+	return root, importScope{base: base, stack: stack}, nil
+}
+
+// setOrigin records the document which the nodes in tree "n" were loaded from.
+func (l *loader) setOrigin(n node, o origin) {
+	if l.origins == nil {
+		l.origins = map[node]origin{}
+	}
+	l.origins[n] = o
+	for _, c := range n.Children {
+		l.setOrigin(c, o)
+	}
+}
+
+// originOf returns the document which node "n" was loaded from.
+// Nodes which weren't imported belong to the root document.
+func (l *loader) originOf(n node) origin {
+	if o, ok := l.origins[n]; ok {
+		return o
+	}
+	return origin{file: l.file, base: l.base}
+}
+
+// noResolve returns true if references to other documents should
+// not be resolved, e.g. when NoResolve() is used.
+func (l *loader) noResolve() bool {
+	return isNoResolve(l.resolver)
+}
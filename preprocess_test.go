@@ -0,0 +1,106 @@
+package cwl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes a set of files, keyed by relative path, to a temporary
+// directory and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "cwl-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportRelativeToImportingFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tool.cwl": `
+class: CommandLineTool
+inputs:
+  $import: types/inputs.yml
+outputs: []
+requirements:
+  - $mixin: types/docker.yml
+    dockerPull: "ubuntu:18.04"
+`,
+		"types/inputs.yml": `
+reads:
+  $import: reads.yml
+`,
+		"types/reads.yml": `
+type: File
+inputBinding:
+  prefix: -r
+`,
+		"types/docker.yml": `
+class: DockerRequirement
+dockerPull: "ubuntu:latest"
+dockerOutputDirectory: /out
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	doc, err := Load(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+
+	if len(tool.Inputs) != 1 || tool.Inputs[0].InputBinding.GetPrefix() != "-r" {
+		t.Fatalf("unexpected inputs: %#v", tool.Inputs)
+	}
+	pos := tool.Inputs[0].InputBinding.Pos
+	if pos.File != filepath.Join(dir, "types/reads.yml") || pos.Line != 4 {
+		t.Errorf("unexpected position of imported node: %s", pos)
+	}
+
+	d, ok := tool.RequiresDocker()
+	if !ok {
+		t.Fatal("expected DockerRequirement from $mixin")
+	}
+	if d.Pull != "ubuntu:18.04" || d.OutputDirectory != "/out" {
+		t.Errorf("unexpected $mixin result: %#v", d)
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tool.cwl": `
+class: CommandLineTool
+inputs:
+  $import: a.yml
+outputs: []
+`,
+		"a.yml": `
+x:
+  $import: b.yml
+`,
+		"b.yml": `
+$import: a.yml
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := Load(filepath.Join(dir, "tool.cwl"))
+	if err == nil {
+		t.Fatal("expected import cycle error")
+	}
+	if !strings.Contains(err.Error(), "$import cycle") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,399 @@
+package process
+
+import (
+	"github.com/buchanae/cwl"
+	"sort"
+	"strings"
+)
+
+// NodeKind is the kind of a node of a workflow graph.
+type NodeKind string
+
+const (
+	InputNode  NodeKind = "input"
+	StepNode   NodeKind = "step"
+	OutputNode NodeKind = "output"
+)
+
+// EdgeKind is the kind of an edge of a workflow graph, i.e. the kind
+// of source it describes.
+type EdgeKind string
+
+const (
+	// SourceEdge connects a source to a step input ("source").
+	SourceEdge EdgeKind = "source"
+	// OutputSourceEdge connects a source to a workflow output ("outputSource").
+	OutputSourceEdge EdgeKind = "outputSource"
+)
+
+// Node is a workflow input, step or output.
+type Node struct {
+	// ID is the path of the node in the graph, e.g. "align" for step
+	// "align", or "align/sort" for step "sort" of a subworkflow run by
+	// step "align", once expanded.
+	ID string
+	// Name is the local ID of the input, step or output, e.g. "sort".
+	Name string
+	Kind NodeKind
+	// Parent is the ID of the step whose subworkflow contains the node,
+	// or empty for the nodes of the top-level workflow.
+	Parent string
+
+	// One of Input, Step or Output is set, depending on Kind.
+	Input  *cwl.WorkflowInput
+	Step   *cwl.Step
+	Output *cwl.WorkflowOutput
+}
+
+// Edge connects the source of a value to the node which uses it.
+type Edge struct {
+	Kind     EdgeKind
+	From, To *Node
+	// FromPort is the output of the step which is the source,
+	// e.g. "out" for "source: align/out". It's empty when the source
+	// is a workflow input, or the output of an expanded subworkflow.
+	FromPort string
+	// ToPort is the step input which uses the source. It's empty for
+	// workflow outputs, and the inputs of expanded subworkflows.
+	ToPort string
+	// LinkMerge and PickValue describe how the value is combined
+	// with the other sources of the same input or output.
+	LinkMerge cwl.LinkMergeMethod
+	PickValue cwl.PickValueMethod
+}
+
+// Graph is the dependency graph of a workflow: its inputs, steps and
+// outputs, connected by their sources.
+type Graph struct {
+	Workflow *cwl.Workflow
+	// Nodes are in the order of the document: inputs, then steps,
+	// then outputs. The nodes of an expanded subworkflow replace
+	// the step which runs it.
+	Nodes []*Node
+	Edges []*Edge
+}
+
+// NewGraph returns the dependency graph of a workflow. Steps which run
+// subworkflows are single nodes; see Graph.Expand.
+//
+// An error is returned if a source doesn't refer to a workflow input
+// or step output.
+//
+// If the IDs of the workflow aren't resolved (see cwl.ResolveIDs), e.g. for
+// a workflow created in code, the graph is built from a resolved copy.
+func NewGraph(wf *cwl.Workflow) (*Graph, error) {
+	return buildGraph(wf, false)
+}
+
+// Expand returns the graph of the workflow where the steps which run
+// subworkflows are replaced by the inputs, steps and outputs of the
+// subworkflows, recursively. The sources of the step's inputs are
+// connected to the inputs of the subworkflow, and the outputs of the
+// subworkflow are the sources of the step's outputs.
+func (g *Graph) Expand() (*Graph, error) {
+	return buildGraph(g.Workflow, true)
+}
+
+func buildGraph(wf *cwl.Workflow, expand bool) (*Graph, error) {
+	// Workflows created in code might not have fully qualified IDs yet.
+	// They're resolved on a copy, so the caller's workflow isn't modified.
+	if !hasFullIDs(wf) {
+		wf = copyDocument(wf).(*cwl.Workflow)
+		cwl.ResolveIDs(wf, "")
+	}
+	b := graphBuilder{g: &Graph{Workflow: wf}, expand: expand, seen: map[*cwl.Workflow]bool{}}
+	if _, _, err := b.add(wf, "", ""); err != nil {
+		return nil, err
+	}
+	return b.g, nil
+}
+
+type graphBuilder struct {
+	g      *Graph
+	expand bool
+	// seen holds the workflows being expanded, to catch
+	// a workflow which runs itself.
+	seen map[*cwl.Workflow]bool
+}
+
+// port is the source of a value: a node, and the step output, if any.
+type port struct {
+	node *Node
+	name string
+}
+
+// add adds the nodes and edges of workflow "wf" to the graph, where
+// "prefix" is prepended to node IDs, and "parent" is the ID of the
+// expanded step which runs the workflow. It returns the input and output
+// nodes of the workflow, by local ID.
+func (b *graphBuilder) add(wf *cwl.Workflow, prefix, parent string) (ins, outs map[string]*Node, err error) {
+	if b.seen[wf] {
+		return nil, nil, errf("step %q runs a workflow which contains it", parent)
+	}
+	b.seen[wf] = true
+	defer delete(b.seen, wf)
+
+	ins = map[string]*Node{}
+	outs = map[string]*Node{}
+	sources := map[string]port{}
+
+	for i := range wf.Inputs {
+		in := &wf.Inputs[i]
+		n := b.node(prefix, parent, in.LocalID, InputNode)
+		n.Input = in
+		ins[in.LocalID] = n
+		sources[in.FullID] = port{node: n}
+	}
+
+	stepNodes := make([]*Node, len(wf.Steps))
+	// The inputs of the subworkflows of expanded steps, by step.
+	subInputs := make([]map[string]*Node, len(wf.Steps))
+
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+
+		if sub, ok := step.Run.(*cwl.Workflow); ok && b.expand {
+			id := prefix + step.LocalID
+			subIns, subOuts, err := b.add(sub, id+"/", id)
+			if err != nil {
+				return nil, nil, err
+			}
+			subInputs[i] = subIns
+			for _, out := range step.Out {
+				n, ok := subOuts[out.LocalID]
+				if !ok {
+					return nil, nil, errf("step %q: output %q is not an output of the workflow it runs", step.ID, out.ID)
+				}
+				sources[out.FullID] = port{node: n}
+			}
+			continue
+		}
+
+		n := b.node(prefix, parent, step.LocalID, StepNode)
+		n.Step = step
+		stepNodes[i] = n
+		for _, out := range step.Out {
+			sources[out.FullID] = port{node: n, name: out.LocalID}
+		}
+	}
+
+	for i := range wf.Outputs {
+		out := &wf.Outputs[i]
+		n := b.node(prefix, parent, out.LocalID, OutputNode)
+		n.Output = out
+		outs[out.LocalID] = n
+	}
+
+	for i := range wf.Steps {
+		step := &wf.Steps[i]
+		for _, in := range step.In {
+			to, toPort := stepNodes[i], in.LocalID
+			if subInputs[i] != nil {
+				// Step inputs which aren't inputs of the subworkflow
+				// are only used by valueFrom expressions.
+				to, toPort = subInputs[i][in.LocalID], ""
+				if to == nil {
+					continue
+				}
+			}
+			for j, src := range in.FullSource {
+				from, ok := sources[src]
+				if !ok {
+					return nil, nil, errf("step %q: input %q: unknown source %q", step.ID, in.ID, in.Source[j])
+				}
+				b.g.Edges = append(b.g.Edges, &Edge{
+					Kind:      SourceEdge,
+					From:      from.node,
+					FromPort:  from.name,
+					To:        to,
+					ToPort:    toPort,
+					LinkMerge: in.LinkMerge,
+					PickValue: in.PickValue,
+				})
+			}
+		}
+	}
+
+	for i := range wf.Outputs {
+		out := &wf.Outputs[i]
+		for j, src := range out.FullOutputSource {
+			from, ok := sources[src]
+			if !ok {
+				return nil, nil, errf("output %q: unknown source %q", out.ID, out.OutputSource[j])
+			}
+			b.g.Edges = append(b.g.Edges, &Edge{
+				Kind:      OutputSourceEdge,
+				From:      from.node,
+				FromPort:  from.name,
+				To:        outs[out.LocalID],
+				LinkMerge: out.LinkMerge,
+				PickValue: out.PickValue,
+			})
+		}
+	}
+	return ins, outs, nil
+}
+
+func (b *graphBuilder) node(prefix, parent, name string, kind NodeKind) *Node {
+	n := &Node{ID: prefix + name, Name: name, Kind: kind, Parent: parent}
+	b.g.Nodes = append(b.g.Nodes, n)
+	return n
+}
+
+// Node returns the node with the given ID, or nil.
+func (g *Graph) Node(id string) *Node {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// In returns the edges which end at node "n", i.e. its sources.
+func (g *Graph) In(n *Node) []*Edge {
+	var out []*Edge
+	for _, e := range g.Edges {
+		if e.To == n {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Out returns the edges which start at node "n", i.e. the nodes
+// which use its values.
+func (g *Graph) Out(n *Node) []*Edge {
+	var out []*Edge
+	for _, e := range g.Edges {
+		if e.From == n {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// CycleError is returned by Graph.Sort when nodes depend on each other.
+type CycleError struct {
+	// Cycles holds the groups of nodes which depend on each other,
+	// as returned by Graph.Cycles.
+	Cycles [][]*Node
+}
+
+func (e *CycleError) Error() string {
+	var parts []string
+	for _, c := range e.Cycles {
+		var ids []string
+		for _, n := range c {
+			ids = append(ids, n.ID)
+		}
+		parts = append(parts, strings.Join(ids, ", "))
+	}
+	return "workflow has a cycle between " + strings.Join(parts, "; and between ")
+}
+
+// Sort returns the nodes in topological order, i.e. every node comes
+// after its sources. Nodes which don't depend on each other keep the
+// order of the document. If the graph has cycles, a *CycleError
+// is returned.
+func (g *Graph) Sort() ([]*Node, error) {
+	if cycles := g.Cycles(); len(cycles) > 0 {
+		return nil, &CycleError{Cycles: cycles}
+	}
+
+	deps := map[*Node]int{}
+	for _, e := range g.Edges {
+		deps[e.To]++
+	}
+
+	var sorted []*Node
+	done := map[*Node]bool{}
+	for len(sorted) < len(g.Nodes) {
+		// Take the first node which is ready, to keep the order
+		// of the document.
+		for _, n := range g.Nodes {
+			if done[n] || deps[n] > 0 {
+				continue
+			}
+			done[n] = true
+			sorted = append(sorted, n)
+			for _, e := range g.Out(n) {
+				deps[e.To]--
+			}
+			break
+		}
+	}
+	return sorted, nil
+}
+
+// Cycles returns the groups of nodes which depend on each other,
+// i.e. the strongly connected components of the graph with more than
+// one node, or a node which is its own source. Nodes of a group, and
+// the groups, are in the order of the document.
+func (g *Graph) Cycles() [][]*Node {
+	order := map[*Node]int{}
+	for i, n := range g.Nodes {
+		order[n] = i
+	}
+
+	// Tarjan's strongly connected components algorithm.
+	index := map[*Node]int{}
+	low := map[*Node]int{}
+	onStack := map[*Node]bool{}
+	var stack []*Node
+	var cycles [][]*Node
+
+	var connect func(n *Node)
+	connect = func(n *Node) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+
+		self := false
+		for _, e := range g.Out(n) {
+			m := e.To
+			if m == n {
+				self = true
+			}
+			if _, ok := index[m]; !ok {
+				connect(m)
+				if low[m] < low[n] {
+					low[n] = low[m]
+				}
+			} else if onStack[m] && index[m] < low[n] {
+				low[n] = index[m]
+			}
+		}
+
+		if low[n] != index[n] {
+			return
+		}
+		var c []*Node
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			c = append(c, m)
+			if m == n {
+				break
+			}
+		}
+		if len(c) > 1 || self {
+			sort.Slice(c, func(i, j int) bool {
+				return order[c[i]] < order[c[j]]
+			})
+			cycles = append(cycles, c)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if _, ok := index[n]; !ok {
+			connect(n)
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		return order[cycles[i][0]] < order[cycles[j][0]]
+	})
+	return cycles
+}
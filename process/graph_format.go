@@ -0,0 +1,147 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes the graph in the Graphviz DOT language. Inputs and
+// outputs are ellipses, and steps are boxes. The nodes of expanded
+// subworkflows are grouped in a cluster, labeled with the step name.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph workflow {")
+	fmt.Fprintln(b, "  rankdir=LR;")
+
+	g.writeGroups("", 1, func(n *Node, indent string) {
+		shape := "ellipse"
+		if n.Kind == StepNode {
+			shape = "box"
+		}
+		fmt.Fprintf(b, "%s%s [label=%s, shape=%s];\n", indent, dotQuote(n.ID), dotQuote(n.Name), shape)
+	}, func(step, indent string) {
+		fmt.Fprintf(b, "%ssubgraph %s {\n", indent, dotQuote("cluster_"+step))
+		fmt.Fprintf(b, "%s  label=%s;\n", indent, dotQuote(baseName(step)))
+	}, func(indent string) {
+		fmt.Fprintf(b, "%s}\n", indent)
+	})
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "  %s -> %s", dotQuote(e.From.ID), dotQuote(e.To.ID))
+		if l := e.label(); l != "" {
+			fmt.Fprintf(b, " [label=%s]", dotQuote(l))
+		}
+		fmt.Fprintln(b, ";")
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, which may be
+// embedded in Markdown documents. Inputs and outputs are rounded, and
+// steps are boxes. The nodes of expanded subworkflows are grouped in
+// a subgraph, labeled with the step name.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	// Mermaid IDs are restricted, so nodes are numbered.
+	ids := map[*Node]string{}
+	for i, n := range g.Nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+	}
+	clusters := 0
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "flowchart LR")
+
+	g.writeGroups("", 1, func(n *Node, indent string) {
+		open, close := "([", "])"
+		if n.Kind == StepNode {
+			open, close = "[", "]"
+		}
+		fmt.Fprintf(b, "%s%s%s%s%s\n", indent, ids[n], open, mermaidQuote(n.Name), close)
+	}, func(step, indent string) {
+		fmt.Fprintf(b, "%ssubgraph c%d [%s]\n", indent, clusters, mermaidQuote(baseName(step)))
+		clusters++
+	}, func(indent string) {
+		fmt.Fprintf(b, "%send\n", indent)
+	})
+
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if l := e.label(); l != "" {
+			arrow += "|" + mermaidQuote(l) + "|"
+		}
+		fmt.Fprintf(b, "  %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+	return b.Flush()
+}
+
+// writeGroups calls "node" for the nodes whose parent is "parent",
+// in order, and "open" and "close" around the nodes of each
+// expanded step, recursively.
+func (g *Graph) writeGroups(parent string, depth int, node func(n *Node, indent string), open func(step, indent string), close func(indent string)) {
+	indent := strings.Repeat("  ", depth)
+	done := map[string]bool{}
+
+	for _, n := range g.Nodes {
+		if n.Parent == parent {
+			node(n, indent)
+			continue
+		}
+		// The expanded step under "parent" which contains the node.
+		step := childGroup(parent, n.Parent)
+		if step == "" || done[step] {
+			continue
+		}
+		done[step] = true
+		open(step, indent)
+		g.writeGroups(step, depth+1, node, open, close)
+		close(indent)
+	}
+}
+
+// childGroup returns the ID of the expanded step which is a child
+// of "parent" and contains group "group", or an empty string if
+// "group" isn't inside "parent".
+func childGroup(parent, group string) string {
+	rest := group
+	if parent != "" {
+		if !strings.HasPrefix(group, parent+"/") {
+			return ""
+		}
+		rest = group[len(parent)+1:]
+	}
+	if i := strings.Index(rest, "/"); i != -1 {
+		rest = rest[:i]
+	}
+	if parent == "" {
+		return rest
+	}
+	return parent + "/" + rest
+}
+
+// label returns the ports of the edge, e.g. "out → in".
+func (e *Edge) label() string {
+	switch {
+	case e.FromPort != "" && e.ToPort != "":
+		return e.FromPort + " → " + e.ToPort
+	case e.FromPort != "":
+		return e.FromPort
+	}
+	return e.ToPort
+}
+
+func baseName(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
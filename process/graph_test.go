@@ -0,0 +1,281 @@
+package process
+
+import (
+	"bytes"
+	"github.com/buchanae/cwl"
+	"strings"
+	"testing"
+)
+
+const graphWorkflow = `
+cwlVersion: v1.2
+class: Workflow
+requirements:
+  SubworkflowFeatureRequirement: {}
+inputs:
+  reads: File
+  ref: File
+outputs:
+  report:
+    type: File
+    outputSource: stats/report
+steps:
+  stats:
+    run: {class: ExpressionTool, inputs: {bam: File}, outputs: {report: File}, expression: "{}"}
+    in:
+      bam: align/bam
+    out: [report]
+  align:
+    run:
+      class: Workflow
+      inputs:
+        reads: File
+        ref: File
+      outputs:
+        bam:
+          type: File
+          outputSource: sort/sorted
+      steps:
+        map:
+          run: {class: ExpressionTool, inputs: {reads: File, ref: File}, outputs: {sam: File}, expression: "{}"}
+          in:
+            reads: reads
+            ref: ref
+          out: [sam]
+        sort:
+          run: {class: ExpressionTool, inputs: {sam: File}, outputs: {sorted: File}, expression: "{}"}
+          in:
+            sam: map/sam
+          out: [sorted]
+    in:
+      reads: reads
+      ref: ref
+    out: [bam]
+`
+
+func loadGraph(t *testing.T, src string) *Graph {
+	t.Helper()
+	doc, err := cwl.LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGraph(doc.(*cwl.Workflow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func nodeIDs(nodes []*Node) string {
+	var ids []string
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func edgeStrings(g *Graph) string {
+	var out []string
+	for _, e := range g.Edges {
+		out = append(out, e.From.ID+":"+e.FromPort+" -> "+e.To.ID+":"+e.ToPort+" ("+string(e.Kind)+")")
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestGraph(t *testing.T) {
+	g := loadGraph(t, graphWorkflow)
+
+	if got := nodeIDs(g.Nodes); got != "reads ref stats align report" {
+		t.Errorf("unexpected nodes: %s", got)
+	}
+	expect := `align:bam -> stats:bam (source)
+reads: -> align:reads (source)
+ref: -> align:ref (source)
+stats:report -> report: (outputSource)`
+	if got := edgeStrings(g); got != expect {
+		t.Errorf("unexpected edges:\n%s", got)
+	}
+
+	sorted, err := g.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIDs(sorted); got != "reads ref align stats report" {
+		t.Errorf("unexpected order: %s", got)
+	}
+	if n := g.Node("align"); n.Kind != StepNode || n.Step.ID != "align" || len(g.In(n)) != 2 || len(g.Out(n)) != 1 {
+		t.Errorf("unexpected node: %#v", n)
+	}
+}
+
+func TestGraphExpand(t *testing.T) {
+	g, err := loadGraph(t, graphWorkflow).Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sorted, err := g.Sort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "reads ref align/reads align/ref align/map align/sort align/bam stats report"
+	if got := nodeIDs(sorted); got != expect {
+		t.Errorf("unexpected order: %s", got)
+	}
+	if n := g.Node("align/sort"); n.Parent != "align" || n.Name != "sort" {
+		t.Errorf("unexpected node: %#v", n)
+	}
+	if len(g.In(g.Node("stats"))) != 1 || g.In(g.Node("stats"))[0].From.ID != "align/bam" {
+		t.Errorf("expected stats to use the subworkflow output")
+	}
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := loadGraph(t, `
+cwlVersion: v1.2
+class: Workflow
+inputs:
+  x: int
+outputs: []
+steps:
+  a:
+    run: {class: ExpressionTool, inputs: {x: int, y: int}, outputs: {out: int}, expression: "{}"}
+    in:
+      x: x
+      y: b/out
+    out: [out]
+  b:
+    run: {class: ExpressionTool, inputs: {y: int}, outputs: {out: int}, expression: "{}"}
+    in:
+      y: a/out
+    out: [out]
+  c:
+    run: {class: ExpressionTool, inputs: {y: int}, outputs: {out: int}, expression: "{}"}
+    in:
+      y: c/out
+    out: [out]
+`)
+
+	cycles := g.Cycles()
+	if len(cycles) != 2 || nodeIDs(cycles[0]) != "a b" || nodeIDs(cycles[1]) != "c" {
+		t.Errorf("unexpected cycles: %v", cycles)
+	}
+	_, err := g.Sort()
+	if _, ok := err.(*CycleError); !ok {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if err.Error() != "workflow has a cycle between a, b; and between c" {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestGraphUnknownSource(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: Workflow
+inputs: []
+outputs:
+  out:
+    type: File
+    outputSource: missing/out
+steps: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewGraph(doc.(*cwl.Workflow))
+	if err == nil || !strings.Contains(err.Error(), `unknown source "missing/out"`) {
+		t.Errorf("expected unknown source error, got %v", err)
+	}
+}
+
+// IDs of workflows created in code are resolved without modifying
+// the workflow, and loaded workflows keep the IDs of their document.
+func TestGraphIDs(t *testing.T) {
+	wf := &cwl.Workflow{
+		Inputs:  []cwl.WorkflowInput{{ID: "reads"}},
+		Outputs: []cwl.WorkflowOutput{{ID: "out", OutputSource: []string{"reads"}}},
+	}
+	g, err := NewGraph(wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := edgeStrings(g); got != "reads: -> out: (outputSource)" {
+		t.Errorf("unexpected edges:\n%s", got)
+	}
+	if wf.Inputs[0].FullID != "" || wf.Outputs[0].FullOutputSource != nil {
+		t.Errorf("expected the workflow not to be modified, got %#v", wf)
+	}
+
+	doc, err := cwl.LoadDocumentBytes([]byte(graphWorkflow), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := doc.(*cwl.Workflow)
+	cwl.ResolveIDs(loaded, "file:///x/wf.cwl")
+	if _, err := NewGraph(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if id := loaded.Steps[0].FullID; id != "file:///x/wf.cwl#stats" {
+		t.Errorf("expected the IDs not to be resolved again, got %q", id)
+	}
+}
+
+func TestGraphFormats(t *testing.T) {
+	g, err := loadGraph(t, graphWorkflow).Expand()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	expectDOT := `digraph workflow {
+  rankdir=LR;
+  "reads" [label="reads", shape=ellipse];
+  "ref" [label="ref", shape=ellipse];
+  "stats" [label="stats", shape=box];
+  subgraph "cluster_align" {
+    label="align";
+    "align/reads" [label="reads", shape=ellipse];
+    "align/ref" [label="ref", shape=ellipse];
+    "align/map" [label="map", shape=box];
+    "align/sort" [label="sort", shape=box];
+    "align/bam" [label="bam", shape=ellipse];
+  }
+  "report" [label="report", shape=ellipse];
+  "align/reads" -> "align/map" [label="reads"];
+  "align/ref" -> "align/map" [label="ref"];
+  "align/map" -> "align/sort" [label="sam → sam"];
+  "align/sort" -> "align/bam" [label="sorted"];
+  "align/bam" -> "stats" [label="bam"];
+  "reads" -> "align/reads";
+  "ref" -> "align/ref";
+  "stats" -> "report" [label="report"];
+}
+`
+	if dot.String() != expectDOT {
+		t.Errorf("unexpected DOT:\n%s", dot.String())
+	}
+
+	var mermaid bytes.Buffer
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"flowchart LR",
+		`  n0(["reads"])`,
+		`  n2["stats"]`,
+		`  subgraph c0 ["align"]`,
+		`    n5["map"]`,
+		`  end`,
+		`  n5 -->|"sam → sam"| n6`,
+		`  n0 --> n3`,
+		`  n2 -->|"report"| n8`,
+	} {
+		if !strings.Contains(mermaid.String(), line+"\n") {
+			t.Errorf("expected %q in Mermaid output:\n%s", line, mermaid.String())
+		}
+	}
+}
@@ -45,7 +45,7 @@ func (process *Process) bindInput(
 	name string,
 	types []cwl.InputType,
 	clb *cwl.CommandLineBinding,
-	secondaryFiles []cwl.Expression,
+	secondaryFiles []cwl.SecondaryFileSchema,
 	val interface{},
 	key sortKey,
 ) ([]*Binding, error) {
@@ -207,8 +207,8 @@ Loop:
 			}
 			// TODO figure out a good way to do this.
 			f.Path = "/inputs/" + f.Path
-			for _, expr := range secondaryFiles {
-				process.resolveSecondaryFiles(f, expr)
+			for _, sf := range secondaryFiles {
+				process.resolveSecondaryFiles(f, sf.Pattern)
 			}
 
 			return []*Binding{
@@ -25,7 +25,7 @@ func (process *Process) Outputs(fs Filesystem) (cwl.Values, error) {
 		if err != nil {
 			return nil, errf(`failed to bind value for "%s": %s`, out.ID, err)
 		}
-		values[out.ID] = v
+		values[cwl.LocalID(out.ID)] = v
 	}
 	return values, nil
 }
@@ -35,7 +35,7 @@ func (process *Process) bindOutput(
 	fs Filesystem,
 	types []cwl.OutputType,
 	binding *cwl.CommandOutputBinding,
-	secondaryFiles []cwl.Expression,
+	secondaryFiles []cwl.SecondaryFileSchema,
 	val interface{},
 ) (interface{}, error) {
 	var err error
@@ -146,8 +146,8 @@ Loop:
 					continue Loop
 				}
 				f := y[0]
-				for _, expr := range secondaryFiles {
-					err := process.resolveSecondaryFiles(f, expr)
+				for _, sf := range secondaryFiles {
+					err := process.resolveSecondaryFiles(f, sf.Pattern)
 					if err != nil {
 						return nil, errf("resolving secondary files: %s", err)
 					}
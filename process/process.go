@@ -5,6 +5,7 @@ import (
 	"github.com/buchanae/cwl"
 	"github.com/buchanae/cwl/expr"
 	"github.com/rs/xid"
+	"reflect"
 )
 
 type Mebibyte int
@@ -47,13 +48,28 @@ type Process struct {
 	stderr         string
 }
 
-func NewProcess(tool *cwl.Tool, values cwl.Values, rt Runtime, fs Filesystem) (*Process, error) {
+// NewProcess validates a tool and binds its input values. "inherited" are
+// the requirements of the workflows and steps running the tool, which apply
+// to the tool unless it has a requirement of the same class.
+//
+// The tool is copied, so the caller's tool isn't modified.
+func NewProcess(tool *cwl.Tool, values cwl.Values, rt Runtime, fs Filesystem, inherited ...cwl.Requirement) (*Process, error) {
+
+	tool = copyDocument(tool).(*cwl.Tool)
+	tool.Requirements = inheritRequirements(inherited, tool.Requirements)
 
 	err := cwl.ValidateTool(tool)
 	if err != nil {
 		return nil, err
 	}
 
+	// Replace references to SchemaDefRequirement types with the types,
+	// so that inputs can be bound.
+	err = cwl.ResolveSchemaDefs(tool)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO expose input bindings as an exported type of data
 	//      could be useful to know separately from all the other processing.
 	process := &Process{
@@ -73,9 +89,12 @@ func NewProcess(tool *cwl.Tool, values cwl.Values, rt Runtime, fs Filesystem) (*
 	// nothing can be done on a Process without a valid inputs binding,
 	// which is why we bind in the Process constructor.
 	for _, in := range tool.Inputs {
-		val := values[in.ID]
+		// Input values are keyed by short name, e.g. "reads",
+		// even when the ID is qualified, e.g. "#main/reads".
+		name := cwl.LocalID(in.ID)
+		val := values[name]
 		k := sortKey{getPos(in.InputBinding)}
-		b, err := process.bindInput(in.ID, in.Type, in.InputBinding, in.SecondaryFiles, val, k)
+		b, err := process.bindInput(name, in.Type, in.InputBinding, in.SecondaryFiles, val, k)
 		if err != nil {
 			return nil, errf("binding input %q: %s", in.ID, err)
 		}
@@ -178,7 +197,7 @@ func (process *Process) loadReqs() error {
 			// TODO eval expressions
 
 		case cwl.SchemaDefRequirement:
-			return errf("SchemaDefRequirement is not supported (yet)")
+			// Types are resolved by NewProcess.
 		case cwl.InitialWorkDirRequirement:
 			return errf("InitialWorkDirRequirement is not supported (yet)")
 		}
@@ -255,12 +274,31 @@ func toJSONMap(v interface{}) (interface{}, error) {
 	return data, nil
 }
 
+// inheritRequirements returns "own" following the requirements in
+// "inherited" which don't have the same class as one in "own".
+func inheritRequirements(inherited, own []cwl.Requirement) []cwl.Requirement {
+	var out []cwl.Requirement
+	for _, r := range inherited {
+		found := false
+		for _, o := range own {
+			if reflect.TypeOf(r) == reflect.TypeOf(o) {
+				found = true
+			}
+		}
+		if !found {
+			out = append(out, r)
+		}
+	}
+	return append(out, own...)
+}
+
 // setDefaults sets the default input values based on the CommandInput.Default.
 func setDefaults(values cwl.Values, inputs []cwl.CommandInput) {
 	for _, in := range inputs {
-		_, ok := values[in.ID]
+		name := cwl.LocalID(in.ID)
+		_, ok := values[name]
 		if !ok && in.Default != nil {
-			values[in.ID] = in.Default
+			values[name] = in.Default
 		}
 	}
 }
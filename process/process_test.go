@@ -0,0 +1,129 @@
+package process
+
+import (
+	"github.com/buchanae/cwl"
+	"strings"
+	"testing"
+)
+
+func TestNewProcessSchemaDef(t *testing.T) {
+	doc, err := cwl.Load("../examples/057-schemadef-tool/tool.cwl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := cwl.LoadValuesBytes([]byte(`hello: {a: hello, b: world}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewProcess(doc.(*cwl.Tool), vals, Runtime{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, b := range p.InputBindings() {
+		if _, ok := b.Type.(cwl.InputRecord); ok {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the input to be bound as a record")
+	}
+	if _, ok := doc.(*cwl.Tool).Inputs[0].Type[0].(cwl.TypeRef); !ok {
+		t.Error("expected the tool not to be modified")
+	}
+}
+
+func TestNewProcessInvalid(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: CommandLineTool
+baseCommand: echo
+arguments:
+- valueFrom: $(inputs.n + 1)
+inputs:
+  n: int
+outputs: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := cwl.Values{"n": 2}
+
+	_, err = NewProcess(doc.(*cwl.Tool), vals, Runtime{}, nil)
+	if _, ok := err.(*cwl.ValidationError); !ok {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+
+	// Inherited requirements are validated along with the tool.
+	_, err = NewProcess(doc.(*cwl.Tool), vals, Runtime{}, nil, cwl.InlineJavascriptRequirement{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+const inheritedWorkflow = `
+cwlVersion: v1.2
+class: Workflow
+requirements:
+  InlineJavascriptRequirement: {}
+  SchemaDefRequirement:
+    types:
+    - name: Sample
+      type: record
+      fields:
+      - name: id
+        type: string
+inputs:
+  n: int
+  sample: Sample
+outputs: []
+steps:
+  echo:
+    in:
+      n: n
+      sample: sample
+    out: []
+    run:
+      class: CommandLineTool
+      baseCommand: echo
+      arguments:
+      - valueFrom: $(inputs.n + 1)
+      inputs:
+        n: int
+        sample: Sample
+      outputs: []
+`
+
+// Tools run by a workflow inherit its requirements, including the types
+// of a SchemaDefRequirement.
+func TestNewProcessInherited(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(inheritedWorkflow), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*cwl.Workflow)
+	if diags := cwl.Validate(wf); len(diags) != 0 {
+		t.Fatalf("unexpected problems: %v", diags)
+	}
+	tool := wf.Steps[0].Run.(*cwl.Tool)
+
+	vals, err := cwl.LoadValuesBytes([]byte(`{n: 2, sample: {id: a}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProcess(tool, vals, Runtime{}, nil, wf.Requirements...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := p.Command()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cmd, " ") != "echo 3" {
+		t.Errorf("unexpected command: %v", cmd)
+	}
+	if len(tool.Requirements) != 0 {
+		t.Error("expected the tool not to be modified")
+	}
+}
@@ -13,12 +13,10 @@ TODO
 - filesystem multiplexing based on location
 
 - document validation before processing
-- better line/col/context info from document loading errors
 - carefully check document json/yaml marshaling
 - input/output record type handling
 - executor backends
 - directory type
-- $include and $import
 - test unrecognized fields are ignored (possibly with warning)
 - optional checksum calculation for filesystems
 - resource requests
@@ -5,6 +5,7 @@ import (
 	"github.com/buchanae/cwl"
 	"github.com/kr/pretty"
 	"os"
+	"reflect"
 	"strings"
 )
 
@@ -35,3 +36,82 @@ func debug(args ...interface{}) {
 	}
 	fmt.Fprintf(os.Stderr, strings.Join(fmts, " ")+"\n", formatters...)
 }
+
+// copyDocument returns a deep copy of a document, so that it can be
+// resolved without modifying the caller's document. A document run by
+// several steps is copied once, and is shared by the copied steps.
+func copyDocument(doc cwl.Document) cwl.Document {
+	src := reflect.ValueOf(&doc).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	copier{}.copy(dst, src)
+	return dst.Interface().(cwl.Document)
+}
+
+// copier holds the copies of pointers, by address and type, since
+// a struct and its first field have the same address.
+type copier map[copyKey]reflect.Value
+
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (c copier) copy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		k := copyKey{src.Pointer(), src.Type()}
+		if p, ok := c[k]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c[k] = p
+		c.copy(p.Elem(), src.Elem())
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		c.copy(e, src.Elem())
+		dst.Set(e)
+
+	case reflect.Struct:
+		// Unexported fields are copied as they are.
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath == "" {
+				c.copy(dst.Field(i), src.Field(i))
+			}
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			c.copy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for _, k := range src.MapKeys() {
+			e := reflect.New(src.Type().Elem()).Elem()
+			c.copy(e, src.MapIndex(k))
+			m.SetMapIndex(k, e)
+		}
+		dst.Set(m)
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -1,130 +1,433 @@
 package process
 
 import (
+  "encoding/json"
   "fmt"
   "github.com/buchanae/cwl"
+  "github.com/buchanae/cwl/expr"
+  "reflect"
+  "strings"
 )
 
-type scope struct {
-  prefix string
-  links map[string][]string
-}
-func (n scope) child(prefix string) scope {
-  return scope{prefix: n.prefix + "/" + prefix, links: n.links}
-}
-func (n scope) link(name string, val string) {
-  key := n.key(name)
-  n.links[key] = append(n.links[key], val)
-}
-func (n scope) key(name string) string {
-  return n.prefix + "/" + name
+// DebugWorkflow prints the dependency graph of a workflow, with its
+// subworkflows expanded: each input, step and output, in order,
+// followed by its sources.
+func DebugWorkflow(wf *cwl.Workflow) error {
+  g, err := buildGraph(wf, true)
+  if err != nil {
+    return err
+  }
+  nodes, err := g.Sort()
+  if err != nil {
+    return err
+  }
+
+  for _, n := range nodes {
+    fmt.Printf("%s %s\n", n.Kind, n.ID)
+    for _, e := range g.In(n) {
+      src := e.From.ID
+      if e.FromPort != "" {
+        src += "/" + e.FromPort
+      }
+      if e.ToPort != "" {
+        fmt.Printf("  %s <- %s\n", e.ToPort, src)
+      } else {
+        fmt.Printf("  <- %s\n", src)
+      }
+    }
+  }
+  return nil
 }
 
+/*
+TODO goals
+
+- validate that links are correct, not missing any links, etc
+- have (un)marshal-able workflow state
+- validate value bindings, mid workflow
+- resolve inputs to step in nested workflow, mid workflow
+
 
-// DebugWorkflow is a temporary placeholder for workflow processing code.
-func DebugWorkflow(wf *cwl.Workflow, vals cwl.Values) {
+implementation thoughts:
+- major element of name translation over many layers
+- end result is link between two Process objects and/or
+  Step objects.
+- possibly want global Start/End steps, or maybe only End;
+  End.Done() is true when the workflow is done. need to
+  also have link between workflow outputs and last steps
+- want to query value of value by name at any layer?
+  e.g. query for workflow.step0.count_output mid workflow
+*/
 
-  root := scope{prefix: "", links: map[string][]string{}}
+// StepRunner runs the process of a workflow step, such as a CommandLineTool,
+// with the given input values, returning the output values.
+type StepRunner func(doc cwl.Document, inputs cwl.Values) (cwl.Values, error)
 
-  inputs := root.child("inputs")
-  for k, _ := range vals {
-    root.link(k, inputs.key(k))
+// RunWorkflow evaluates a workflow, calling "run" for each step once its
+// sources are available. Steps run one at a time, in dependency order.
+// Subworkflows are evaluated recursively.
+//
+// Steps with a "when" condition which evaluates to false are skipped,
+// and their outputs are null. Scatter is not supported (yet).
+//
+// The workflow isn't modified; IDs and types are resolved on a copy.
+func RunWorkflow(wf *cwl.Workflow, inputs cwl.Values, run StepRunner) (cwl.Values, error) {
+  wf = copyDocument(wf).(*cwl.Workflow)
+  // Workflows created in code might not have fully qualified IDs yet.
+  if !hasFullIDs(wf) {
+    cwl.ResolveIDs(wf, "")
   }
+  // Steps may refer to the types defined by the workflow.
+  if err := cwl.ResolveSchemaDefs(wf); err != nil {
+    return nil, err
+  }
+  return runWorkflow(wf, inputs, run)
+}
 
-  exports := linkWorkflow(wf, root)
-  outputs := root.child("outputs")
+func runWorkflow(wf *cwl.Workflow, inputs cwl.Values, run StepRunner) (cwl.Values, error) {
+  libs := expressionLibs(wf.Requirements, wf.Hints)
+  s := wfstate{vals: cwl.Values{}, reqs: wf.Requirements}
 
-  for _, out := range wf.Outputs {
-    outputs.link(out.ID, exports.key(out.ID))
+  for _, in := range wf.Inputs {
+    v := inputs[in.LocalID]
+    if v == nil {
+      v = in.Default
+    }
+    s.vals[in.FullID] = v
   }
 
-  walk(root.links, []string{"/outputs/count_output"})
+  done := map[string]bool{}
+  for len(done) < len(wf.Steps) {
+    progress := false
 
-}
+    for _, step := range wf.Steps {
+      if done[step.FullID] || !s.ready(step) {
+        continue
+      }
+
+      outs, err := s.runStep(step, libs, run)
+      if err != nil {
+        return nil, wrap(err, "running step %q", step.ID)
+      }
+      for _, out := range step.Out {
+        s.vals[out.FullID] = outs[out.LocalID]
+      }
+      done[step.FullID] = true
+      progress = true
+    }
 
-func walk(links map[string][]string, keys []string) {
-  for _, key := range keys {
-    fmt.Println(key)
-    walk(links, links[key])
+    if !progress {
+      var waiting []string
+      for _, step := range wf.Steps {
+        if !done[step.FullID] {
+          waiting = append(waiting, step.ID)
+        }
+      }
+      return nil, errf("steps can't run because of missing sources or a cycle: %s",
+        strings.Join(waiting, ", "))
+    }
   }
+
+  outputs := cwl.Values{}
+  for _, out := range wf.Outputs {
+    v, err := s.gather(out.FullOutputSource, out.LinkMerge, out.PickValue)
+    if err != nil {
+      return nil, wrap(err, "collecting workflow output %q", out.ID)
+    }
+    outputs[out.LocalID] = v
+  }
+  return outputs, nil
 }
 
-func linkWorkflow(wf *cwl.Workflow, parent scope) scope {
+// wfstate holds the values of workflow inputs and step outputs,
+// keyed by fully qualified ID, e.g. "file:///wf.cwl#step1/output1".
+type wfstate struct {
+  vals cwl.Values
+  // reqs are the requirements of the workflow,
+  // which are inherited by its steps.
+  reqs []cwl.Requirement
+}
 
-  internal := parent.child("workflow")
+// hasFullIDs returns false if cwl.ResolveIDs hasn't been called
+// for the workflow.
+func hasFullIDs(wf *cwl.Workflow) bool {
   for _, in := range wf.Inputs {
-    internal.link(in.ID, parent.key(in.ID))
+    if in.FullID == "" {
+      return false
+    }
   }
-
   for _, step := range wf.Steps {
-    stepScope := internal.child("step/" + step.ID)
+    if step.FullID == "" {
+      return false
+    }
+  }
+  return true
+}
 
-    for _, in := range step.In {
-      for _, src := range in.Source {
-        stepScope.link(in.ID, internal.key(src))
+// ready returns true if all the sources of the step's inputs are available.
+// The outputs of skipped steps are available, with a null value.
+func (s wfstate) ready(step cwl.Step) bool {
+  for _, in := range step.In {
+    for _, src := range in.FullSource {
+      if _, ok := s.vals[src]; !ok {
+        return false
       }
     }
+  }
+  return true
+}
 
-    stepExports := linkDoc(step.Run, stepScope)
+func (s wfstate) runStep(step cwl.Step, libs []string, run StepRunner) (cwl.Values, error) {
+  if len(step.Scatter) > 0 {
+    return nil, errf("scatter is not supported (yet)")
+  }
 
-    for _, out := range step.Out {
-      id := step.ID + "/" + out.ID
-      internal.link(id, stepExports.key(out.ID))
+  vals := cwl.Values{}
+  for _, in := range step.In {
+    v, err := s.gather(in.FullSource, in.LinkMerge, in.PickValue)
+    if err != nil {
+      return nil, wrap(err, "collecting step input %q", in.ID)
+    }
+    if v == nil {
+      v = in.Default
     }
+    vals[in.LocalID] = v
   }
 
-  exports := internal.child("exports")
-  for _, out := range wf.Outputs {
-    for _, src := range out.OutputSource {
-      exports.link(out.ID, internal.key(src))
+  // cwl spec:
+  // "The value of inputs in the parameter reference or expression must be
+  // the input object to the workflow step after assigning the source values,
+  // applying default, and then scattering."
+  evaluated := cwl.Values{}
+  for k, v := range vals {
+    evaluated[k] = v
+  }
+  for _, in := range step.In {
+    if in.ValueFrom == "" {
+      continue
     }
+    id := in.LocalID
+    v, err := evalWorkflowExpr(in.ValueFrom, libs, vals, vals[id])
+    if err != nil {
+      return nil, wrap(err, "evaluating valueFrom of step input %q", in.ID)
+    }
+    evaluated[id] = v
   }
 
-  return exports
-}
-
-func linkTool(in []cwl.CommandInput, out []cwl.CommandOutput, parent scope) scope {
-  internal := parent.child("tool")
-  for _, in := range in {
-    internal.link(in.ID, parent.key(in.ID))
-    internal.link("toolexec", internal.key(in.ID))
+  if step.When != "" {
+    ok, err := evalWhen(step.When, libs, evaluated)
+    if err != nil {
+      return nil, err
+    }
+    if !ok {
+      // Skipped steps produce null outputs.
+      outs := cwl.Values{}
+      for _, out := range step.Out {
+        outs[out.LocalID] = nil
+      }
+      return outs, nil
+    }
   }
-  exports := internal.child("exports")
-  for _, out := range out {
-    exports.link(out.ID, internal.key("toolexec"))
+
+  // cwl spec:
+  // "Requirements specified in a parent Workflow are inherited by step processes
+  // if they are valid for that step."
+  reqs := inheritRequirements(s.reqs, step.Requirements)
+  doc := withRequirements(step.Run, reqs)
+  if sub, ok := doc.(*cwl.Workflow); ok {
+    return runWorkflow(sub, evaluated, run)
   }
-  return exports
+  return run(doc, evaluated)
 }
 
-func linkDoc(doc cwl.Document, parent scope) scope {
+// withRequirements returns a copy of a process with the requirements it
+// inherits from a workflow step, so that the process is not modified.
+func withRequirements(doc cwl.Document, reqs []cwl.Requirement) cwl.Document {
+  if len(reqs) == 0 {
+    return doc
+  }
   switch z := doc.(type) {
-  case *cwl.Workflow:
-    return linkWorkflow(z, parent)
   case *cwl.Tool:
-    return linkTool(z.Inputs, z.Outputs, parent)
+    c := *z
+    c.Requirements = inheritRequirements(reqs, z.Requirements)
+    return &c
   case *cwl.ExpressionTool:
-    return linkTool(z.Inputs, z.Outputs, parent)
+    c := *z
+    c.Requirements = inheritRequirements(reqs, z.Requirements)
+    return &c
+  case *cwl.Workflow:
+    c := *z
+    c.Requirements = inheritRequirements(reqs, z.Requirements)
+    return &c
   }
-  return scope{}
+  return doc
 }
 
-/*
-TODO goals
+// gather collects the values of a list of sources, applying linkMerge
+// and then pickValue.
+func (s wfstate) gather(sources []string, merge cwl.LinkMergeMethod, pick cwl.PickValueMethod) (cwl.Value, error) {
+  if len(sources) == 0 {
+    return nil, nil
+  }
 
-- validate that links are correct, not missing any links, etc
-- have (un)marshal-able workflow state
-- validate value bindings, mid workflow
-- resolve inputs to step in nested workflow, mid workflow
-- encode links between steps directly, without intermediate layers
+  var v cwl.Value
+  if len(sources) == 1 && merge == "" {
+    v = s.vals[sources[0]]
+  } else {
+    merged := []cwl.Value{}
+    for _, src := range sources {
+      x := s.vals[src]
+      if list, ok := toValueSlice(x); ok && merge == cwl.MergeFlattened {
+        merged = append(merged, list...)
+      } else {
+        merged = append(merged, x)
+      }
+    }
+    v = merged
+  }
 
+  if pick == "" {
+    return v, nil
+  }
+  return PickValue(pick, v)
+}
 
-implementation thoughts:
-- major element of name translation over many layers
-- end result is link between two Process objects and/or
-  Step objects.
-- possibly want global Start/End steps, or maybe only End;
-  End.Done() is true when the workflow is done. need to
-  also have link between workflow outputs and last steps
-- want to query value of value by name at any layer?
-  e.g. query for workflow.step0.count_output mid workflow
-*/
+// PickValue applies a pickValue method to a list of values,
+// such as the merged values of multiple sources.
+func PickValue(method cwl.PickValueMethod, v cwl.Value) (cwl.Value, error) {
+  list, ok := toValueSlice(v)
+  if !ok {
+    return nil, errf("pickValue %s requires a list of values", method)
+  }
+
+  nonNull := []cwl.Value{}
+  for _, x := range list {
+    if x != nil {
+      nonNull = append(nonNull, x)
+    }
+  }
+
+  switch method {
+  case cwl.FirstNonNull:
+    if len(nonNull) == 0 {
+      return nil, errf("pickValue %s: all values are null", method)
+    }
+    return nonNull[0], nil
+
+  case cwl.TheOnlyNonNull:
+    if len(nonNull) != 1 {
+      return nil, errf("pickValue %s: expected exactly one non-null value, found %d", method, len(nonNull))
+    }
+    return nonNull[0], nil
+
+  case cwl.AllNonNull:
+    return nonNull, nil
+  }
+  return nil, errf("unknown pickValue method: %s", method)
+}
+
+// evalWhen evaluates a step's "when" expression, which must return a boolean.
+func evalWhen(when cwl.Expression, libs []string, inputs cwl.Values) (bool, error) {
+  v, err := evalWorkflowExpr(when, libs, inputs, nil)
+  if err != nil {
+    return false, wrap(err, "evaluating when")
+  }
+  b, ok := v.(bool)
+  if !ok {
+    return false, errf(`"when" must evaluate to a boolean, got %#v`, v)
+  }
+  return b, nil
+}
+
+// evalWorkflowExpr evaluates an expression in the context of a workflow step,
+// where "inputs" are the step's input values.
+func evalWorkflowExpr(x cwl.Expression, libs []string, inputs cwl.Values, self cwl.Value) (interface{}, error) {
+  inputsData := map[string]interface{}{}
+  for k, v := range inputs {
+    d, err := toJSONMap(v)
+    if err != nil {
+      return nil, wrap(err, `marshaling "%s" for JS eval`, k)
+    }
+    if d == nil {
+      d = expr.Null
+    }
+    inputsData[k] = d
+  }
+
+  selfData, err := toJSONMap(self)
+  if err != nil {
+    return nil, wrap(err, `marshaling "self" for JS eval`)
+  }
+
+  return expr.Eval(x, libs, map[string]interface{}{
+    "inputs":  inputsData,
+    "self":    selfData,
+    "runtime": map[string]interface{}{},
+  })
+}
+
+// RunExpressionTool evaluates the expression of an ExpressionTool,
+// with the defaults of inputs which aren't given, returning its outputs.
+func RunExpressionTool(tool *cwl.ExpressionTool, inputs cwl.Values) (cwl.Values, error) {
+  vals := cwl.Values{}
+  for k, v := range inputs {
+    vals[k] = v
+  }
+  for _, in := range tool.Inputs {
+    name := cwl.LocalID(in.ID)
+    if vals[name] == nil && in.Default != nil {
+      vals[name] = in.Default
+    }
+  }
+
+  libs := expressionLibs(tool.Requirements, tool.Hints)
+  v, err := evalWorkflowExpr(tool.Expression, libs, vals, nil)
+  if err != nil {
+    return nil, wrap(err, "evaluating expression")
+  }
+
+  // Load the result as a job order, so that Files and Directories
+  // are loaded as cwl.File and cwl.Directory.
+  b, err := json.Marshal(v)
+  if err != nil {
+    return nil, wrap(err, "marshaling the result of the expression")
+  }
+  res, err := cwl.LoadValuesBytes(b)
+  if err != nil || res == nil {
+    return nil, errf("expression must return an object, got %s", b)
+  }
+
+  outputs := cwl.Values{}
+  for _, out := range tool.Outputs {
+    name := cwl.LocalID(out.ID)
+    outputs[name] = res[name]
+  }
+  return outputs, nil
+}
+
+// expressionLibs returns the expressionLib of an InlineJavascriptRequirement.
+func expressionLibs(reqs, hints []cwl.Requirement) []string {
+  reqs = append(append([]cwl.Requirement{}, reqs...), hints...)
+  for _, req := range reqs {
+    if r, ok := req.(cwl.InlineJavascriptRequirement); ok {
+      return r.ExpressionLib
+    }
+  }
+  return nil
+}
+
+// toValueSlice converts a list of any type (e.g. []cwl.File) to []cwl.Value.
+func toValueSlice(v cwl.Value) ([]cwl.Value, bool) {
+  if list, ok := v.([]cwl.Value); ok {
+    return list, true
+  }
+  rv := reflect.ValueOf(v)
+  if v == nil || rv.Kind() != reflect.Slice {
+    return nil, false
+  }
+  list := make([]cwl.Value, rv.Len())
+  for i := range list {
+    list[i] = rv.Index(i).Interface()
+  }
+  return list, true
+}
@@ -0,0 +1,218 @@
+package process
+
+import (
+	"github.com/buchanae/cwl"
+	"reflect"
+	"testing"
+)
+
+const conditionalWorkflow = `
+cwlVersion: v1.2
+class: Workflow
+requirements:
+  InlineJavascriptRequirement: {}
+inputs:
+  val: int
+outputs:
+  out:
+    type: string
+    outputSource: [big/out, small/out]
+    pickValue: the_only_non_null
+  all:
+    type: string[]
+    outputSource: [big/out, small/out]
+    pickValue: all_non_null
+steps:
+  big:
+    run:
+      class: ExpressionTool
+      inputs:
+        in: int
+      outputs:
+        out: string
+      expression: '{"out": "big"}'
+    in:
+      in: val
+    when: $(inputs.in > 10)
+    out: [out]
+  small:
+    run:
+      class: ExpressionTool
+      inputs:
+        in: int
+      outputs:
+        out: string
+      expression: '{"out": "small"}'
+    in:
+      in: val
+    when: $(inputs.in <= 10)
+    out: [out]
+`
+
+// fakeRunner returns the step name as "out", based on the tool's expression,
+// and counts the steps which ran.
+func fakeRunner(ran *[]string) StepRunner {
+	return func(doc cwl.Document, inputs cwl.Values) (cwl.Values, error) {
+		name := "big"
+		if doc.(*cwl.ExpressionTool).Expression == `{"out": "small"}` {
+			name = "small"
+		}
+		*ran = append(*ran, name)
+		return cwl.Values{"out": name}, nil
+	}
+}
+
+func TestRunWorkflowWhen(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(conditionalWorkflow), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*cwl.Workflow)
+
+	for val, expect := range map[int]string{3: "small", 30: "big"} {
+		var ran []string
+		out, err := RunWorkflow(wf, cwl.Values{"val": val}, fakeRunner(&ran))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ran, []string{expect}) {
+			t.Errorf("expected only step %q to run, got %v", expect, ran)
+		}
+		if out["out"] != expect {
+			t.Errorf("expected output %q, got %#v", expect, out["out"])
+		}
+		if !reflect.DeepEqual(out["all"], []cwl.Value{expect}) {
+			t.Errorf("unexpected all_non_null output: %#v", out["all"])
+		}
+	}
+}
+
+func TestPickValue(t *testing.T) {
+	vals := []cwl.Value{nil, "a", nil, "b"}
+
+	v, err := PickValue(cwl.FirstNonNull, vals)
+	if err != nil || v != "a" {
+		t.Errorf("first_non_null: got %#v, %v", v, err)
+	}
+
+	v, err = PickValue(cwl.AllNonNull, vals)
+	if err != nil || !reflect.DeepEqual(v, []cwl.Value{"a", "b"}) {
+		t.Errorf("all_non_null: got %#v, %v", v, err)
+	}
+
+	if _, err := PickValue(cwl.TheOnlyNonNull, vals); err == nil {
+		t.Error("the_only_non_null: expected error for multiple non-null values")
+	}
+	if _, err := PickValue(cwl.FirstNonNull, []cwl.Value{nil, nil}); err == nil {
+		t.Error("first_non_null: expected error when all values are null")
+	}
+
+	v, err = PickValue(cwl.AllNonNull, []cwl.Value{nil})
+	if err != nil || !reflect.DeepEqual(v, []cwl.Value{}) {
+		t.Errorf("all_non_null: expected empty list, got %#v, %v", v, err)
+	}
+}
+
+func TestRunExpressionTool(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: ExpressionTool
+requirements:
+  InlineJavascriptRequirement: {}
+inputs:
+  n: int
+  name:
+    type: string
+    default: out.txt
+outputs:
+  next: int
+  file: File
+expression: |
+  ${ return {"next": inputs.n + 1, "file": {"class": "File", "path": inputs.name}, "extra": 1}; }
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := RunExpressionTool(doc.(*cwl.ExpressionTool), cwl.Values{"n": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := cwl.Values{
+		"next": 3,
+		"file": cwl.File{Path: "out.txt"},
+	}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("unexpected outputs: %#v", out)
+	}
+}
+
+// RunWorkflow resolves types on a copy of the workflow.
+func TestRunWorkflowCopy(t *testing.T) {
+	doc, err := cwl.LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: Workflow
+requirements:
+  SchemaDefRequirement:
+    types:
+    - name: Mode
+      type: enum
+      symbols: [fast, slow]
+inputs:
+  mode: Mode
+outputs:
+  out:
+    type: string
+    outputSource: echo/out
+steps:
+  echo:
+    run:
+      class: ExpressionTool
+      inputs:
+        mode: Mode
+      outputs:
+        out: string
+      expression: '{"out": "big"}'
+    in:
+      mode: mode
+    out: [out]
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*cwl.Workflow)
+
+	var ran []string
+	if _, err := RunWorkflow(wf, cwl.Values{"mode": "fast"}, fakeRunner(&ran)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wf.Inputs[0].Type[0].(cwl.TypeRef); !ok {
+		t.Errorf("expected the workflow not to be modified, got %#v", wf.Inputs[0].Type)
+	}
+	tool := wf.Steps[0].Run.(*cwl.ExpressionTool)
+	if _, ok := tool.Inputs[0].Type[0].(cwl.TypeRef); !ok {
+		t.Errorf("expected the step's tool not to be modified, got %#v", tool.Inputs[0].Type)
+	}
+}
+
+// Pointers to a struct and to its first field have the same address,
+// but each gets a copy of its own type.
+func TestCopyDocumentSameAddress(t *testing.T) {
+	type inner struct{ N int }
+	type outer struct{ In inner }
+	o := &outer{In: inner{N: 1}}
+	src := struct {
+		Outer *outer
+		Inner *inner
+	}{o, &o.In}
+
+	dst := reflect.New(reflect.TypeOf(src)).Elem()
+	copier{}.copy(dst, reflect.ValueOf(src))
+	c := dst.Interface().(struct {
+		Outer *outer
+		Inner *inner
+	})
+	if c.Outer == o || c.Inner == &o.In || c.Inner.N != 1 || c.Outer.In.N != 1 {
+		t.Errorf("unexpected copy: %#v", c)
+	}
+}
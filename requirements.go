@@ -1,7 +1,12 @@
 package cwl
 
 type UnknownRequirement struct {
-	Name string
+	// Name is the class of the requirement.
+	Name string `json:"-"`
+	// Extensions holds all the other fields of the requirement.
+	Extensions map[string]Value `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type DockerRequirement struct {
@@ -11,6 +16,8 @@ type DockerRequirement struct {
 	Import          string `json:"dockerImport,omitempty"`
 	ImageID         string `json:"dockerImageID,omitempty"`
 	OutputDirectory string `json:"dockerOutputDirectory,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type ResourceRequirement struct {
@@ -22,59 +29,138 @@ type ResourceRequirement struct {
 	TmpDirMax Expression `json:"tmpdirMax,omitempty"`
 	OutDirMin Expression `json:"outdirMin,omitempty"`
 	OutDirMax Expression `json:"outdirMax,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type EnvVarRequirement struct {
 	EnvDef map[string]Expression `json:"envDef,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type ShellCommandRequirement struct {
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type InlineJavascriptRequirement struct {
 	ExpressionLib []string `json:"expressionLib,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type SchemaDefRequirement struct {
 	Types []SchemaDef `json:"types,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type SchemaDef struct {
 	Name string `json:"name,omitempty"`
 	Type SchemaType
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type SoftwareRequirement struct {
 	Packages []SoftwarePackage `json:"packages,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type SoftwarePackage struct {
 	Package string   `json:"package,omitempty"`
 	Version []string `json:"version,omitempty"`
 	Specs   []string `json:"specs,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+// InitialWorkDirListing describes the files and directories staged into
+// the output directory by InitialWorkDirRequirement.
+//
+// The listing is either a single expression which evaluates to a list
+// of files and directories, e.g. "listing: $(inputs.indir.listing)",
+// or a list of entries.
+type InitialWorkDirListing struct {
+	Expression Expression
+	Entries    []InitialWorkDirEntry
 }
 
-type InitialWorkDirListing struct{}
+// InitialWorkDirEntry is an item in an InitialWorkDirListing:
+// Dirent, File, Directory, or an Expression which evaluates to
+// a file, directory, or list of them.
+type InitialWorkDirEntry interface {
+	initialworkdirentry()
+}
+
+func (Dirent) initialworkdirentry()     {}
+func (File) initialworkdirentry()       {}
+func (Directory) initialworkdirentry()  {}
+func (Expression) initialworkdirentry() {}
 
 type InitialWorkDirRequirement struct {
-	// TODO the most difficult union type
 	Listing InitialWorkDirListing `json:"listing,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type Dirent struct {
 	Entry     Expression `json:"entry,omitempty"`
 	Entryname Expression `json:"entryname,omitempty"`
-	Writable  bool       `json:"writeable,omitempty"`
+	Writable  bool       `json:"writable,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type SubworkflowFeatureRequirement struct {
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type ScatterFeatureRequirement struct {
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type MultipleInputFeatureRequirement struct {
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type StepInputExpressionRequirement struct {
+	Pos Position `json:"-" yaml:"-"`
+}
+
+// ToolTimeLimit sets an upper limit on the execution time of a tool,
+// in seconds. Timelimit is an int or an expression.
+type ToolTimeLimit struct {
+	Timelimit Expression `json:"timelimit,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+// WorkReuse controls whether previously computed results may be reused.
+// EnableReuse is a boolean or an expression; reuse is enabled when empty.
+type WorkReuse struct {
+	EnableReuse Expression `json:"enableReuse,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+// NetworkAccess controls whether a tool may access the network.
+// NetworkAccess is a boolean or an expression.
+type NetworkAccess struct {
+	NetworkAccess Expression `json:"networkAccess,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+type InplaceUpdateRequirement struct {
+	InplaceUpdate bool `json:"inplaceUpdate,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+type LoadListingRequirement struct {
+	LoadListing LoadListing `json:"loadListing,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
@@ -2,6 +2,7 @@ package cwl
 
 import (
 	"github.com/commondream/yamlast"
+	"reflect"
 	"strings"
 )
 
@@ -42,6 +43,7 @@ func (l *loader) MappingToRequirementSlice(n node) ([]Requirement, error) {
 			return nil, err
 		}
 		req := x.(Requirement)
+		l.setPos(reflect.ValueOf(&req), v)
 		reqs = append(reqs, req)
 	}
 	return reqs, nil
@@ -52,8 +54,76 @@ func (l *loader) MappingToRequirement(n node) (Requirement, error) {
 	return l.loadReqByName(class, n)
 }
 
+// SeqToSchemaDefSlice loads the types of a SchemaDefRequirement. An item may
+// itself be a list of types, e.g. a file of types included with $import.
+func (l *loader) SeqToSchemaDefSlice(n node) ([]SchemaDef, error) {
+	var out []SchemaDef
+	for _, c := range n.Children {
+		if c.Kind == yamlast.SequenceNode {
+			defs, err := l.SeqToSchemaDefSlice(c)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, defs...)
+			continue
+		}
+		var def SchemaDef
+		if err := l.load(c, &def); err != nil {
+			return nil, err
+		}
+		out = append(out, def)
+	}
+	return out, nil
+}
+
+func (l *loader) ScalarToInitialWorkDirListing(n node) (InitialWorkDirListing, error) {
+	return InitialWorkDirListing{Expression: Expression(n.Value)}, nil
+}
+
 func (l *loader) SeqToInitialWorkDirListing(n node) (InitialWorkDirListing, error) {
-	return InitialWorkDirListing{}, nil
+	listing := InitialWorkDirListing{}
+	for _, c := range n.Children {
+		var e InitialWorkDirEntry
+		err := l.load(c, &e)
+		if err != nil {
+			return listing, err
+		}
+		listing.Entries = append(listing.Entries, e)
+	}
+	return listing, nil
+}
+
+func (l *loader) ScalarToInitialWorkDirEntry(n node) (InitialWorkDirEntry, error) {
+	return Expression(n.Value), nil
+}
+
+func (l *loader) MappingToInitialWorkDirEntry(n node) (InitialWorkDirEntry, error) {
+	class := findKey(n, "class")
+	switch strings.ToLower(class) {
+	case "file":
+		f := File{}
+		err := l.load(n, &f)
+		return f, err
+	case "directory":
+		d := Directory{}
+		err := l.load(n, &d)
+		return d, err
+	case "":
+		d := Dirent{}
+		err := l.load(n, &d)
+		return d, err
+	default:
+		return nil, errf("unknown initial work dir listing class: '%s'", class)
+	}
+}
+
+func (l *loader) ScalarToLoadListing(n node) (LoadListing, error) {
+	switch x := LoadListing(n.Value); x {
+	case NoListing, ShallowListing, DeepListing:
+		return x, nil
+	default:
+		return "", errf("invalid loadListing value: %s", n.Value)
+	}
 }
 
 func (l *loader) loadReqByName(name string, n node) (Requirement, error) {
@@ -98,8 +168,33 @@ func (l *loader) loadReqByName(name string, n node) (Requirement, error) {
 		return MultipleInputFeatureRequirement{}, nil
 	case "stepinputexpressionrequirement":
 		return StepInputExpressionRequirement{}, nil
+	case "tooltimelimit":
+		r := ToolTimeLimit{}
+		err := l.load(n, &r)
+		return r, err
+	case "workreuse":
+		r := WorkReuse{}
+		err := l.load(n, &r)
+		return r, err
+	case "networkaccess":
+		r := NetworkAccess{}
+		err := l.load(n, &r)
+		return r, err
+	case "inplaceupdaterequirement":
+		r := InplaceUpdateRequirement{}
+		err := l.load(n, &r)
+		return r, err
+	case "loadlistingrequirement":
+		r := LoadListingRequirement{}
+		err := l.load(n, &r)
+		return r, err
+	}
+	r := UnknownRequirement{Name: name}
+	if n.Kind == yamlast.MappingNode {
+		err := l.load(n, &r)
+		return r, err
 	}
-	return UnknownRequirement{Name: name}, nil
+	return r, nil
 	// TODO logging
 	//return nil, fmt.Errorf("unknown requirement name: %s", name)
 }
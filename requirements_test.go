@@ -0,0 +1,188 @@
+package cwl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const initialWorkDirDoc = `
+class: CommandLineTool
+inputs: []
+outputs: []
+requirements:
+  - class: InitialWorkDirRequirement
+    listing:
+      - entryname: config.txt
+        entry: |
+          threads=4
+      - entry: $(inputs.input_dir)
+        entryname: work_dir
+        writable: true
+      - $(inputs.reference)
+      - class: File
+        location: data.txt
+hints:
+  - class: InitialWorkDirRequirement
+    listing: $(inputs.indir.listing)
+`
+
+func TestInitialWorkDirListing(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(initialWorkDirDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+
+	expect := []InitialWorkDirEntry{
+		Dirent{Entryname: "config.txt", Entry: "threads=4\n"},
+		Dirent{Entryname: "work_dir", Entry: "$(inputs.input_dir)", Writable: true},
+		Expression("$(inputs.reference)"),
+		File{Location: "data.txt"},
+	}
+
+	req := tool.Requirements[0].(InitialWorkDirRequirement)
+	if got := clearDirentPos(req.Listing.Entries); !reflect.DeepEqual(got, expect) {
+		t.Errorf("unexpected listing entries:\n%#v", got)
+	}
+
+	hint := tool.Hints[0].(InitialWorkDirRequirement)
+	if hint.Listing.Expression != "$(inputs.indir.listing)" || hint.Listing.Entries != nil {
+		t.Errorf("unexpected expression listing: %#v", hint.Listing)
+	}
+
+	// The listing must survive marshaling and reloading.
+	b, err := json.Marshal(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = LoadDocumentBytes(b, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool = doc.(*Tool)
+
+	req = tool.Requirements[0].(InitialWorkDirRequirement)
+	if got := clearDirentPos(req.Listing.Entries); !reflect.DeepEqual(got, expect) {
+		t.Errorf("unexpected listing entries after marshaling:\n%#v", got)
+	}
+	hint = tool.Hints[0].(InitialWorkDirRequirement)
+	if hint.Listing.Expression != "$(inputs.indir.listing)" {
+		t.Errorf("unexpected expression listing after marshaling: %#v", hint.Listing)
+	}
+}
+
+func clearDirentPos(entries []InitialWorkDirEntry) []InitialWorkDirEntry {
+	var out []InitialWorkDirEntry
+	for _, e := range entries {
+		if d, ok := e.(Dirent); ok {
+			d.Pos = Position{}
+			e = d
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+const v12RequirementsDoc = `
+cwlVersion: v1.2
+class: CommandLineTool
+requirements:
+  ToolTimeLimit:
+    timelimit: 60
+  WorkReuse:
+    enableReuse: $(inputs.reuse)
+  NetworkAccess:
+    networkAccess: true
+  InplaceUpdateRequirement:
+    inplaceUpdate: true
+  LoadListingRequirement:
+    loadListing: deep_listing
+inputs:
+  bam:
+    type: File
+    secondaryFiles:
+      - .bai
+      - pattern: ^.bai
+        required: false
+  ref:
+    type: File
+    secondaryFiles:
+      pattern: .fai
+outputs: []
+`
+
+func TestV12Requirements(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(v12RequirementsDoc), "", nil, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+
+	var reqs []Requirement
+	for _, r := range tool.Requirements {
+		reqs = append(reqs, clearReqPos(r))
+	}
+	expect := []Requirement{
+		ToolTimeLimit{Timelimit: "60"},
+		WorkReuse{EnableReuse: "$(inputs.reuse)"},
+		NetworkAccess{NetworkAccess: "true"},
+		InplaceUpdateRequirement{InplaceUpdate: true},
+		LoadListingRequirement{LoadListing: DeepListing},
+	}
+	if !reflect.DeepEqual(reqs, expect) {
+		t.Errorf("unexpected requirements:\n%#v", reqs)
+	}
+
+	bam := tool.Inputs[0].SecondaryFiles
+	if len(bam) != 2 || bam[0].Pattern != ".bai" || bam[0].Required != "" ||
+		bam[1].Pattern != "^.bai" || bam[1].Required != "false" {
+		t.Errorf("unexpected secondary files: %#v", bam)
+	}
+	ref := tool.Inputs[1].SecondaryFiles
+	if len(ref) != 1 || ref[0].Pattern != ".fai" {
+		t.Errorf("unexpected secondary files: %#v", ref)
+	}
+}
+
+// Patterns without "required" are marshaled as strings,
+// which is the only form allowed by v1.0.
+func TestMarshalSecondaryFiles(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(v12RequirementsDoc), "", nil, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(doc.(*Tool).Inputs[0].SecondaryFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[".bai",{"pattern":"^.bai","required":"false"}]`
+	if string(b) != expect {
+		t.Errorf("unexpected secondary files: %s", b)
+	}
+}
+
+func TestInvalidLoadListing(t *testing.T) {
+	doc := `
+class: CommandLineTool
+inputs: []
+outputs: []
+requirements:
+  - class: LoadListingRequirement
+    loadListing: all_of_it
+`
+	_, err := LoadDocumentBytes([]byte(doc), "", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// clearReqPos zeroes the position of a requirement, for comparison.
+func clearReqPos(r Requirement) Requirement {
+	v := reflect.New(reflect.TypeOf(r)).Elem()
+	v.Set(reflect.ValueOf(r))
+	if f := v.FieldByName("Pos"); f.IsValid() {
+		f.Set(reflect.ValueOf(Position{}))
+	}
+	return v.Interface().(Requirement)
+}
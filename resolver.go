@@ -1,10 +1,16 @@
 package cwl
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
 // Resolver describes a type which resolves docment
@@ -20,23 +26,36 @@ type Resolver interface {
 	Resolve(base, location string) (doc []byte, newBase string, err error)
 }
 
+// ResolverFunc is a function which implements Resolver,
+// e.g. for registering a custom scheme with MultiResolver.
+type ResolverFunc func(base, location string) ([]byte, string, error)
+
+func (f ResolverFunc) Resolve(base, loc string) ([]byte, string, error) {
+	return f(base, loc)
+}
+
 // DefaultResolver is a document location resolver which
-// resolves local file paths.
-type DefaultResolver struct{}
+// resolves local file paths and HTTP URLs.
+type DefaultResolver struct {
+	// HTTP resolves HTTP URLs, see HTTPResolver.
+	HTTP HTTPResolver
+}
 
-func (DefaultResolver) Resolve(base, loc string) ([]byte, string, error) {
-	if u, ok := isHTTP(base, loc); ok {
-		b, err := httpGet(u)
-		if err != nil {
-			return nil, "", err
-		}
-		return b, u.String(), nil
+func (d DefaultResolver) Resolve(base, loc string) ([]byte, string, error) {
+	if u, ok := isHTTP(base, loc); ok && (u.Scheme == "http" || u.Scheme == "https") {
+		return d.HTTP.Resolve(base, loc)
 	}
+	return FileResolver{}.Resolve(base, loc)
+}
 
-	if !filepath.IsAbs(loc) {
-		loc = filepath.Clean(filepath.Join(base, loc))
-	}
+// FileResolver resolves local file paths and "file://" URIs.
+type FileResolver struct{}
+
+func (FileResolver) Resolve(base, loc string) ([]byte, string, error) {
+	base = strings.TrimPrefix(base, "file://")
+	loc = strings.TrimPrefix(loc, "file://")
 
+	loc = joinLocation(base, loc)
 	b, err := ioutil.ReadFile(loc)
 	if err != nil {
 		return nil, "", err
@@ -45,6 +64,185 @@ func (DefaultResolver) Resolve(base, loc string) ([]byte, string, error) {
 	return b, dir, nil
 }
 
+// FSResolver resolves slash-separated paths in a file system, e.g. an
+// embed.FS. Relative paths are resolved against the directory of the
+// document containing the reference. See LoadFS.
+type FSResolver struct {
+	FS fs.FS
+}
+
+func (r FSResolver) Resolve(base, loc string) ([]byte, string, error) {
+	p := loc
+	if !strings.HasPrefix(loc, "/") {
+		p = path.Join(base, loc)
+	}
+	// fs.FS paths are unrooted.
+	p = strings.TrimPrefix(path.Clean(p), "/")
+
+	b, err := fs.ReadFile(r.FS, p)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, path.Dir(p), nil
+}
+
+// HTTPResolver resolves http and https URLs. The zero value
+// uses http.DefaultClient, with no authorization and no size limit.
+//
+// Responses with a non-2xx status are reported as an *HTTPError.
+type HTTPResolver struct {
+	// Context is used for requests, e.g. to cancel them.
+	// If nil, context.Background() is used.
+	Context context.Context
+	// Client sends requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Authorization returns the value of the Authorization header for
+	// a request, e.g. "Bearer <token>", or an empty string for none.
+	Authorization func(u *url.URL) (string, error)
+	// MaxSize is the maximum size of a document in bytes.
+	// Zero means no limit.
+	MaxSize int64
+}
+
+// HTTPError describes a response with a non-2xx status.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("failed to resolve HTTP URL %s: %s", e.URL, e.Status)
+}
+
+func (h HTTPResolver) Resolve(base, loc string) ([]byte, string, error) {
+	u, ok := isHTTP(base, loc)
+	if !ok {
+		return nil, "", fmt.Errorf("not a URL: %s", loc)
+	}
+	resp, err := h.get(u, nil)
+	if err != nil {
+		return nil, "", errf(`failed to resolve HTTP URL %s: %w`, u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", &HTTPError{URL: u.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := h.read(u, resp)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, u.String(), nil
+}
+
+// get sends a GET request for URL "u" with the additional headers "hdr".
+func (h HTTPResolver) get(u *url.URL, hdr http.Header) (*http.Response, error) {
+	ctx := h.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range hdr {
+		req.Header[k] = v
+	}
+
+	if h.Authorization != nil {
+		auth, err := h.Authorization(u)
+		if err != nil {
+			return nil, err
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// read reads the body of a response, up to MaxSize bytes.
+func (h HTTPResolver) read(u *url.URL, resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if h.MaxSize > 0 {
+		if resp.ContentLength > h.MaxSize {
+			return nil, h.tooLarge(u)
+		}
+		r = io.LimitReader(r, h.MaxSize+1)
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errf(`failed to read HTTP response body for %s: %s`, u.String(), err)
+	}
+	if h.MaxSize > 0 && int64(len(body)) > h.MaxSize {
+		return nil, h.tooLarge(u)
+	}
+	return body, nil
+}
+
+func (h HTTPResolver) tooLarge(u *url.URL) error {
+	return errf(`document %s is larger than the maximum size of %d bytes`, u.String(), h.MaxSize)
+}
+
+// MultiResolver resolves documents with the resolver registered for
+// the URI scheme of their location, e.g. "https", "trs" or "git+file".
+// Relative locations use the scheme of their base, and locations
+// without a scheme, i.e. file paths, use the "file" scheme.
+//
+// Unless registered otherwise, the "file" scheme is resolved by
+// FileResolver, and "http" and "https" by HTTPResolver.
+// The zero value is ready to use.
+type MultiResolver struct {
+	schemes map[string]Resolver
+}
+
+// Register sets the resolver for a URI scheme, e.g. "trs".
+func (m *MultiResolver) Register(scheme string, r Resolver) {
+	if m.schemes == nil {
+		m.schemes = map[string]Resolver{}
+	}
+	m.schemes[strings.ToLower(scheme)] = r
+}
+
+func (m *MultiResolver) Resolve(base, loc string) ([]byte, string, error) {
+	scheme := schemeOf(loc)
+	if scheme == "" {
+		scheme = schemeOf(base)
+	}
+	if scheme == "" {
+		scheme = "file"
+	}
+
+	if r, ok := m.schemes[scheme]; ok {
+		return r.Resolve(base, loc)
+	}
+	switch scheme {
+	case "file":
+		return FileResolver{}.Resolve(base, loc)
+	case "http", "https":
+		return HTTPResolver{}.Resolve(base, loc)
+	}
+	return nil, "", fmt.Errorf("no resolver for scheme %q", scheme)
+}
+
+// schemeOf returns the lowercase URI scheme of a location,
+// or an empty string for file paths.
+func schemeOf(loc string) string {
+	u, err := url.Parse(loc)
+	// Single letter schemes are probably Windows drive letters.
+	if err != nil || len(u.Scheme) < 2 {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
 // NoResolve is a special case resolver which does not
 // resolve documents, but instead creates `DocumentRef`
 // instances in the document tree.
@@ -56,6 +254,25 @@ type noResolver struct {
 	Resolver
 }
 
+// isNoResolve returns true if "r" doesn't resolve references to other
+// documents, i.e. it is nil or NoResolve().
+func isNoResolve(r Resolver) bool {
+	_, ok := r.(noResolver)
+	return ok || r == nil
+}
+
+// joinLocation returns the location of `loc` relative to `base`,
+// either a URL or a file path, following the same rules as DefaultResolver.
+func joinLocation(base, loc string) string {
+	if u, ok := isHTTP(base, loc); ok {
+		return u.String()
+	}
+	if !filepath.IsAbs(loc) {
+		loc = filepath.Clean(filepath.Join(base, loc))
+	}
+	return loc
+}
+
 func isHTTP(base, loc string) (*url.URL, bool) {
 	if base == "" {
 		base, loc = loc, base
@@ -73,16 +290,3 @@ func isHTTP(base, loc string) (*url.URL, bool) {
 	}
 	return b.ResolveReference(l), true
 }
-
-func httpGet(u *url.URL) ([]byte, error) {
-	resp, err := http.Get(u.String())
-	if err != nil {
-		return nil, errf(`failed to resolve HTTP URL %s: %s`, u.String(), err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errf(`failed to read HTTP response body for %s: %s`, u.String(), err)
-	}
-	return body, nil
-}
@@ -0,0 +1,297 @@
+package cwl
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const resolverWf = `
+cwlVersion: v1.0
+class: Workflow
+inputs: []
+outputs: []
+steps:
+  local:
+    run: tool.cwl
+    in: []
+    out: []
+  remote:
+    run: %s/tool.cwl
+    in: []
+    out: []
+`
+
+const resolverTool = `
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: echo
+inputs: []
+outputs: []
+`
+
+func TestMultiResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(resolverTool))
+	}))
+	defer srv.Close()
+
+	// "mem" is a custom scheme serving documents from memory.
+	docs := map[string]string{
+		"mem://docs/wf.cwl":   strings.Replace(resolverWf, "%s", srv.URL, 1),
+		"mem://docs/tool.cwl": resolverTool,
+	}
+	m := &MultiResolver{}
+	m.Register("mem", ResolverFunc(func(base, loc string) ([]byte, string, error) {
+		loc = joinLocation(base, loc)
+		d, ok := docs[loc]
+		if !ok {
+			return nil, "", os.ErrNotExist
+		}
+		return []byte(d), loc, nil
+	}))
+
+	doc, err := LoadWithResolver("mem://docs/wf.cwl", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range doc.(*Workflow).Steps {
+		tool, ok := step.Run.(*Tool)
+		if !ok || tool.BaseCommand[0] != "echo" {
+			t.Errorf("step %s: expected the echo tool, got %#v", step.ID, step.Run)
+		}
+	}
+
+	if _, _, err := m.Resolve("", "trs://example.com/tool"); err == nil {
+		t.Error("expected error for unregistered scheme")
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	var fetched, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(resolverTool))
+	}))
+
+	dir, err := ioutil.TempDir("", "cwl-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := CachingResolver{Dir: dir}
+
+	load := func() {
+		t.Helper()
+		doc, err := LoadWithResolver(srv.URL+"/tool.cwl", c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tool := doc.(*Tool); tool.BaseCommand[0] != "echo" {
+			t.Errorf("unexpected tool: %#v", tool)
+		}
+	}
+
+	load()
+	load()
+	if fetched != 1 || notModified != 1 {
+		t.Errorf("expected one download and one revalidation, got %d and %d", fetched, notModified)
+	}
+
+	// Only the document and its entry are left in the cache directory.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected two cache files, got %d", len(files))
+	}
+
+	// The cached document is used when the server is unreachable.
+	srv.Close()
+	load()
+}
+
+func TestHTTPResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/tool.cwl":
+			w.Write([]byte(resolverTool))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	h := HTTPResolver{
+		Authorization: func(u *url.URL) (string, error) {
+			return "Bearer secret", nil
+		},
+	}
+	doc, err := LoadWithResolver(srv.URL+"/tool.cwl", DefaultResolver{HTTP: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool := doc.(*Tool); tool.BaseCommand[0] != "echo" {
+		t.Errorf("unexpected tool: %#v", tool)
+	}
+
+	expectStatus := func(r HTTPResolver, path string, code int) {
+		t.Helper()
+		_, _, err := r.Resolve(srv.URL, path)
+		if e, ok := err.(*HTTPError); !ok || e.StatusCode != code {
+			t.Errorf("expected HTTP %d error, got %v", code, err)
+		}
+	}
+	expectStatus(HTTPResolver{}, "tool.cwl", http.StatusUnauthorized)
+	expectStatus(h, "missing.cwl", http.StatusNotFound)
+
+	// A 404 page isn't parsed as a document.
+	_, err = LoadWithResolver(srv.URL+"/missing.cwl", DefaultResolver{HTTP: h})
+	var herr *HTTPError
+	if !errors.As(err, &herr) {
+		t.Errorf("expected HTTP error, got %v", err)
+	}
+
+	small := h
+	small.MaxSize = 10
+	if _, _, err := small.Resolve(srv.URL, "tool.cwl"); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("expected maximum size error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := h
+	canceled.Context = ctx
+	if _, _, err := canceled.Resolve(srv.URL, "tool.cwl"); err == nil {
+		t.Error("expected error for canceled context")
+	}
+}
+
+// HTTP errors are found by errors.As through the errors of the documents
+// and imports which refer to the missing document.
+func TestHTTPErrorNested(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wf.cwl":
+			w.Write([]byte(`
+cwlVersion: v1.0
+class: Workflow
+inputs: []
+outputs: []
+steps:
+  sub:
+    run: sub.cwl
+    in: []
+    out: []
+`))
+		case "/sub.cwl":
+			w.Write([]byte(`
+cwlVersion: v1.0
+class: Workflow
+inputs: []
+outputs: []
+steps:
+  missing:
+    run: missing.cwl
+    in: []
+    out: []
+`))
+		case "/import.cwl":
+			w.Write([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  $import: missing.yml
+outputs: []
+`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/wf.cwl", "/import.cwl"} {
+		_, err := LoadWithResolver(srv.URL+path, DefaultResolver{})
+		var herr *HTTPError
+		if !errors.As(err, &herr) || herr.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected HTTP 404 error, got %v", path, err)
+		}
+		var lerr *LoadError
+		if !errors.As(err, &lerr) {
+			t.Errorf("%s: expected a load error, got %v", path, err)
+		}
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cwl/wf.cwl": {Data: []byte(`
+cwlVersion: v1.0
+class: Workflow
+inputs: []
+outputs: []
+steps:
+  echo:
+    run: tools/echo.cwl
+    in: []
+    out: []
+`)},
+		"cwl/tools/echo.cwl": {Data: []byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+baseCommand: echo
+requirements:
+  - $import: ../reqs.yml
+inputs: []
+outputs: []
+arguments:
+  - valueFrom:
+      $include: message.txt
+`)},
+		"cwl/reqs.yml":          {Data: []byte(`class: InlineJavascriptRequirement`)},
+		"cwl/tools/message.txt": {Data: []byte(`hello`)},
+	}
+
+	doc, err := LoadFS(fsys, "cwl/wf.cwl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Workflow).Steps[0].Run.(*Tool)
+	if _, ok := tool.Requirements[0].(InlineJavascriptRequirement); !ok {
+		t.Errorf("expected imported requirement, got %#v", tool.Requirements)
+	}
+	if v := tool.Arguments[0].ValueFrom; v != "hello" {
+		t.Errorf("expected included argument, got %q", v)
+	}
+
+	// IDs are relative to the root of the file system,
+	// not the working directory.
+	if id := doc.(*Workflow).Steps[0].FullID; id != "cwl/wf.cwl#echo" {
+		t.Errorf("unexpected step ID %q", id)
+	}
+	if tool.FullID != "cwl/tools/echo.cwl" {
+		t.Errorf("unexpected tool ID %q", tool.FullID)
+	}
+
+	if _, err := LoadFS(fsys, "cwl/missing.cwl"); err == nil {
+		t.Error("expected error for missing document")
+	}
+}
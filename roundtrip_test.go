@@ -0,0 +1,213 @@
+package cwl
+
+import (
+	"encoding/json"
+	"github.com/kr/pretty"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// invalidExamples are the examples which don't load, and why.
+var invalidExamples = map[string]string{
+	"examples/unsorted/mc3-annotate.cwl": "refers to a workflow which isn't in the examples",
+}
+
+// isJobOrder returns true for the examples which are job orders,
+// e.g. "examples/000-bwa-mem-tool/job.cwl", rather than documents.
+func isJobOrder(path string) bool {
+	base := filepath.Base(path)
+	return base == "job.cwl" || strings.HasSuffix(base, ".inputs.cwl")
+}
+
+// TestRoundTrip checks that every example document can be marshaled to
+// JSON, YAML and compact YAML, and loaded again, without losing anything.
+func TestRoundTrip(t *testing.T) {
+	files, _ := filepath.Glob("examples/*/*.cwl")
+	if len(files) == 0 {
+		t.Fatal("no example files found")
+	}
+
+	for _, path := range files {
+		if isJobOrder(path) {
+			continue
+		}
+		doc, err := Load(path)
+		if reason, ok := invalidExamples[path]; ok {
+			if err == nil {
+				t.Errorf("%s: expected a load error (%s)", path, reason)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		clearLocations(&doc)
+
+		jsonb, err := json.Marshal(doc)
+		if err != nil {
+			t.Errorf("%s: marshaling JSON: %s", path, err)
+			continue
+		}
+		yamlb, err := MarshalYAML(doc)
+		if err != nil {
+			t.Errorf("%s: marshaling YAML: %s", path, err)
+			continue
+		}
+		compactb, err := MarshalCompactYAML(doc)
+		if err != nil {
+			t.Errorf("%s: marshaling compact YAML: %s", path, err)
+			continue
+		}
+
+		for format, b := range map[string][]byte{"JSON": jsonb, "YAML": yamlb, "compact YAML": compactb} {
+			got, err := LoadDocumentBytes(b, filepath.Dir(path), nil)
+			if err != nil {
+				t.Errorf("%s: loading marshaled %s: %s\n%s", path, format, err, b)
+				continue
+			}
+			clearLocations(&got)
+			if !reflect.DeepEqual(doc, got) {
+				t.Errorf("%s: %s round trip changed the document:\n%s",
+					path, format, pretty.Diff(doc, got))
+			}
+		}
+	}
+}
+
+// TestLoadLists checks parameters given as a list of types,
+// and step inputs given as a list of sources.
+func TestLoadLists(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: CommandLineTool
+inputs:
+  reads: [File, "null"]
+outputs:
+  out: [File, "null"]
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+	if n := len(tool.Inputs[0].Type); n != 2 {
+		t.Errorf("expected two input types, got %d", n)
+	}
+	if n := len(tool.Outputs[0].Type); n != 2 {
+		t.Errorf("expected two output types, got %d", n)
+	}
+
+	doc, err = LoadDocumentBytes([]byte(`
+cwlVersion: v1.2
+class: Workflow
+inputs:
+  a: [File, "null"]
+  b: File
+outputs:
+  out: [File, "null"]
+steps:
+  cat:
+    run: {class: ExpressionTool, inputs: {files: "File[]"}, outputs: [], expression: "{}"}
+    in:
+      files: [a, b]
+    out: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*Workflow)
+	if n := len(wf.Inputs[0].Type); n != 2 {
+		t.Errorf("expected two input types, got %d", n)
+	}
+	if n := len(wf.Outputs[0].Type); n != 2 {
+		t.Errorf("expected two output types, got %d", n)
+	}
+	if src := wf.Steps[0].In[0].Source; !reflect.DeepEqual(src, []string{"a", "b"}) {
+		t.Errorf("unexpected sources: %v", src)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	doc, err := Load("examples/023-count-lines1-wf/tool.cwl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearLocations(&doc)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wf := &Workflow{}
+	if err := json.Unmarshal(b, wf); err != nil {
+		t.Fatal(err)
+	}
+	clearLocations(wf)
+	if !reflect.DeepEqual(doc, wf) {
+		t.Errorf("unmarshaled workflow differs:\n%s", pretty.Diff(doc, wf))
+	}
+}
+
+// clearLocations zeroes every Position and fully qualified ID (e.g. FullID)
+// in "v", which must be a pointer, so that documents loaded from different
+// locations can be compared.
+func clearLocations(v interface{}) {
+	clearLocationsValue(reflect.ValueOf(v))
+}
+
+func clearLocationsValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			clearLocationsValue(v.Elem())
+		}
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		// Values held by interfaces aren't addressable, so copy, clear, and replace.
+		e := v.Elem()
+		c := reflect.New(e.Type()).Elem()
+		c.Set(e)
+		clearLocationsValue(c)
+		if v.CanSet() {
+			v.Set(c)
+		}
+
+	case reflect.Struct:
+		if v.Type() == posType {
+			if v.CanSet() {
+				v.Set(reflect.Zero(posType))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			if strings.HasPrefix(f.Name, "Full") && v.CanSet() {
+				v.Field(i).Set(reflect.Zero(f.Type))
+				continue
+			}
+			clearLocationsValue(v.Field(i))
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearLocationsValue(v.Index(i))
+		}
+
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			clearLocationsValue(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package cwl
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ResolveSchemaDefs replaces references to the types defined by a
+// SchemaDefRequirement, e.g. "#Sample", with the types themselves.
+//
+// The inputs and outputs of tools, expression tools and workflows are
+// resolved, including the documents run by steps and the members of
+// a $graph. A process can refer to the types defined by the workflows
+// and steps which run it, as well as its own. Types imported from other
+// files, e.g. "$import: types.yml", are loaded by Load like any other types,
+// and may be referenced by file and name, e.g. "types.yml#Sample". Types with
+// the same name in different files must be referenced by file and name.
+//
+// Circular references, e.g. a record with a field of its own type,
+// are reported as errors with the path of the types involved.
+//
+// ResolveSchemaDefs modifies the document in place.
+func ResolveSchemaDefs(doc Document) error {
+	switch z := doc.(type) {
+	case Graph:
+		return resolveGraphSchemaDefs(z.Docs)
+	case *Graph:
+		return resolveGraphSchemaDefs(z.Docs)
+	}
+	return resolveSchemaDefs(doc, schemaDefs{})
+}
+
+// resolveGraphSchemaDefs resolves the members of a $graph. Members which
+// are run by a step are resolved with the types of the step's workflow.
+func resolveGraphSchemaDefs(docs []Document) error {
+	run := graphRunDocs(docs)
+	for _, d := range docs {
+		if !isProcess(d) || run[d] {
+			continue
+		}
+		if err := resolveSchemaDefs(d, schemaDefs{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// graphRunDocs returns the members of a $graph which are run by steps,
+// directly or by subworkflows.
+func graphRunDocs(docs []Document) map[Document]bool {
+	run := map[Document]bool{}
+	var mark func(d Document)
+	mark = func(d Document) {
+		if wf, ok := d.(*Workflow); ok {
+			for _, step := range wf.Steps {
+				if isProcess(step.Run) && !run[step.Run] {
+					run[step.Run] = true
+					mark(step.Run)
+				}
+			}
+		}
+	}
+	for _, d := range docs {
+		mark(d)
+	}
+	return run
+}
+
+// schemaDefs holds the types in scope, by their fully qualified name
+// (see schemaName), e.g. "/x/types.yml#Sample". References which don't
+// match a qualified name, e.g. a tool's reference to a type defined by
+// the workflow running it, are found by short name, e.g. "Sample",
+// unless types from different documents have the same short name.
+type schemaDefs struct {
+	full map[string]SchemaDef
+	// local holds the qualified names by short name,
+	// or an empty string if the short name is ambiguous.
+	local map[string]string
+}
+
+// with returns the types in "s" and the types defined by "reqs".
+// The types defined by "reqs" hide types in "s" with the same short name.
+func (s schemaDefs) with(reqs ...[]Requirement) schemaDefs {
+	out := schemaDefs{full: map[string]SchemaDef{}, local: map[string]string{}}
+	for k, v := range s.full {
+		out.full[k] = v
+	}
+	for k, v := range s.local {
+		out.local[k] = v
+	}
+	added := map[string]bool{}
+	for _, rs := range reqs {
+		for _, r := range rs {
+			if sd, ok := r.(SchemaDefRequirement); ok {
+				for _, def := range sd.Types {
+					full := schemaName(def.Pos.File, def.Name)
+					out.full[full] = def
+
+					name := LocalID(def.Name)
+					if added[name] && out.local[name] != full {
+						out.local[name] = ""
+					} else {
+						out.local[name] = full
+					}
+					added[name] = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+// schemaName returns the fully qualified name of type "name" in document
+// "file", e.g. "/x/types.yml#Sample" for "types.yml#Sample" in "/x/tool.cwl",
+// and "/x/tool.cwl#Sample" for "Sample" or "#Sample".
+func schemaName(file, name string) string {
+	doc, frag := splitFragment(name)
+	if !strings.Contains(name, "#") {
+		doc, frag = "", name
+	}
+	switch {
+	case doc == "":
+		doc = file
+	case strings.Contains(file, "://"):
+		doc = joinLocation(file, doc)
+	case file != "":
+		doc = joinLocation(filepath.Dir(file), doc)
+	}
+	return doc + "#" + frag
+}
+
+func resolveSchemaDefs(doc Document, parent schemaDefs) error {
+	switch z := doc.(type) {
+	case *Tool:
+		defs := parent.with(z.Requirements, z.Hints)
+		return resolveParamTypes(defs, z.Inputs, z.Outputs)
+
+	case *ExpressionTool:
+		defs := parent.with(z.Requirements, z.Hints)
+		return resolveParamTypes(defs, z.Inputs, z.Outputs)
+
+	case *Workflow:
+		defs := parent.with(z.Requirements, z.Hints)
+		for i := range z.Inputs {
+			in := &z.Inputs[i]
+			t, err := defs.inputTypes(in.Type, nil)
+			if err != nil {
+				return typeError(in.Pos, "input", in.ID, err)
+			}
+			in.Type = t
+		}
+		for i := range z.Outputs {
+			out := &z.Outputs[i]
+			t, err := defs.outputTypes(out.Type, nil)
+			if err != nil {
+				return typeError(out.Pos, "output", out.ID, err)
+			}
+			out.Type = t
+		}
+		for _, step := range z.Steps {
+			err := resolveSchemaDefs(step.Run, defs.with(step.Requirements, step.Hints))
+			if err != nil {
+				return fmt.Errorf("step %q: %s", step.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func resolveParamTypes(defs schemaDefs, inputs []CommandInput, outputs []CommandOutput) error {
+	for i := range inputs {
+		in := &inputs[i]
+		t, err := defs.inputTypes(in.Type, nil)
+		if err != nil {
+			return typeError(in.Pos, "input", in.ID, err)
+		}
+		in.Type = t
+	}
+	for i := range outputs {
+		out := &outputs[i]
+		t, err := defs.outputTypes(out.Type, nil)
+		if err != nil {
+			return typeError(out.Pos, "output", out.ID, err)
+		}
+		out.Type = t
+	}
+	return nil
+}
+
+func typeError(pos Position, kind, id string, err error) error {
+	if pos.IsValid() {
+		return fmt.Errorf("%s: %s %q: %s", pos, kind, id, err)
+	}
+	return fmt.Errorf("%s %q: %s", kind, id, err)
+}
+
+// lookup returns the type referenced by "ref". "path" holds the names of
+// the types being resolved, which refer to this one, to detect cycles.
+func (s schemaDefs) lookup(ref TypeRef, path []string) (SchemaType, []string, error) {
+	full := schemaName(ref.Pos.File, ref.Name)
+	def, ok := s.full[full]
+	if !ok {
+		name, found := s.local[LocalID(ref.Name)]
+		if found && name == "" {
+			return nil, nil, fmt.Errorf("schema def name %q is ambiguous", ref.Name)
+		}
+		full = name
+		def, ok = s.full[full]
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("no schema def named %q", ref.Name)
+	}
+	for _, p := range path {
+		if p == full {
+			var names []string
+			for _, n := range append(path, full) {
+				names = append(names, LocalID(n))
+			}
+			cycle := strings.Join(names, " -> ")
+			return nil, nil, fmt.Errorf("circular schema type reference: %s", cycle)
+		}
+	}
+	return def.Type, append(append([]string{}, path...), full), nil
+}
+
+// inputTypes returns a copy of "types" with references resolved.
+// The types are copied, since the same definition may be used many times.
+func (s schemaDefs) inputTypes(types []InputType, path []string) ([]InputType, error) {
+	var out []InputType
+	for _, t := range types {
+		r, err := s.inputType(t, path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s schemaDefs) inputType(t InputType, path []string) (InputType, error) {
+	switch z := t.(type) {
+	case TypeRef:
+		def, path, err := s.lookup(z, path)
+		if err != nil {
+			return nil, err
+		}
+		it, ok := def.(InputType)
+		if !ok {
+			return nil, fmt.Errorf("schema def %q is not an input type", z.Name)
+		}
+		return s.inputType(it, path)
+
+	case InputRecord:
+		fields := make([]InputField, len(z.Fields))
+		for i, f := range z.Fields {
+			types, err := s.inputTypes(f.Type, path)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", f.Name, err)
+			}
+			f.Type = types
+			fields[i] = f
+		}
+		z.Fields = fields
+		return z, nil
+
+	case InputArray:
+		items, err := s.inputTypes(z.Items, path)
+		if err != nil {
+			return nil, err
+		}
+		z.Items = items
+		return z, nil
+	}
+	return t, nil
+}
+
+func (s schemaDefs) outputTypes(types []OutputType, path []string) ([]OutputType, error) {
+	var out []OutputType
+	for _, t := range types {
+		r, err := s.outputType(t, path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s schemaDefs) outputType(t OutputType, path []string) (OutputType, error) {
+	switch z := t.(type) {
+	case TypeRef:
+		def, path, err := s.lookup(z, path)
+		if err != nil {
+			return nil, err
+		}
+		it, ok := def.(InputType)
+		if !ok {
+			return nil, fmt.Errorf("schema def %q is not a type", z.Name)
+		}
+		return s.outputType(inputToOutputType(it), path)
+
+	case OutputRecord:
+		fields := make([]OutputField, len(z.Fields))
+		for i, f := range z.Fields {
+			types, err := s.outputTypes(f.Type, path)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", f.Name, err)
+			}
+			f.Type = types
+			fields[i] = f
+		}
+		z.Fields = fields
+		return z, nil
+
+	case OutputArray:
+		items, err := s.outputTypes(z.Items, path)
+		if err != nil {
+			return nil, err
+		}
+		z.Items = items
+		return z, nil
+	}
+	return t, nil
+}
+
+// inputToOutputType converts a type defined by a SchemaDefRequirement,
+// which is loaded as an input type, for use as an output type.
+// Input bindings are dropped.
+func inputToOutputType(t InputType) OutputType {
+	switch z := t.(type) {
+	case InputRecord:
+		r := OutputRecord{Label: z.Label, Pos: z.Pos}
+		for _, f := range z.Fields {
+			r.Fields = append(r.Fields, OutputField{
+				Name: f.Name,
+				Doc:  f.Doc,
+				Type: inputToOutputTypes(f.Type),
+				Pos:  f.Pos,
+			})
+		}
+		return r
+	case InputEnum:
+		return OutputEnum{Label: z.Label, Symbols: z.Symbols, Pos: z.Pos}
+	case InputArray:
+		return OutputArray{Label: z.Label, Items: inputToOutputTypes(z.Items), Pos: z.Pos}
+	case OutputType:
+		return z
+	}
+	return nil
+}
+
+func inputToOutputTypes(types []InputType) []OutputType {
+	var out []OutputType
+	for _, t := range types {
+		if ot := inputToOutputType(t); ot != nil {
+			out = append(out, ot)
+		}
+	}
+	return out
+}
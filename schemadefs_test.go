@@ -0,0 +1,224 @@
+package cwl
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const schemaDefsDoc = `
+cwlVersion: v1.0
+class: Workflow
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - name: Tag
+        type: enum
+        symbols: [normal, tumor]
+      - name: Sample
+        type: record
+        fields:
+          - name: reads
+            type: File
+          - name: tags
+            type: Tag[]
+inputs:
+  sample: "#Sample"
+outputs: []
+steps:
+  align:
+    run:
+      class: CommandLineTool
+      inputs:
+        sample: Sample
+      outputs:
+        out: Sample
+    in:
+      sample: sample
+    out: [out]
+`
+
+func TestResolveSchemaDefs(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(schemaDefsDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveSchemaDefs(doc); err != nil {
+		t.Fatal(err)
+	}
+	wf := doc.(*Workflow)
+
+	checkSample := func(t InputType) bool {
+		r, ok := t.(InputRecord)
+		if !ok || len(r.Fields) != 2 {
+			return false
+		}
+		arr, ok := r.Fields[1].Type[0].(InputArray)
+		if !ok {
+			return false
+		}
+		_, ok = arr.Items[0].(InputEnum)
+		return ok
+	}
+	if !checkSample(wf.Inputs[0].Type[0]) {
+		t.Errorf("unexpected workflow input type: %#v", wf.Inputs[0].Type)
+	}
+
+	// The step's tool uses the types of the workflow.
+	tool := wf.Steps[0].Run.(*Tool)
+	if !checkSample(tool.Inputs[0].Type[0]) {
+		t.Errorf("unexpected tool input type: %#v", tool.Inputs[0].Type)
+	}
+	out, ok := tool.Outputs[0].Type[0].(OutputRecord)
+	if !ok || len(out.Fields) != 2 {
+		t.Fatalf("unexpected tool output type: %#v", tool.Outputs[0].Type)
+	}
+	if arr, ok := out.Fields[1].Type[0].(OutputArray); !ok {
+		t.Errorf("unexpected output field type: %#v", out.Fields[1].Type)
+	} else if _, ok := arr.Items[0].(OutputEnum); !ok {
+		t.Errorf("unexpected output array items: %#v", arr.Items)
+	}
+}
+
+func TestResolveSchemaDefsCycle(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - name: A
+        type: record
+        fields:
+          - name: b
+            type: B
+      - name: B
+        type: record
+        fields:
+          - name: a
+            type: "#A"
+inputs:
+  x: A
+outputs: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ResolveSchemaDefs(doc)
+	if err == nil || !strings.Contains(err.Error(), "A -> B -> A") {
+		t.Errorf("expected circular reference error, got %v", err)
+	}
+}
+
+func TestResolveSchemaDefsUnknown(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  x: Missing
+outputs: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ResolveSchemaDefs(doc)
+	if err == nil || !strings.Contains(err.Error(), `no schema def named "Missing"`) {
+		t.Errorf("expected unknown type error, got %v", err)
+	}
+}
+
+// TestResolveSchemaDefsImport checks types imported from a file
+// holding a list of types.
+func TestResolveSchemaDefsImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tool.cwl": `
+cwlVersion: v1.0
+class: CommandLineTool
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - $import: types.yml
+inputs:
+  sample: "types.yml#Sample"
+outputs: []
+`,
+		"types.yml": `
+- name: Sample
+  type: record
+  fields:
+    - name: id
+      type: string
+- name: Other
+  type: enum
+  symbols: [a]
+`,
+	})
+
+	doc, err := Load(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveSchemaDefs(doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.(*Tool).Inputs[0].Type[0].(InputRecord); !ok {
+		t.Errorf("unexpected input type: %#v", doc.(*Tool).Inputs[0].Type)
+	}
+}
+
+// Types with the same name imported from different files are found
+// by file and name, and a reference by name alone is ambiguous.
+func TestResolveSchemaDefsSameName(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"tool.cwl": `
+cwlVersion: v1.0
+class: CommandLineTool
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - $import: a.yml
+      - $import: b.yml
+inputs:
+  a: "a.yml#Rec"
+  b: "b.yml#Rec"
+outputs: []
+`,
+		"a.yml": `
+name: Rec
+type: record
+fields:
+  - name: a
+    type: string
+`,
+		"b.yml": `
+name: Rec
+type: enum
+symbols: [b]
+`,
+	})
+
+	doc, err := Load(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveSchemaDefs(doc); err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+	if _, ok := tool.Inputs[0].Type[0].(InputRecord); !ok {
+		t.Errorf("expected a record for a.yml#Rec, got %#v", tool.Inputs[0].Type)
+	}
+	if _, ok := tool.Inputs[1].Type[0].(InputEnum); !ok {
+		t.Errorf("expected an enum for b.yml#Rec, got %#v", tool.Inputs[1].Type)
+	}
+
+	doc, err = Load(filepath.Join(dir, "tool.cwl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.(*Tool).Inputs[0].Type = []InputType{TypeRef{Name: "Rec"}}
+	err = ResolveSchemaDefs(doc)
+	if err == nil || !strings.Contains(err.Error(), `schema def name "Rec" is ambiguous`) {
+		t.Errorf("expected ambiguous type error, got %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package cwl
 type InputRecord struct {
 	Label  string       `json:"label,omitempty"`
 	Fields []InputField `json:"fields,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type InputField struct {
@@ -11,23 +13,31 @@ type InputField struct {
 	Label        string              `json:"label,omitempty"`
 	Type         []InputType         `json:"type,omitempty"`
 	InputBinding *CommandLineBinding `json:"inputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type InputEnum struct {
 	Label        string              `json:"label,omitempty"`
 	Symbols      []string            `json:"symbols,omitempty"`
 	InputBinding *CommandLineBinding `json:"inputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type InputArray struct {
 	Label        string              `json:"label,omitempty"`
 	Items        []InputType         `json:"items,omitempty"`
 	InputBinding *CommandLineBinding `json:"inputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type OutputRecord struct {
 	Label  string        `json:"label,omitempty"`
 	Fields []OutputField `json:"fields,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type OutputField struct {
@@ -35,16 +45,34 @@ type OutputField struct {
 	Doc           string                `json:"doc,omitempty"`
 	Type          []OutputType          `json:"type,omitempty"`
 	OutputBinding *CommandOutputBinding `json:"outputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type OutputEnum struct {
 	Label         string                `json:"label,omitempty"`
 	Symbols       []string              `json:"symbols,omitempty"`
 	OutputBinding *CommandOutputBinding `json:"outputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type OutputArray struct {
 	Label         string                `json:"label,omitempty"`
 	Items         []OutputType          `json:"items,omitempty"`
 	OutputBinding *CommandOutputBinding `json:"outputBinding,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
+}
+
+// SecondaryFileSchema describes a secondary file pattern, such as ".bai"
+// or "^.bai", or an expression. The v1.0 string form of secondaryFiles is
+// loaded as a SecondaryFileSchema with only the Pattern set.
+type SecondaryFileSchema struct {
+	Pattern Expression `json:"pattern,omitempty"`
+	// Required is a boolean or an expression. When empty, the default
+	// depends on the CWL version and whether the file is an input or output.
+	Required Expression `json:"required,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
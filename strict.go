@@ -0,0 +1,180 @@
+package cwl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Strict causes loading to fail with an *UnknownFieldsError when the document
+// (or any document it references) contains fields which are not recognized,
+// such as a misspelled "inputBindng".
+//
+// Namespaced extension fields, e.g. "sbg:toolkit", and schema salad
+// directives such as "$namespaces" are always allowed.
+func Strict() LoadOption {
+	return func(o *loadOptions) {
+		o.strict = true
+	}
+}
+
+// WarnUnknownFields collects unrecognized fields into "w" instead of
+// silently ignoring them. Loading doesn't fail unless Strict() is also given.
+func WarnUnknownFields(w *[]UnknownField) LoadOption {
+	return func(o *loadOptions) {
+		o.warn = w
+	}
+}
+
+// UnknownField describes a field in a document which does not match
+// any field of the type being loaded.
+type UnknownField struct {
+	Pos  Position
+	Path string
+	Name string
+	// Suggestions lists known field names which are similar to Name,
+	// closest first.
+	Suggestions []string
+}
+
+func (u UnknownField) String() string {
+	msg := fmt.Sprintf("unknown field %q", u.Name)
+	if len(u.Suggestions) > 0 {
+		msg += fmt.Sprintf(`, did you mean "%s"?`, strings.Join(u.Suggestions, `" or "`))
+	}
+	var parts []string
+	if s := u.Pos.String(); s != "" {
+		parts = append(parts, s)
+	}
+	if u.Path != "" {
+		parts = append(parts, u.Path)
+	}
+	return strings.Join(append(parts, msg), ": ")
+}
+
+// UnknownFieldsError is returned by strict loading when unknown fields
+// were found.
+type UnknownFieldsError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	var lines []string
+	for _, f := range e.Fields {
+		lines = append(lines, f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unknownField records key "k", which didn't match any field of struct "typ".
+func (l *loader) unknownField(typ reflect.Type, k node) {
+	if l.opts == nil || (!l.opts.strict && l.opts.warn == nil) {
+		return
+	}
+	if isExtensionField(k.Value) || isImplicitField(typ, k.Value) {
+		return
+	}
+	l.opts.unknown = append(l.opts.unknown, UnknownField{
+		Pos:         l.pos(k),
+		Path:        l.paths[k],
+		Name:        k.Value,
+		Suggestions: suggestFields(typ, k.Value),
+	})
+}
+
+// isExtensionField returns true for namespaced fields (e.g. "sbg:toolkit",
+// or a full IRI) and schema salad directives (e.g. "$namespaces").
+func isExtensionField(name string) bool {
+	return strings.Contains(name, ":") || strings.HasPrefix(name, "$")
+}
+
+// isImplicitField returns true for fields which don't have a struct field
+// because they are consumed by a loader handler, e.g. "class" selects
+// the type of a requirement, and "type" selects the type of a schema.
+func isImplicitField(typ reflect.Type, name string) bool {
+	name = strings.ToLower(name)
+	if name == "class" {
+		return true
+	}
+	switch reflect.New(typ).Elem().Interface().(type) {
+	case InputRecord, InputEnum, InputArray, OutputRecord, OutputEnum, OutputArray:
+		return name == "type" || name == "name"
+	}
+	return false
+}
+
+// suggestFields returns the fields of struct "typ" which are similar
+// to "name", based on the field's JSON name.
+func suggestFields(typ reflect.Type, name string) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+	var cs []candidate
+
+	name = strings.ToLower(name)
+	// Allow roughly one typo per three characters.
+	max := len(name) / 3
+	if max < 1 {
+		max = 1
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		n := f.Name
+		if alt, ok := f.Tag.Lookup("json"); ok {
+			if sp := strings.Split(alt, ","); sp[0] != "" {
+				n = sp[0]
+			}
+		}
+		if n == "-" {
+			continue
+		}
+		d := editDistance(name, strings.ToLower(n))
+		if d <= max {
+			cs = append(cs, candidate{n, d})
+		}
+	}
+
+	sort.SliceStable(cs, func(i, j int) bool {
+		return cs[i].dist < cs[j].dist
+	})
+
+	var out []string
+	for _, c := range cs {
+		out = append(out, c.name)
+	}
+	return out
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,74 @@
+package cwl
+
+import (
+	"reflect"
+	"testing"
+)
+
+const strictDoc = `
+cwlVersion: v1.0
+class: CommandLineTool
+sbg:toolkit: bwa
+$namespaces:
+  sbg: https://sevenbridges.com
+inputs:
+  reads:
+    type: File
+    secondaryfile: .bai
+    inputBindng:
+      position: 1
+outputs: []
+`
+
+func TestStrictUnknownFields(t *testing.T) {
+	_, err := LoadDocumentBytes([]byte(strictDoc), "", nil, Strict())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	e, ok := err.(*UnknownFieldsError)
+	if !ok {
+		t.Fatalf("expected *UnknownFieldsError, got %T: %s", err, err)
+	}
+	if len(e.Fields) != 2 {
+		t.Fatalf("expected 2 unknown fields, got %d: %s", len(e.Fields), err)
+	}
+
+	expect := []UnknownField{
+		{
+			Pos:         Position{Line: 10, Column: 5},
+			Path:        "inputs.reads.secondaryfile",
+			Name:        "secondaryfile",
+			Suggestions: []string{"secondaryFiles"},
+		},
+		{
+			Pos:         Position{Line: 11, Column: 5},
+			Path:        "inputs.reads.inputBindng",
+			Name:        "inputBindng",
+			Suggestions: []string{"inputBinding"},
+		},
+	}
+	if !reflect.DeepEqual(e.Fields, expect) {
+		t.Errorf("unexpected unknown fields:\n%#v", e.Fields)
+	}
+}
+
+func TestWarnUnknownFields(t *testing.T) {
+	var warn []UnknownField
+	doc, err := LoadDocumentBytes([]byte(strictDoc), "", nil, WarnUnknownFields(&warn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc == nil {
+		t.Fatal("expected document")
+	}
+	if len(warn) != 2 {
+		t.Errorf("expected 2 warnings, got %d", len(warn))
+	}
+}
+
+func TestLenientIgnoresUnknownFields(t *testing.T) {
+	_, err := LoadDocumentBytes([]byte(strictDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
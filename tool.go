@@ -20,8 +20,19 @@ type Tool struct {
 	Stdout Expression `json:"stdout,omitempty"`
 
 	SuccessCodes       []int `json:"successCodes,omitempty"`
-	TemporaryFailCodes []int `json:",omitempty"`
-	PermanentFailCodes []int `json:",omitempty"`
+	TemporaryFailCodes []int `json:"temporaryFailCodes,omitempty"`
+	PermanentFailCodes []int `json:"permanentFailCodes,omitempty"`
+
+	// Extensions holds vendor extension fields, e.g. "sbg:toolkit",
+	// and schema salad fields such as "$namespaces".
+	Extensions map[string]Value `json:"-" yaml:"-"`
+
+	// FullID is the fully qualified ID, e.g. "file:///x/tool.cwl#main",
+	// and LocalID is the short name, e.g. "main". See ResolveIDs.
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type CommandInput struct {
@@ -33,10 +44,15 @@ type CommandInput struct {
 
 	Type []InputType `json:"type,omitempty"`
 
-	SecondaryFiles []Expression `json:"secondaryFiles,omitempty"`
-	Format         []Expression `json:"format,omitempty"`
+	SecondaryFiles []SecondaryFileSchema `json:"secondaryFiles,omitempty"`
+	Format         []Expression          `json:"format,omitempty"`
 
 	InputBinding *CommandLineBinding `json:"inputBinding,omitempty"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type CommandOutput struct {
@@ -47,10 +63,15 @@ type CommandOutput struct {
 
 	Type []OutputType `json:"type,omitempty"`
 
-	SecondaryFiles []Expression `json:"secondaryFiles,omitempty"`
-	Format         []Expression `json:"format,omitempty"`
+	SecondaryFiles []SecondaryFileSchema `json:"secondaryFiles,omitempty"`
+	Format         []Expression          `json:"format,omitempty"`
 
 	OutputBinding *CommandOutputBinding `json:"outputBinding,omitempty"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type CommandLineBinding struct {
@@ -61,10 +82,14 @@ type CommandLineBinding struct {
 	ValueFrom     Expression `json:"valueFrom,omitempty"`
 	Separate      OptOut     `json:"separate,omitempty"`
 	ShellQuote    OptOut     `json:"shellQuote,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type CommandOutputBinding struct {
 	Glob         []Expression `json:"glob,omitempty"`
 	LoadContents bool         `json:"loadContents,omitempty"`
 	OutputEval   Expression   `json:"outputEval,omitempty"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
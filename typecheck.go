@@ -0,0 +1,466 @@
+package cwl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// paramType describes the type of a value passed between steps,
+// for checking that connected parameters have compatible types.
+type paramType struct {
+	// name is the name of the type, e.g. "File", "array" or "record".
+	name string
+	// items holds the item types of an array.
+	items []paramType
+}
+
+var (
+	anyType  = paramType{name: "any"}
+	nullType = paramType{name: "null"}
+)
+
+func arrayOf(items []paramType) paramType {
+	return paramType{name: "array", items: items}
+}
+
+func (t paramType) String() string {
+	if t.name == "array" {
+		s := typeString(t.items)
+		if strings.Contains(s, " ") {
+			s = "(" + s + ")"
+		}
+		return s + "[]"
+	}
+	return t.name
+}
+
+// typeString formats a type union, e.g. "File?" or "int | string".
+func typeString(types []paramType) string {
+	var names []string
+	optional := false
+	for _, t := range types {
+		if t.name == "null" {
+			optional = true
+			continue
+		}
+		names = append(names, t.String())
+	}
+	switch {
+	case len(names) == 0:
+		return "null"
+	case optional && len(names) == 1:
+		return names[0] + "?"
+	case optional:
+		names = append([]string{"null"}, names...)
+	}
+	return strings.Join(names, " | ")
+}
+
+func inputParamTypes(types []InputType) []paramType {
+	var out []paramType
+	for _, t := range types {
+		out = append(out, paramTypeOf(t))
+	}
+	return out
+}
+
+func outputParamTypes(types []OutputType) []paramType {
+	var out []paramType
+	for _, t := range types {
+		out = append(out, paramTypeOf(t))
+	}
+	return out
+}
+
+func paramTypeOf(t cwltype) paramType {
+	switch z := t.(type) {
+	case InputArray:
+		return arrayOf(inputParamTypes(z.Items))
+	case OutputArray:
+		return arrayOf(outputParamTypes(z.Items))
+	case Stdout, Stderr:
+		return paramType{name: "File"}
+	case TypeRef:
+		// Unresolved references are reported by the unknown-type rule.
+		return anyType
+	case fmt.Stringer:
+		return paramType{name: z.String()}
+	}
+	return anyType
+}
+
+// assignable returns true if a value of type "src" may be passed to
+// a parameter of type "sink". Unions are compatible if any of their
+// types are, since which type a value has is only known at runtime.
+// An empty union is unknown, which is compatible with anything.
+func assignable(src, sink []paramType) bool {
+	if len(src) == 0 || len(sink) == 0 {
+		return true
+	}
+	for _, s := range src {
+		for _, k := range sink {
+			if typeAssignable(s, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// widening lists the types which values of a type can be passed to,
+// other than the type itself.
+var widening = map[string][]string{
+	"int":   {"long", "float", "double"},
+	"long":  {"float", "double"},
+	"float": {"double"},
+	"enum":  {"string"},
+}
+
+func typeAssignable(src, sink paramType) bool {
+	switch {
+	case src.name == "any" || sink.name == "any":
+		return true
+	case src.name == "array" && sink.name == "array":
+		return assignable(src.items, sink.items)
+	case src.name == sink.name:
+		return true
+	}
+	for _, w := range widening[src.name] {
+		if w == sink.name {
+			return true
+		}
+	}
+	return false
+}
+
+// itemTypes returns the item types of arrays in "types".
+// Types which aren't arrays may hold anything.
+func itemTypes(types []paramType) []paramType {
+	var out []paramType
+	for _, t := range types {
+		if t.name == "array" {
+			out = append(out, t.items...)
+		} else {
+			out = append(out, anyType)
+		}
+	}
+	return out
+}
+
+func nonNull(types []paramType) []paramType {
+	var out []paramType
+	for _, t := range types {
+		if t.name != "null" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// mergeTypes returns the type of the value of a parameter with the given
+// sources, after linkMerge and pickValue are applied. Multiple sources
+// are merged with merge_nested by default.
+func mergeTypes(sources [][]paramType, merge LinkMergeMethod, pick PickValueMethod) []paramType {
+	for _, s := range sources {
+		if len(s) == 0 {
+			return nil
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	if merge == "" && len(sources) > 1 {
+		merge = MergeNested
+	}
+
+	var t []paramType
+	switch merge {
+	case MergeNested:
+		var items []paramType
+		for _, s := range sources {
+			items = append(items, s...)
+		}
+		t = []paramType{arrayOf(items)}
+	case MergeFlattened:
+		var items []paramType
+		for _, s := range sources {
+			for _, x := range s {
+				if x.name == "array" {
+					items = append(items, x.items...)
+				} else {
+					items = append(items, x)
+				}
+			}
+		}
+		t = []paramType{arrayOf(items)}
+	default:
+		t = sources[0]
+	}
+
+	switch pick {
+	case FirstNonNull, TheOnlyNonNull:
+		return nonNull(itemTypes(t))
+	case AllNonNull:
+		return []paramType{arrayOf(nonNull(itemTypes(t)))}
+	}
+	return t
+}
+
+// processParams returns the types of the inputs and outputs of a process,
+// keyed by local ID, with references to SchemaDefRequirement types resolved.
+func processParams(doc Document, s validateScope) (inputs, outputs map[string][]paramType) {
+	inputs = map[string][]paramType{}
+	outputs = map[string][]paramType{}
+
+	inType := func(types []InputType) []paramType {
+		t, err := s.defs.inputTypes(types, nil)
+		if err != nil {
+			return nil
+		}
+		return inputParamTypes(t)
+	}
+	outType := func(types []OutputType) []paramType {
+		t, err := s.defs.outputTypes(types, nil)
+		if err != nil {
+			return nil
+		}
+		return outputParamTypes(t)
+	}
+
+	switch z := doc.(type) {
+	case *Tool:
+		for _, in := range z.Inputs {
+			inputs[LocalID(in.ID)] = inType(in.Type)
+		}
+		for _, out := range z.Outputs {
+			outputs[LocalID(out.ID)] = outType(out.Type)
+		}
+	case *ExpressionTool:
+		for _, in := range z.Inputs {
+			inputs[LocalID(in.ID)] = inType(in.Type)
+		}
+		for _, out := range z.Outputs {
+			outputs[LocalID(out.ID)] = outType(out.Type)
+		}
+	case *Workflow:
+		for _, in := range z.Inputs {
+			inputs[LocalID(in.ID)] = inType(in.Type)
+		}
+		for _, out := range z.Outputs {
+			outputs[LocalID(out.ID)] = outType(out.Type)
+		}
+	}
+	return
+}
+
+func processRequirements(doc Document) (reqs, hints []Requirement) {
+	switch z := doc.(type) {
+	case *Tool:
+		return z.Requirements, z.Hints
+	case *ExpressionTool:
+		return z.Requirements, z.Hints
+	case *Workflow:
+		return z.Requirements, z.Hints
+	}
+	return nil, nil
+}
+
+// fullID returns the fully qualified ID of an element, resolving "id"
+// against "scope" if the document's IDs haven't been resolved.
+func fullID(full, scope, id string) string {
+	if full != "" {
+		return full
+	}
+	return resolveID(scope, id)
+}
+
+// connections checks the sources of the step inputs and outputs of a
+// workflow: that they exist, that their types are compatible, and that
+// the features they use are enabled by requirements.
+func (v *validator) connections(s validateScope, wf *Workflow) {
+	wfid := wf.FullID
+
+	// Types of the sources, keyed by full ID. A nil type is unknown.
+	sources := map[string][]paramType{}
+	inputs, _ := processParams(wf, s)
+	for _, in := range wf.Inputs {
+		sources[fullID(in.FullID, wfid, in.ID)] = inputs[LocalID(in.ID)]
+	}
+
+	type stepParams struct {
+		inputs map[string][]paramType
+		known  bool
+	}
+	params := make([]stepParams, len(wf.Steps))
+
+	for i, step := range wf.Steps {
+		ss := s.with(step.Requirements, step.Hints)
+		pos := orPos(step.Pos, wf.Pos)
+		stepID := fullID(step.FullID, wfid, step.ID)
+
+		if _, ok := step.Run.(*Workflow); ok && !ss.has(SubworkflowFeatureRequirement{}) {
+			v.report(SeverityError, "missing-requirement", pos,
+				"step %q: running a workflow requires SubworkflowFeatureRequirement", step.ID)
+		}
+		if len(step.Scatter) > 0 && !ss.has(ScatterFeatureRequirement{}) {
+			v.report(SeverityError, "missing-requirement", pos,
+				"step %q: scatter requires ScatterFeatureRequirement", step.ID)
+		}
+		for _, name := range step.Scatter {
+			if !stepHasInput(step, name) {
+				v.report(SeverityError, "scatter", pos,
+					"step %q: scatter parameter %q is not an input of the step", step.ID, name)
+			}
+		}
+
+		known := isProcess(step.Run)
+		reqs, hints := processRequirements(step.Run)
+		runIn, runOut := processParams(step.Run, ss.with(reqs, hints))
+		params[i] = stepParams{inputs: runIn, known: known}
+
+		for _, out := range step.Out {
+			t, ok := runOut[LocalID(out.ID)]
+			if known && !ok {
+				v.report(SeverityError, "unknown-source", orPos(out.Pos, pos),
+					"step %q: %q is not an output of the process it runs", step.ID, out.ID)
+			}
+			sources[fullID(out.FullID, stepID, out.ID)] = scatterOutput(step, t)
+		}
+	}
+
+	for i, step := range wf.Steps {
+		ss := s.with(step.Requirements, step.Hints)
+		for _, in := range step.In {
+			pos := orPos(in.Pos, orPos(step.Pos, wf.Pos))
+			where := fmt.Sprintf("step %q input %q", step.ID, in.ID)
+
+			if len(in.Source) > 1 && !ss.has(MultipleInputFeatureRequirement{}) {
+				v.report(SeverityError, "missing-requirement", pos,
+					"%s: multiple sources require MultipleInputFeatureRequirement", where)
+			}
+			if in.ValueFrom != "" && !ss.has(StepInputExpressionRequirement{}) {
+				v.report(SeverityError, "missing-requirement", pos,
+					"%s: valueFrom requires StepInputExpressionRequirement", where)
+			}
+
+			src, ok := v.sourceTypes(sources, wfid, in.Source, in.FullSource, pos, where)
+			// The value of an input with valueFrom is the result of the expression.
+			if !ok || in.ValueFrom != "" || !params[i].known {
+				continue
+			}
+			sink, ok := params[i].inputs[LocalID(in.ID)]
+			if !ok {
+				continue
+			}
+			if stepScatters(step, in.ID) {
+				sink = []paramType{arrayOf(sink)}
+			}
+			v.checkSources(src, sink, in.Source, in.LinkMerge, in.PickValue, pos, where)
+		}
+	}
+
+	_, outputs := processParams(wf, s)
+	for _, out := range wf.Outputs {
+		pos := orPos(out.Pos, wf.Pos)
+		where := fmt.Sprintf("output %q", out.ID)
+
+		if len(out.OutputSource) > 1 && !s.has(MultipleInputFeatureRequirement{}) {
+			v.report(SeverityError, "missing-requirement", pos,
+				"%s: multiple sources require MultipleInputFeatureRequirement", where)
+		}
+		src, ok := v.sourceTypes(sources, wfid, out.OutputSource, out.FullOutputSource, pos, where)
+		if !ok {
+			continue
+		}
+		v.checkSources(src, outputs[LocalID(out.ID)], out.OutputSource, out.LinkMerge, out.PickValue, pos, where)
+	}
+}
+
+// sourceTypes returns the types of the given sources, reporting those
+// which don't exist. It returns false if any source doesn't exist.
+func (v *validator) sourceTypes(sources map[string][]paramType, wfid string, src, full []string, pos Position, where string) ([][]paramType, bool) {
+	if len(full) != len(src) {
+		full = resolveSources(wfid, src)
+	}
+	var types [][]paramType
+	ok := true
+	for i, id := range full {
+		t, found := sources[id]
+		if !found {
+			v.report(SeverityError, "unknown-source", pos, "%s: unknown source %q", where, src[i])
+			ok = false
+		}
+		types = append(types, t)
+	}
+	return types, ok
+}
+
+// checkSources reports sources whose types aren't compatible with "sink".
+// When sources are merged, every source must be compatible, since the
+// merged array holds the values of all of them.
+func (v *validator) checkSources(src [][]paramType, sink []paramType, names []string, merge LinkMergeMethod, pick PickValueMethod, pos Position, where string) {
+	if merge == "" && len(src) > 1 {
+		merge = MergeNested
+	}
+	if merge == "" {
+		if t := mergeTypes(src, merge, pick); !assignable(t, sink) {
+			v.report(SeverityError, "type-mismatch", pos,
+				"%s: source %q of type %s is not compatible with %s", where, names[0], typeString(src[0]), typeString(sink))
+		}
+		return
+	}
+	for i, s := range src {
+		t := mergeTypes([][]paramType{s}, merge, pick)
+		if !assignable(t, sink) {
+			v.report(SeverityError, "type-mismatch", pos,
+				"%s: source %q of type %s is not compatible with %s, using %s", where, names[i], typeString(s), typeString(sink), merge)
+		}
+	}
+}
+
+// scatterOutput returns the type of an output of a step, which is
+// an array if the step is scattered, and optional if it's conditional.
+func scatterOutput(step Step, t []paramType) []paramType {
+	if t == nil {
+		return nil
+	}
+	if len(step.Scatter) > 0 {
+		depth := 1
+		if step.ScatterMethod == NestedCrossProduct {
+			depth = len(step.Scatter)
+		}
+		for i := 0; i < depth; i++ {
+			t = []paramType{arrayOf(t)}
+		}
+	}
+	if step.When != "" {
+		t = append([]paramType{nullType}, t...)
+	}
+	return t
+}
+
+func stepHasInput(step Step, name string) bool {
+	for _, in := range step.In {
+		if LocalID(in.ID) == LocalID(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func stepScatters(step Step, id string) bool {
+	for _, name := range step.Scatter {
+		if LocalID(name) == LocalID(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// orPos returns "pos", or "fallback" if "pos" is unknown.
+func orPos(pos, fallback Position) Position {
+	if pos.IsValid() {
+		return pos
+	}
+	return fallback
+}
@@ -0,0 +1,193 @@
+package cwl
+
+import (
+	"strings"
+	"testing"
+)
+
+const typecheckTool = `
+      class: CommandLineTool
+      inputs:
+        reads: File
+        count: int
+      outputs:
+        bam: File
+        counts: int[]
+`
+
+func TestValidateSources(t *testing.T) {
+	diags := expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  reads: File
+  name: string
+outputs:
+  bam:
+    type: File
+    outputSource: align/bai
+  counts:
+    type: File
+    outputSource: align/counts
+steps:
+  align:
+    run:`+typecheckTool+`
+    in:
+      reads: name
+      count: nope
+    out: [bam, counts, missing]
+`,
+		"unknown-source",
+		"type-mismatch",
+		"unknown-source",
+		"unknown-source",
+		"type-mismatch",
+	)
+
+	expect := []string{
+		`step "align": "missing" is not an output of the process it runs`,
+		`step "align" input "reads": source "name" of type string is not compatible with File`,
+		`step "align" input "count": unknown source "nope"`,
+		`output "bam": unknown source "align/bai"`,
+		`output "counts": source "align/counts" of type int[] is not compatible with File`,
+	}
+	for i, d := range diags {
+		if i < len(expect) && d.Message != expect[i] {
+			t.Errorf("expected %q, got %q", expect[i], d.Message)
+		}
+	}
+}
+
+func TestValidateScatter(t *testing.T) {
+	expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+requirements:
+  - class: ScatterFeatureRequirement
+  - class: StepInputExpressionRequirement
+inputs:
+  reads: File[]
+  counts: int[]
+outputs:
+  bams:
+    type: File[]
+    outputSource: align/bam
+  nested:
+    type:
+      type: array
+      items:
+        type: array
+        items: File
+    outputSource: cross/bam
+  wrong:
+    type: File
+    outputSource: align/bam
+steps:
+  align:
+    run:`+typecheckTool+`
+    scatter: [reads, other]
+    in:
+      reads: reads
+      count:
+        source: counts
+        valueFrom: $(self[0])
+    out: [bam]
+  cross:
+    run:`+typecheckTool+`
+    scatter: [reads, count]
+    scatterMethod: nested_crossproduct
+    in:
+      reads: reads
+      count: counts
+    out: [bam]
+`, "scatter", "type-mismatch")
+}
+
+func TestValidateLinkMerge(t *testing.T) {
+	diags := expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+requirements:
+  - class: MultipleInputFeatureRequirement
+inputs:
+  a: File
+  b: File[]
+  n: int
+outputs:
+  nested:
+    type:
+      type: array
+      items: ["null", File, {type: array, items: File}]
+    outputSource: [a, b]
+  flat:
+    type: File[]
+    outputSource: [a, b]
+    linkMerge: merge_flattened
+  bad:
+    type: File[]
+    outputSource: [a, n]
+    linkMerge: merge_flattened
+  picked:
+    type: File
+    outputSource: [a, a]
+    pickValue: first_non_null
+`, "type-mismatch")
+
+	if len(diags) == 1 && !strings.Contains(diags[0].Message, `source "n" of type int is not compatible with File[], using merge_flattened`) {
+		t.Errorf("unexpected message: %s", diags[0].Message)
+	}
+}
+
+func TestValidateFeatureRequirements(t *testing.T) {
+	expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  a: File
+  b: File
+  c: File[]
+outputs:
+  both:
+    type: File[]
+    outputSource: [a, b]
+steps:
+  sub:
+    run:
+      class: Workflow
+      inputs:
+        x: File[]
+      outputs: []
+      steps: []
+    in:
+      x:
+        source: [a, b]
+    out: []
+  tool:
+    run:
+      class: CommandLineTool
+      inputs:
+        reads: File
+      outputs: []
+    in:
+      reads:
+        source: a
+        valueFrom: $(self)
+    out: []
+  many:
+    run:
+      class: CommandLineTool
+      inputs:
+        reads: File
+      outputs: []
+    scatter: reads
+    in:
+      reads: c
+    out: []
+`,
+		"missing-requirement",
+		"missing-requirement",
+		"missing-requirement",
+		"missing-requirement",
+		"missing-requirement",
+	)
+}
@@ -0,0 +1,162 @@
+package cwl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LatestVersion is the CWL version which Upgrade produces.
+const LatestVersion = "v1.2"
+
+// Upgrade rewrites a draft-3, v1.0 or v1.1 document (in place) for CWL v1.2.
+// Documents without a cwlVersion are treated as v1.0. Draft-3 documents are
+// converted to the v1.0 form when they're loaded, e.g. IDs without "#" and
+// steps with "in" and "out", so they're upgraded like v1.0 documents.
+//
+// The cwlVersion of the document, and any embedded documents, is set to v1.2.
+// Since v1.1 doesn't list Directory contents or allow network access
+// by default, draft-3 and v1.0 documents get a LoadListingRequirement
+// with "deep_listing" and a NetworkAccess requirement, which reproduce
+// the v1.0 behavior, unless they already have them.
+//
+// Old-style secondaryFiles strings are loaded as SecondaryFileSchema,
+// so they are written in the v1.2 form when the document is marshaled,
+// e.g. by MarshalYAML.
+func Upgrade(doc Document) (Document, error) {
+	u := upgrader{}
+
+	switch z := doc.(type) {
+	case Graph:
+		err := u.upgradeGraph(&z)
+		return z, err
+
+	case *Graph:
+		err := u.upgradeGraph(z)
+		return z, err
+
+	case *Tool, *Workflow, *ExpressionTool:
+		legacy, err := isLegacyVersion(docVersion(z))
+		if err != nil {
+			return nil, err
+		}
+		u.legacy = legacy
+		u.upgrade(z, true)
+		setVersion(z, LatestVersion)
+		return z, nil
+
+	default:
+		return nil, fmt.Errorf("can't upgrade document of type %s", doc.Doctype())
+	}
+}
+
+type upgrader struct {
+	// legacy is true when the document is older than v1.1.
+	legacy bool
+}
+
+func (u upgrader) upgradeGraph(g *Graph) error {
+	legacy, err := isLegacyVersion(g.CWLVersion)
+	if err != nil {
+		return err
+	}
+	g.CWLVersion = LatestVersion
+	u.legacy = legacy
+	for _, d := range g.Docs {
+		u.upgrade(d, true)
+	}
+	return nil
+}
+
+// upgrade upgrades a document and its embedded documents.
+// "top" is true for documents which don't inherit requirements,
+// i.e. the root document, or the documents of a $graph.
+func (u upgrader) upgrade(doc Document, top bool) {
+	switch z := doc.(type) {
+	case *Tool:
+		z.CWLVersion = upgradedVersion(z.CWLVersion)
+		if top && u.legacy {
+			z.Requirements = addLegacyRequirements(z.Requirements, z.Hints)
+		}
+
+	case *ExpressionTool:
+		z.CWLVersion = upgradedVersion(z.CWLVersion)
+		if top && u.legacy {
+			z.Requirements = addLegacyRequirements(z.Requirements, z.Hints)
+		}
+
+	case *Workflow:
+		z.CWLVersion = upgradedVersion(z.CWLVersion)
+		if top && u.legacy {
+			z.Requirements = addLegacyRequirements(z.Requirements, z.Hints)
+		}
+		for _, step := range z.Steps {
+			u.upgrade(step.Run, false)
+		}
+	}
+}
+
+// upgradedVersion returns the cwlVersion for an upgraded document.
+// Embedded documents, and the documents of a $graph, only get
+// a cwlVersion if they already had one.
+func upgradedVersion(v string) string {
+	if v != "" {
+		return LatestVersion
+	}
+	return ""
+}
+
+// addLegacyRequirements adds the requirements which reproduce
+// the v1.0 behavior, unless they are already present.
+func addLegacyRequirements(reqs, hints []Requirement) []Requirement {
+	var listing, network bool
+	for _, r := range append(append([]Requirement{}, reqs...), hints...) {
+		switch r.(type) {
+		case LoadListingRequirement:
+			listing = true
+		case NetworkAccess:
+			network = true
+		}
+	}
+	if !listing {
+		reqs = append(reqs, LoadListingRequirement{LoadListing: DeepListing})
+	}
+	if !network {
+		reqs = append(reqs, NetworkAccess{NetworkAccess: "true"})
+	}
+	return reqs
+}
+
+// isLegacyVersion returns true for versions older than v1.1,
+// and an error for unknown versions.
+func isLegacyVersion(v string) (bool, error) {
+	switch strings.TrimPrefix(v, "cwl:") {
+	case "", "draft-3", "v1.0":
+		return true, nil
+	case "v1.1", "v1.2":
+		return false, nil
+	}
+	return false, fmt.Errorf("can't upgrade unknown cwlVersion %q", v)
+}
+
+func setVersion(doc Document, v string) {
+	switch z := doc.(type) {
+	case *Tool:
+		z.CWLVersion = v
+	case *Workflow:
+		z.CWLVersion = v
+	case *ExpressionTool:
+		z.CWLVersion = v
+	}
+}
+
+func docVersion(doc Document) string {
+	switch z := doc.(type) {
+	case *Tool:
+		return z.CWLVersion
+	case *Workflow:
+		return z.CWLVersion
+	case *ExpressionTool:
+		return z.CWLVersion
+	}
+	return ""
+}
@@ -0,0 +1,273 @@
+package cwl
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const upgradeDoc = `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  bam:
+    type: File
+    secondaryFiles: [.bai]
+outputs: []
+steps:
+  index:
+    run:
+      class: CommandLineTool
+      inputs:
+        bam:
+          type: File
+          secondaryFiles: .bai
+      outputs: []
+    in:
+      bam: bam
+    out: []
+`
+
+func TestUpgrade(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(upgradeDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := MarshalYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "networkAccess: true\n") {
+		t.Errorf("expected networkAccess to be marshaled as a boolean:\n%s", b)
+	}
+	doc, err = LoadDocumentBytes(b, "", nil, Strict())
+	if err != nil {
+		t.Fatalf("loading upgraded document: %s\n%s", err, b)
+	}
+	wf := doc.(*Workflow)
+
+	if wf.CWLVersion != "v1.2" {
+		t.Errorf("expected cwlVersion v1.2, got %q", wf.CWLVersion)
+	}
+	if len(wf.Requirements) != 2 {
+		t.Fatalf("expected two requirements, got %#v", wf.Requirements)
+	}
+	if r, ok := wf.Requirements[0].(LoadListingRequirement); !ok || r.LoadListing != DeepListing {
+		t.Errorf("expected deep_listing LoadListingRequirement, got %#v", wf.Requirements[0])
+	}
+	if r, ok := wf.Requirements[1].(NetworkAccess); !ok || r.NetworkAccess != "true" {
+		t.Errorf("expected NetworkAccess requirement, got %#v", wf.Requirements[1])
+	}
+	if sf := wf.Inputs[0].SecondaryFiles; len(sf) != 1 || sf[0].Pattern != ".bai" {
+		t.Errorf("unexpected secondary files: %#v", sf)
+	}
+
+	tool := wf.Steps[0].Run.(*Tool)
+	if tool.CWLVersion != "" {
+		t.Errorf("expected embedded tool to have no cwlVersion, got %q", tool.CWLVersion)
+	}
+	if len(tool.Requirements) != 0 {
+		t.Errorf("expected embedded tool to inherit requirements, got %#v", tool.Requirements)
+	}
+	if sf := tool.Inputs[0].SecondaryFiles; len(sf) != 1 || sf[0].Pattern != ".bai" {
+		t.Errorf("unexpected secondary files: %#v", sf)
+	}
+}
+
+func TestUpgradeLatest(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte("cwlVersion: v1.2\nclass: CommandLineTool\ninputs: []\noutputs: []"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs := doc.(*Tool).Requirements; len(reqs) != 0 {
+		t.Errorf("expected no requirements to be added, got %#v", reqs)
+	}
+}
+
+func TestUpgradeV11(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte("cwlVersion: v1.1\nclass: CommandLineTool\ninputs: []\noutputs: []"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+	if tool.CWLVersion != "v1.2" {
+		t.Errorf("expected cwlVersion v1.2, got %q", tool.CWLVersion)
+	}
+	if len(tool.Requirements) != 0 {
+		t.Errorf("expected no requirements to be added, got %#v", tool.Requirements)
+	}
+}
+
+func TestUpgradeExistingRequirements(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+hints:
+  NetworkAccess:
+    networkAccess: false
+inputs: []
+outputs: []`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := doc.(*Tool)
+	if len(tool.Requirements) != 1 {
+		t.Fatalf("expected one requirement, got %#v", tool.Requirements)
+	}
+	if _, ok := tool.Requirements[0].(LoadListingRequirement); !ok {
+		t.Errorf("expected LoadListingRequirement, got %#v", tool.Requirements[0])
+	}
+}
+
+func TestUpgradeUnknownVersion(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte("cwlVersion: v9\nclass: CommandLineTool\ninputs: []\noutputs: []"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Upgrade(doc); err == nil {
+		t.Error("expected error")
+	}
+}
+
+var draft3Files = map[string]string{
+	"wf.cwl": `
+cwlVersion: "cwl:draft-3"
+class: Workflow
+description: Count the lines of files.
+requirements:
+  - class: ScatterFeatureRequirement
+inputs:
+  - id: "#files"
+    type: {type: array, items: File}
+outputs:
+  - id: "#counts"
+    type: {type: array, items: int}
+    source: "#wc.output"
+steps:
+  - id: "#wc"
+    run: tool.cwl
+    scatter: "#wc/file1"
+    inputs:
+      - id: "#wc/file1"
+        source: "#files"
+    outputs:
+      - id: "#wc/output"
+`,
+	"tool.cwl": `
+cwlVersion: "cwl:draft-3"
+class: CommandLineTool
+description: Count the lines of a file.
+requirements:
+  - class: ExpressionEngineRequirement
+    id: "#js"
+    engineCommand: cwlNodeEngine.js
+  - class: CreateFileRequirement
+    fileDef:
+      - filename: count.sh
+        fileContent: "wc -l < $1"
+inputs:
+  - id: "#file1"
+    type: File
+    description: The file to count.
+    inputBinding:
+      position: 1
+      secondaryFiles: [".idx"]
+      valueFrom: {engine: "cwl:JsonPointer", script: "job/file1/path"}
+outputs:
+  - id: "#output"
+    type: int
+    outputBinding:
+      glob: output.txt
+      loadContents: true
+      outputEval: {engine: "#js", script: "parseInt(self[0].contents)"}
+baseCommand: [sh, count.sh]
+stdout: output.txt
+`,
+}
+
+func TestUpgradeDraft3(t *testing.T) {
+	dir := writeFiles(t, draft3Files)
+	doc, err := Load(filepath.Join(dir, "wf.cwl"), Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = Upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = LoadDocumentBytes(b, "", nil, Strict())
+	if err != nil {
+		t.Fatalf("loading upgraded document: %s\n%s", err, b)
+	}
+
+	wf := doc.(*Workflow)
+	if wf.CWLVersion != "v1.2" || wf.Doc != "Count the lines of files." {
+		t.Errorf("unexpected workflow:\n%s", b)
+	}
+	if len(wf.Requirements) != 3 {
+		t.Errorf("expected the v1.0 requirements to be added:\n%s", b)
+	}
+	if id := wf.Inputs[0].ID; id != "files" {
+		t.Errorf("unexpected input ID %q", id)
+	}
+	if out := wf.Outputs[0]; out.ID != "counts" || !reflect.DeepEqual(out.OutputSource, []string{"wc/output"}) {
+		t.Errorf("unexpected output: %#v", out)
+	}
+	step := wf.Steps[0]
+	if step.ID != "wc" || !reflect.DeepEqual(step.Scatter, []string{"file1"}) {
+		t.Errorf("unexpected step: %#v", step)
+	}
+	if in := step.In[0]; in.ID != "file1" || !reflect.DeepEqual(in.Source, []string{"files"}) {
+		t.Errorf("unexpected step input: %#v", in)
+	}
+	if out := step.Out[0]; out.ID != "output" {
+		t.Errorf("unexpected step output: %#v", out)
+	}
+
+	tool := step.Run.(*Tool)
+	if _, ok := tool.Requirements[0].(InlineJavascriptRequirement); !ok {
+		t.Errorf("expected InlineJavascriptRequirement, got %#v", tool.Requirements[0])
+	}
+	iwd, ok := tool.Requirements[1].(InitialWorkDirRequirement)
+	if !ok || len(iwd.Listing.Entries) != 1 {
+		t.Fatalf("expected InitialWorkDirRequirement, got %#v", tool.Requirements[1])
+	}
+	if d, ok := iwd.Listing.Entries[0].(Dirent); !ok || d.Entryname != "count.sh" || d.Entry != "wc -l < $1" {
+		t.Errorf("unexpected listing: %#v", iwd.Listing.Entries[0])
+	}
+	in := tool.Inputs[0]
+	if in.ID != "file1" || in.Doc != "The file to count." {
+		t.Errorf("unexpected tool input: %#v", in)
+	}
+	if sf := in.SecondaryFiles; len(sf) != 1 || sf[0].Pattern != ".idx" {
+		t.Errorf("expected secondary files to move from the binding, got %#v", sf)
+	}
+	if v := in.InputBinding.ValueFrom; v != "$(inputs.file1.path)" {
+		t.Errorf("unexpected valueFrom %q", v)
+	}
+	if v := tool.Outputs[0].OutputBinding.OutputEval; v != "$(parseInt(self[0].contents))" {
+		t.Errorf("unexpected outputEval %q", v)
+	}
+}
@@ -1,12 +1,485 @@
 package cwl
 
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Severity describes how serious a Diagnostic is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a problem found in a document by Validate.
+type Diagnostic struct {
+	Severity Severity
+	// Rule identifies the check which found the problem, e.g. "duplicate-id".
+	Rule    string
+	Message string
+	Pos     Position
+}
+
+// String formats the diagnostic as "file:line:col: severity: message (rule)".
+func (d Diagnostic) String() string {
+	msg := fmt.Sprintf("%s: %s (%s)", d.Severity, d.Message, d.Rule)
+	if p := d.Pos.String(); p != "" {
+		return p + ": " + msg
+	}
+	return msg
+}
+
+// ValidationError lists the errors found by Validate. See ValidateTool.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return "invalid document:\n" + strings.Join(lines, "\n")
+}
+
+// HasErrors returns true if any of the diagnostics is an error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTool validates a tool, returning a *ValidationError listing
+// the errors found, if any. Warnings are ignored. See Validate.
 func ValidateTool(tool *Tool) error {
-	// TODO
-	/*
-	   TODO find a place for this validation
-	   if b.clb.Prefix == "" {
-	     return nil, errf("boolean value without prefix")
-	   }
-	*/
-	return nil
+	return validationError(Validate(tool))
+}
+
+func validationError(diags []Diagnostic) error {
+	var errs []Diagnostic
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Diagnostics: errs}
+}
+
+// Validate checks tools, expression tools and workflows for problems which
+// can be found without running them, including the documents run by steps
+// and the members of a $graph. It returns the problems found, in document order.
+//
+// The checks are:
+//   - duplicate-id: inputs, outputs and steps with the same ID.
+//   - argument-value-from: arguments without a valueFrom.
+//   - boolean-prefix: boolean inputs bound without a prefix,
+//     which never add anything to the command line (a warning).
+//   - stdout-type: stdout or stderr outputs used more than once,
+//     or inside records, arrays or unions.
+//   - unknown-type: references to types which aren't defined by a
+//     SchemaDefRequirement, or which refer to themselves.
+//   - success-codes: exit codes outside of 0-255, or listed as
+//     both success and failure codes.
+//   - inline-javascript: JavaScript expressions without
+//     InlineJavascriptRequirement. Parameter references such as
+//     $(inputs.reads.path) don't need the requirement.
+//   - unknown-source: step input and workflow output sources which aren't
+//     workflow inputs or step outputs, and step outputs which aren't
+//     outputs of the process run by the step.
+//   - type-mismatch: sources whose type isn't compatible with the type of
+//     the parameter they're connected to, accounting for linkMerge,
+//     pickValue, scatter and conditional steps.
+//   - scatter: scatter parameters which aren't inputs of the step.
+//   - missing-requirement: scatter, multiple sources, subworkflows and
+//     step input valueFrom used without ScatterFeatureRequirement,
+//     MultipleInputFeatureRequirement, SubworkflowFeatureRequirement
+//     or StepInputExpressionRequirement.
+func Validate(doc Document) []Diagnostic {
+	v := validator{seen: map[Document]bool{}}
+
+	switch z := doc.(type) {
+	case Graph:
+		v.graph(z.Docs)
+	case *Graph:
+		v.graph(z.Docs)
+	default:
+		v.process(doc, validateScope{})
+	}
+	return v.diags
+}
+
+type validator struct {
+	diags []Diagnostic
+	// seen holds the processes which have been validated, since the same
+	// process may be run by multiple steps, e.g. in a $graph.
+	seen map[Document]bool
+}
+
+// validateScope holds what a process inherits from the workflows
+// and steps which run it.
+type validateScope struct {
+	defs schemaDefs
+	reqs []Requirement
+}
+
+// with returns the scope of a process, or step, with the given requirements.
+func (s validateScope) with(reqs, hints []Requirement) validateScope {
+	var all []Requirement
+	all = append(all, s.reqs...)
+	all = append(all, reqs...)
+	all = append(all, hints...)
+	return validateScope{
+		defs: s.defs.with(reqs, hints),
+		reqs: all,
+	}
+}
+
+// has returns true if a requirement, or hint, of the same type as "req"
+// is in scope.
+func (s validateScope) has(req Requirement) bool {
+	t := reflect.TypeOf(req)
+	for _, r := range s.reqs {
+		if reflect.TypeOf(r) == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) report(sev Severity, rule string, pos Position, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{
+		Severity: sev,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+		Pos:      pos,
+	})
+}
+
+func (v *validator) graph(docs []Document) {
+	run := graphRunDocs(docs)
+	for _, d := range docs {
+		if !run[d] {
+			v.process(d, validateScope{})
+		}
+	}
+}
+
+func (v *validator) process(doc Document, parent validateScope) {
+	if !isProcess(doc) || v.seen[doc] {
+		return
+	}
+	v.seen[doc] = true
+
+	switch z := doc.(type) {
+	case *Tool:
+		s := parent.with(z.Requirements, z.Hints)
+		ids := idChecker{v: v}
+		for _, in := range z.Inputs {
+			ids.check(in.ID, in.Pos)
+			v.inputTypes(s, in.ID, in.Pos, in.Type)
+			v.booleanBinding(in.ID, in.Pos, in.Type, in.InputBinding)
+		}
+		for _, out := range z.Outputs {
+			ids.check(out.ID, out.Pos)
+			v.outputTypes(s, out.ID, out.Pos, out.Type)
+		}
+		v.stdoutTypes(z)
+		v.arguments(z)
+		v.successCodes(z)
+		v.expressions(s, z, z.Pos)
+
+	case *ExpressionTool:
+		s := parent.with(z.Requirements, z.Hints)
+		ids := idChecker{v: v}
+		for _, in := range z.Inputs {
+			ids.check(in.ID, in.Pos)
+			v.inputTypes(s, in.ID, in.Pos, in.Type)
+		}
+		for _, out := range z.Outputs {
+			ids.check(out.ID, out.Pos)
+			v.outputTypes(s, out.ID, out.Pos, out.Type)
+			v.noStdout(out.ID, out.Pos, out.Type, "an expression tool output")
+		}
+		v.expressions(s, z, z.Pos)
+
+	case *Workflow:
+		s := parent.with(z.Requirements, z.Hints)
+		ids := idChecker{v: v}
+		for _, in := range z.Inputs {
+			ids.check(in.ID, in.Pos)
+			v.inputTypes(s, in.ID, in.Pos, in.Type)
+		}
+		for _, out := range z.Outputs {
+			ids.check(out.ID, out.Pos)
+			v.outputTypes(s, out.ID, out.Pos, out.Type)
+			v.noStdout(out.ID, out.Pos, out.Type, "a workflow output")
+		}
+		for _, step := range z.Steps {
+			ids.check(step.ID, step.Pos)
+
+			in := idChecker{v: v}
+			for _, x := range step.In {
+				in.check(x.ID, x.Pos)
+			}
+			out := idChecker{v: v}
+			for _, x := range step.Out {
+				out.check(x.ID, x.Pos)
+			}
+		}
+		v.connections(s, z)
+		// Check the workflow's expressions, including those of the steps,
+		// before the documents run by the steps.
+		v.expressions(s, z, z.Pos)
+
+		for _, step := range z.Steps {
+			v.process(step.Run, s.with(step.Requirements, step.Hints))
+		}
+	}
+}
+
+// idChecker reports IDs which were already seen.
+type idChecker struct {
+	v    *validator
+	seen map[string]bool
+}
+
+func (c *idChecker) check(id string, pos Position) {
+	if id == "" {
+		return
+	}
+	if c.seen == nil {
+		c.seen = map[string]bool{}
+	}
+	name := LocalID(id)
+	if c.seen[name] {
+		c.v.report(SeverityError, "duplicate-id", pos, "duplicate ID %q", name)
+	}
+	c.seen[name] = true
+}
+
+func (v *validator) inputTypes(s validateScope, id string, pos Position, types []InputType) {
+	if _, err := s.defs.inputTypes(types, nil); err != nil {
+		v.report(SeverityError, "unknown-type", pos, "input %q: %s", id, err)
+	}
+}
+
+func (v *validator) outputTypes(s validateScope, id string, pos Position, types []OutputType) {
+	if _, err := s.defs.outputTypes(types, nil); err != nil {
+		v.report(SeverityError, "unknown-type", pos, "output %q: %s", id, err)
+	}
+}
+
+// booleanBinding reports boolean inputs, and record fields, which are bound
+// without a prefix. A boolean adds its prefix to the command line when true,
+// and nothing otherwise.
+func (v *validator) booleanBinding(id string, pos Position, types []InputType, b *CommandLineBinding) {
+	if b != nil && b.Prefix == "" && b.ValueFrom == "" {
+		for _, t := range types {
+			if _, ok := t.(Boolean); ok {
+				v.report(SeverityWarning, "boolean-prefix", pos,
+					"boolean %q is bound without a prefix, so it never adds anything to the command line", id)
+				break
+			}
+		}
+	}
+
+	for _, t := range types {
+		if r, ok := t.(InputRecord); ok {
+			for _, f := range r.Fields {
+				v.booleanBinding(f.Name, orPos(f.Pos, pos), f.Type, f.InputBinding)
+			}
+		}
+	}
+}
+
+// stdoutTypes reports stdout and stderr types used more than once,
+// or not as the only type of a tool output.
+func (v *validator) stdoutTypes(tool *Tool) {
+	var stdout, stderr int
+	for _, out := range tool.Outputs {
+		for _, t := range out.Type {
+			switch t.(type) {
+			case Stdout:
+				stdout++
+				if stdout > 1 {
+					v.report(SeverityError, "stdout-type", out.Pos, "output %q: stdout is used by more than one output", out.ID)
+				}
+			case Stderr:
+				stderr++
+				if stderr > 1 {
+					v.report(SeverityError, "stdout-type", out.Pos, "output %q: stderr is used by more than one output", out.ID)
+				}
+			}
+		}
+
+		if len(out.Type) > 1 {
+			v.noStdout(out.ID, out.Pos, out.Type, "a type union")
+			continue
+		}
+		for _, t := range out.Type {
+			switch z := t.(type) {
+			case OutputRecord:
+				for _, f := range z.Fields {
+					v.noStdout(out.ID, out.Pos, f.Type, "a record")
+				}
+			case OutputArray:
+				v.noStdout(out.ID, out.Pos, z.Items, "an array")
+			}
+		}
+	}
+}
+
+// noStdout reports stdout and stderr types, found anywhere in "types",
+// which aren't allowed in the given context, e.g. "a record".
+func (v *validator) noStdout(id string, pos Position, types []OutputType, context string) {
+	for _, t := range types {
+		switch z := t.(type) {
+		case Stdout, Stderr:
+			v.report(SeverityError, "stdout-type", pos, "output %q: %s can't be used in %s", id, t, context)
+		case OutputRecord:
+			for _, f := range z.Fields {
+				v.noStdout(id, pos, f.Type, context)
+			}
+		case OutputArray:
+			v.noStdout(id, pos, z.Items, context)
+		}
+	}
+}
+
+func (v *validator) arguments(tool *Tool) {
+	for i, arg := range tool.Arguments {
+		if arg != nil && arg.ValueFrom == "" {
+			v.report(SeverityError, "argument-value-from", orPos(arg.Pos, tool.Pos),
+				"argument %d has no valueFrom", i+1)
+		}
+	}
+}
+
+func (v *validator) successCodes(tool *Tool) {
+	lists := []struct {
+		name  string
+		codes []int
+	}{
+		{"successCodes", tool.SuccessCodes},
+		{"temporaryFailCodes", tool.TemporaryFailCodes},
+		{"permanentFailCodes", tool.PermanentFailCodes},
+	}
+
+	seen := map[int]string{}
+	for _, l := range lists {
+		for _, code := range l.codes {
+			if code < 0 || code > 255 {
+				v.report(SeverityError, "success-codes", tool.Pos, "%s: invalid exit code %d", l.name, code)
+				continue
+			}
+			if other, ok := seen[code]; ok && other != l.name {
+				v.report(SeverityError, "success-codes", tool.Pos,
+					"%s: exit code %d is also listed in %s", l.name, code, other)
+			}
+			seen[code] = l.name
+		}
+	}
+}
+
+// expressions reports JavaScript expressions used without
+// InlineJavascriptRequirement.
+func (v *validator) expressions(s validateScope, doc Document, pos Position) {
+	if s.has(InlineJavascriptRequirement{}) {
+		return
+	}
+	Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case Expression:
+			for _, js := range jsExpressions(n) {
+				v.report(SeverityError, "inline-javascript", orPos(c.Pos(), pos),
+					"expression %s requires InlineJavascriptRequirement", abbrev(js, 40))
+			}
+		case Document:
+			// Documents run by steps are checked separately.
+			return !isProcess(n) || n == doc
+		}
+		return true
+	}))
+	if et, ok := doc.(*ExpressionTool); ok && et.Expression != "" && len(jsExpressions(et.Expression)) == 0 {
+		v.report(SeverityError, "inline-javascript", pos,
+			"expression tools require InlineJavascriptRequirement")
+	}
+}
+
+// paramRef matches the parameter references which can be used without
+// InlineJavascriptRequirement, e.g. "inputs.reads.path" or "self[0]".
+var paramRef = regexp.MustCompile(`^\w+(\.\w+|\['(\\.|[^'\\])*'\]|\["(\\.|[^"\\])*"\]|\[[0-9]+\])*$`)
+
+// jsExpressions returns the JavaScript expressions in "e", which are
+// ${...} function bodies, and $(...) expressions other than parameter
+// references such as $(inputs.reads.path).
+func jsExpressions(e Expression) []string {
+	s := string(e)
+	var out []string
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != '$' || (s[i+1] != '(' && s[i+1] != '{') {
+			continue
+		}
+		end := closingBracket(s, i+1)
+		if end == -1 {
+			break
+		}
+		body := strings.TrimSpace(s[i+2 : end])
+		if s[i+1] == '{' || !paramRef.MatchString(body) {
+			out = append(out, s[i:end+1])
+		}
+		i = end
+	}
+	return out
+}
+
+// closingBracket returns the index of the bracket closing the one at
+// index "open", skipping quoted strings, or -1 if it isn't closed.
+func closingBracket(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func abbrev(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
 }
@@ -0,0 +1,228 @@
+package cwl
+
+import (
+	"strings"
+	"testing"
+)
+
+// expectDiagnostics checks that validating "src" finds exactly
+// the given rules, in order.
+func expectDiagnostics(t *testing.T, src string, rules ...string) []Diagnostic {
+	t.Helper()
+	doc, err := LoadDocumentBytes([]byte(src), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := Validate(doc)
+
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Rule)
+	}
+	if strings.Join(got, ",") != strings.Join(rules, ",") {
+		t.Errorf("expected rules %v, got:\n%s", rules, diagString(diags))
+	}
+	return diags
+}
+
+func diagString(diags []Diagnostic) string {
+	var lines []string
+	for _, d := range diags {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestValidateDuplicateID(t *testing.T) {
+	diags := expectDiagnostics(t, `
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  - id: reads
+    type: File
+  - id: "#reads"
+    type: File
+outputs:
+  reads: stdout
+`, "duplicate-id", "duplicate-id")
+
+	if d := diags[0]; d.Pos.Line != 7 || d.Severity != SeverityError {
+		t.Errorf("unexpected diagnostic: %s", d)
+	}
+
+	expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  y: File
+  z: File
+outputs: []
+steps:
+  - id: a
+    run: tool.cwl
+    in:
+      - id: x
+        source: y
+      - id: x
+        source: z
+    out: [o, o]
+  - id: a
+    run: tool.cwl
+    in: []
+    out: []
+`, "duplicate-id", "duplicate-id", "duplicate-id")
+}
+
+func TestValidateTool(t *testing.T) {
+	expectDiagnostics(t, `
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  verbose:
+    type: boolean
+    inputBinding: {}
+  quiet:
+    type: boolean
+    inputBinding:
+      prefix: -q
+  opts:
+    type:
+      type: record
+      fields:
+        - name: force
+          type: boolean
+          inputBinding:
+            position: 1
+outputs:
+  out: stdout
+  log: stdout
+  err:
+    type:
+      type: array
+      items: stderr
+arguments:
+  - prefix: --threads
+successCodes: [0, 256]
+permanentFailCodes: [0]
+`,
+		"boolean-prefix", "boolean-prefix",
+		"stdout-type", "stdout-type",
+		"argument-value-from",
+		"success-codes", "success-codes",
+	)
+}
+
+func TestValidateUnknownType(t *testing.T) {
+	expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+requirements:
+  - class: SchemaDefRequirement
+    types:
+      - name: Sample
+        type: record
+        fields:
+          - name: reads
+            type: File
+inputs:
+  sample: Sample
+  other: Missing
+outputs: []
+steps:
+  run:
+    run:
+      class: ExpressionTool
+      requirements:
+        - class: InlineJavascriptRequirement
+      inputs:
+        sample: Sample
+      outputs:
+        out: Unknown
+      expression: "${ return {}; }"
+    in:
+      sample: sample
+    out: [out]
+`, "unknown-type", "unknown-type")
+}
+
+func TestValidateInlineJavascript(t *testing.T) {
+	diags := expectDiagnostics(t, `
+cwlVersion: v1.0
+class: Workflow
+inputs: []
+outputs: []
+steps:
+  - id: a
+    run:
+      class: CommandLineTool
+      inputs:
+        reads: File
+      outputs:
+        out:
+          type: File
+          outputBinding:
+            glob: $(inputs.reads.nameroot).bam
+      arguments:
+        - valueFrom: $(inputs.reads.path.split('/'))
+        - valueFrom: $(inputs['reads'].basename)
+    in: []
+    out: []
+  - id: b
+    requirements:
+      - class: InlineJavascriptRequirement
+      - class: StepInputExpressionRequirement
+    run:
+      class: ExpressionTool
+      inputs: []
+      outputs: []
+      expression: "${ return {}; }"
+    in:
+      - id: x
+        valueFrom: ${ return 1; }
+    out: []
+`, "inline-javascript", "inline-javascript")
+
+	if !strings.Contains(diags[0].Message, "${ return 1; }") {
+		t.Errorf("expected the step expression first, got %s", diags[0])
+	}
+	if !strings.Contains(diags[1].Message, "split") {
+		t.Errorf("expected the tool argument, got %s", diags[1])
+	}
+
+	for _, e := range []string{
+		`$(inputs.bar['b\'az'])`,
+		`$(inputs.bar["b az"][0])`,
+		`$(runtime.outdir)/out.txt`,
+		`\$(1 + 2)`,
+	} {
+		if js := jsExpressions(Expression(e)); len(js) != 0 {
+			t.Errorf("unexpected JavaScript in %s: %v", e, js)
+		}
+	}
+}
+
+func TestValidateToolError(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(`
+cwlVersion: v1.0
+class: CommandLineTool
+inputs:
+  flag:
+    type: boolean
+    inputBinding: {}
+outputs: []
+`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Warnings aren't errors.
+	if err := ValidateTool(doc.(*Tool)); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	doc.(*Tool).Arguments = []*CommandLineBinding{{Prefix: "-x"}}
+	err = ValidateTool(doc.(*Tool))
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Diagnostics) != 1 || verr.Diagnostics[0].Rule != "argument-value-from" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
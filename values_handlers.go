@@ -1,9 +1,73 @@
 package cwl
 
 import (
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+// ScalarToValue loads a scalar with the type YAML gives it, e.g. 1 as an int,
+// true as a bool and null as nil, so that values such as defaults and
+// extension fields keep their types. Quoted scalars are strings.
+func (l *loader) ScalarToValue(n node) (Value, error) {
+	tag := strings.TrimPrefix(n.Tag, "tag:yaml.org,2002:")
+	tag = strings.TrimPrefix(tag, "!!")
+	switch tag {
+	case "":
+		if !n.Implicit {
+			return n.Value, nil
+		}
+	case "null":
+		return nil, nil
+	case "bool", "int", "float":
+	default:
+		// Strings, and tags which aren't YAML's.
+		return n.Value, nil
+	}
+	return resolveScalar(n.Value), nil
+}
+
+var (
+	intPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	floatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveScalar returns the value of a plain scalar, using the types of
+// the YAML 1.2 core schema.
+func resolveScalar(s string) Value {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	switch {
+	case intPattern.MatchString(s):
+		if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(i)
+		}
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0o"):
+		if i, err := strconv.ParseInt(s, 0, 0); err == nil {
+			return int(i)
+		}
+	}
+	if floatPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
 func (l *loader) SeqToValue(n node) (Value, error) {
 	vals := []Value{}
 	for _, c := range n.Children {
@@ -66,3 +130,22 @@ func (l *loader) MappingToValueMap(n node) (map[string]Value, error) {
 	}
 	return vals, nil
 }
+
+// SeqToFileDirSlice loads a list of File and Directory objects,
+// such as File.SecondaryFiles and Directory.Listing.
+func (l *loader) SeqToFileDirSlice(n node) ([]FileDir, error) {
+	var out []FileDir
+	for _, c := range n.Children {
+		var v Value
+		err := l.load(c, &v)
+		if err != nil {
+			return nil, err
+		}
+		fd, ok := v.(FileDir)
+		if !ok {
+			return nil, l.errorAt(c, errf("expected a File or Directory"))
+		}
+		out = append(out, fd)
+	}
+	return out, nil
+}
@@ -0,0 +1,287 @@
+package cwl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Visitor is called by Walk for each node of a document.
+type Visitor interface {
+	// Visit is called for a node before its children. If it returns false,
+	// the children of the node aren't visited.
+	Visit(c *Cursor) bool
+}
+
+// VisitorFunc adapts a function to the Visitor interface.
+type VisitorFunc func(c *Cursor) bool
+
+func (f VisitorFunc) Visit(c *Cursor) bool {
+	return f(c)
+}
+
+// Cursor describes a node being visited by Walk.
+type Cursor struct {
+	val  reflect.Value
+	path string
+	pos  Position
+}
+
+// Node returns the node being visited.
+//
+// Structs stored in fields and slices, e.g. a CommandInput or a Step,
+// are returned as pointers, so they can be modified in place. Values
+// stored in interfaces, e.g. an InputRecord type or a DockerRequirement,
+// are returned as values; use Replace to change them.
+func (c *Cursor) Node() interface{} {
+	if c.val.Kind() == reflect.Struct {
+		return c.val.Addr().Interface()
+	}
+	return c.val.Interface()
+}
+
+// Path returns the path of the node in the document, using the CWL field
+// names, e.g. "steps[0].run.inputs[1].inputBinding.valueFrom".
+// The path of the document itself is empty.
+func (c *Cursor) Path() string {
+	return c.path
+}
+
+// Pos returns the position of the node, or of the nearest parent
+// with a known position.
+func (c *Cursor) Pos() Position {
+	return c.pos
+}
+
+// Replace replaces the node with "n", which must have the same type as
+// the node, or be assignable to the interface holding it, e.g. any
+// InputType in place of a TypeRef. A struct node may also be replaced
+// with a pointer to a struct, and an interface or pointer node with nil.
+// The children of the new node are visited.
+//
+// Replace panics if "n" has the wrong type.
+func (c *Cursor) Replace(n interface{}) {
+	if n == nil {
+		switch c.val.Kind() {
+		case reflect.Interface, reflect.Ptr:
+			c.val.Set(reflect.Zero(c.val.Type()))
+			return
+		}
+		panic(fmt.Sprintf("cwl: can't replace %s at %q with nil", c.val.Type(), c.path))
+	}
+
+	v := reflect.ValueOf(n)
+	if c.val.Kind() == reflect.Struct && v.Kind() == reflect.Ptr && v.Type().Elem() == c.val.Type() {
+		v = v.Elem()
+	}
+	if !v.Type().AssignableTo(c.val.Type()) {
+		panic(fmt.Sprintf("cwl: can't replace %s at %q with %s", c.val.Type(), c.path, v.Type()))
+	}
+	c.val.Set(v)
+}
+
+// Walk visits the nodes of a document in depth-first order, calling "v"
+// for each process, step, input, output, type, record field, requirement,
+// binding and Expression, along with the other structs of this package
+// which make up a document. Other values, such as IDs, default values and
+// extension fields, aren't visited.
+//
+// Walk descends into the documents run by steps. Each process is visited
+// once, even if it's run by several steps; the members of a $graph are
+// visited as members, not as the documents run by steps. Empty (unset)
+// expressions aren't visited.
+//
+// Nodes may be modified in place, or replaced using Cursor.Replace.
+// Walk returns the document, which is different from "doc" if the
+// document itself was replaced.
+func Walk(doc Document, v Visitor) Document {
+	w := walker{v: v, seen: map[Document]bool{}, graph: map[Document]bool{}}
+	root := reflect.ValueOf(&doc).Elem()
+	w.walk(root, "", Position{})
+	return doc
+}
+
+type walker struct {
+	v Visitor
+	// seen holds the processes which have been visited.
+	seen map[Document]bool
+	// graph holds the members of a $graph.
+	graph map[Document]bool
+}
+
+var (
+	cwlPkg     = reflect.TypeOf(Tool{}).PkgPath()
+	optOutType = reflect.TypeOf(OptOut{})
+	exprType   = reflect.TypeOf(Expression(""))
+	docType    = reflect.TypeOf((*Document)(nil)).Elem()
+)
+
+// walk visits "val", which must be settable, and its children.
+func (w *walker) walk(val reflect.Value, path string, pos Position) {
+	if val.Type() == docType && !val.IsNil() {
+		d := val.Interface().(Document)
+		if isProcess(d) {
+			// Graph members are only visited at their place in the graph.
+			if w.seen[d] || (w.graph[d] && !isGraphPath(path)) {
+				return
+			}
+			w.seen[d] = true
+		}
+		switch z := d.(type) {
+		case Graph:
+			w.markGraph(z.Docs)
+		case *Graph:
+			w.markGraph(z.Docs)
+		}
+	}
+
+	if p := nodePos(val); p.IsValid() {
+		pos = p
+	}
+	if isNode(val) {
+		c := &Cursor{val: val, path: path, pos: pos}
+		if !w.v.Visit(c) {
+			return
+		}
+		if p := nodePos(val); p.IsValid() {
+			pos = p
+		}
+	}
+
+	switch val.Kind() {
+	case reflect.Interface:
+		if val.IsNil() {
+			return
+		}
+		elem := val.Elem()
+		if elem.Kind() == reflect.Ptr {
+			w.children(elem, path, pos)
+			return
+		}
+		// Values in interfaces aren't settable, so walk a copy
+		// and store it, in case it was modified.
+		cp := reflect.New(elem.Type()).Elem()
+		cp.Set(elem)
+		w.children(cp, path, pos)
+		val.Set(cp)
+
+	default:
+		w.children(val, path, pos)
+	}
+}
+
+// children walks the children of "val", which is settable,
+// or a pointer.
+func (w *walker) children(val reflect.Value, path string, pos Position) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			w.children(val.Elem(), path, pos)
+		}
+
+	case reflect.Struct:
+		if val.Type().PkgPath() != cwlPkg || val.Type() == optOutType {
+			return
+		}
+		for i := 0; i < val.NumField(); i++ {
+			f := val.Type().Field(i)
+			name, ok := fieldName(f)
+			if !ok {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			w.walk(val.Field(i), name, pos)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			w.walk(val.Index(i), fmt.Sprintf("%s[%d]", path, i), pos)
+		}
+
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return
+		}
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, k := range keys {
+			// Map values aren't settable, so walk a copy and store it.
+			cp := reflect.New(val.Type().Elem()).Elem()
+			cp.Set(val.MapIndex(k))
+			w.walk(cp, path+"."+k.String(), pos)
+			val.SetMapIndex(k, cp)
+		}
+	}
+}
+
+func (w *walker) markGraph(docs []Document) {
+	for _, d := range docs {
+		w.graph[d] = true
+	}
+}
+
+func isGraphPath(path string) bool {
+	return strings.HasPrefix(path, "$graph[") && !strings.Contains(path, ".")
+}
+
+// fieldName returns the CWL name of a struct field, and false for fields
+// which aren't part of the document, such as Pos, FullID and Extensions,
+// and for default values, which are data rather than nodes.
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" || f.Type == reflect.TypeOf((*Value)(nil)).Elem() {
+		return "", false
+	}
+	tag := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(f.Name[:1]) + f.Name[1:], true
+	}
+	return tag, true
+}
+
+// isNode returns true for the values which are passed to the visitor:
+// structs of this package, pointers to them, interfaces holding them,
+// and expressions which aren't empty.
+func isNode(val reflect.Value) bool {
+	if val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return false
+		}
+		val = val.Elem()
+	}
+	t := val.Type()
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return false
+		}
+		t = t.Elem()
+	}
+	if t == exprType {
+		return val.String() != ""
+	}
+	return t.Kind() == reflect.Struct && t.PkgPath() == cwlPkg && t != optOutType && t != posType
+}
+
+// nodePos returns the value of the Pos field of a node, if it has one.
+func nodePos(val reflect.Value) Position {
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return Position{}
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return Position{}
+	}
+	if p := val.FieldByName("Pos"); p.IsValid() && p.Type() == posType {
+		return p.Interface().(Position)
+	}
+	return Position{}
+}
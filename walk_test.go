@@ -0,0 +1,156 @@
+package cwl
+
+import (
+	"strings"
+	"testing"
+)
+
+const walkDoc = `
+cwlVersion: v1.0
+class: Workflow
+inputs:
+  reads: File
+outputs: []
+steps:
+  align:
+    run:
+      class: CommandLineTool
+      requirements:
+        - class: EnvVarRequirement
+          envDef:
+            B: $(inputs.reads.basename)
+            A: $(inputs.reads.path)
+      inputs:
+        reads:
+          type: Sample
+          inputBinding:
+            prefix: -i
+      outputs: []
+      arguments:
+        - valueFrom: $(runtime.cores)
+    in:
+      reads: reads
+    out: []
+`
+
+func TestWalk(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(walkDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case Expression:
+			paths = append(paths, c.Path()+"="+string(n))
+		case *CommandLineBinding:
+			paths = append(paths, c.Path())
+		case TypeRef:
+			paths = append(paths, c.Path()+"="+n.Name)
+			if c.Pos().Line != 18 {
+				t.Errorf("unexpected position of %s: %s", c.Path(), c.Pos())
+			}
+		}
+		return true
+	}))
+
+	expect := []string{
+		"steps[0].run.requirements[0].envDef.A=$(inputs.reads.path)",
+		"steps[0].run.requirements[0].envDef.B=$(inputs.reads.basename)",
+		"steps[0].run.inputs[0].type[0]=Sample",
+		"steps[0].run.inputs[0].inputBinding",
+		"steps[0].run.arguments[0]",
+		"steps[0].run.arguments[0].valueFrom=$(runtime.cores)",
+	}
+	if got := strings.Join(paths, "\n"); got != strings.Join(expect, "\n") {
+		t.Errorf("unexpected paths:\n%s", got)
+	}
+}
+
+func TestWalkRewrite(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(walkDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		switch n := c.Node().(type) {
+		case *CommandInput:
+			n.Doc = "documented"
+		case TypeRef:
+			c.Replace(FileType{})
+		case Expression:
+			c.Replace(Expression(strings.Replace(string(n), "inputs.reads", "inputs.bam", 1)))
+		case *CommandLineBinding:
+			if n.Prefix != "" {
+				c.Replace(nil)
+			}
+		}
+		return true
+	}))
+
+	tool := doc.(*Workflow).Steps[0].Run.(*Tool)
+	in := tool.Inputs[0]
+	if in.Doc != "documented" || in.InputBinding != nil {
+		t.Errorf("unexpected input: %#v", in)
+	}
+	if _, ok := in.Type[0].(FileType); !ok {
+		t.Errorf("unexpected type: %#v", in.Type)
+	}
+	env := tool.Requirements[0].(EnvVarRequirement).EnvDef
+	if env["A"] != "$(inputs.bam.path)" || env["B"] != "$(inputs.bam.basename)" {
+		t.Errorf("unexpected envDef: %#v", env)
+	}
+
+	// Replacing with the wrong type panics.
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		if _, ok := c.Node().(Expression); ok {
+			c.Replace("string")
+		}
+		return true
+	}))
+}
+
+func TestWalkGraph(t *testing.T) {
+	doc, err := LoadDocumentBytes([]byte(packedDoc), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put the workflow first, so that its step is walked before
+	// the tool it runs.
+	g := doc.(Graph)
+	g.Docs[0], g.Docs[1] = g.Docs[1], g.Docs[0]
+
+	var procs []string
+	Walk(g, VisitorFunc(func(c *Cursor) bool {
+		if d, ok := c.Node().(Document); ok && isProcess(d) {
+			procs = append(procs, c.Path())
+		}
+		return true
+	}))
+
+	// The tool is only visited as a member of the graph.
+	if got := strings.Join(procs, ","); got != "$graph[0],$graph[1]" {
+		t.Errorf("unexpected processes: %s", got)
+	}
+
+	// The document itself can be replaced.
+	tool := &Tool{ID: "replaced"}
+	got := Walk(doc, VisitorFunc(func(c *Cursor) bool {
+		if c.Path() == "" {
+			c.Replace(tool)
+			return false
+		}
+		return true
+	}))
+	if got != tool {
+		t.Errorf("expected the replaced document, got %#v", got)
+	}
+}
@@ -12,6 +12,13 @@ type Workflow struct {
 	Inputs  []WorkflowInput  `json:"inputs,omitempty"`
 	Outputs []WorkflowOutput `json:"outputs,omitempty"`
 	Steps   []Step           `json:"steps,omitempty"`
+
+	Extensions map[string]Value `json:"-" yaml:"-"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 // TODO exactly the same as CommandInput? Changing in v1.1?
@@ -20,14 +27,19 @@ type WorkflowInput struct {
 	Label      string `json:"label,omitempty"`
 	Doc        string `json:"doc,omitempty"`
 	Streamable bool   `json:"streamable,omitempty"`
-	Default        Value               `json:"default,omitempty"`
+	Default    Value  `json:"default,omitempty"`
+
+	Type []InputType `json:"type,omitempty"`
 
-	Type           []InputType         `json:"type,omitempty"`
+	SecondaryFiles []SecondaryFileSchema `json:"secondaryFiles,omitempty"`
+	Format         []Expression          `json:"format,omitempty"`
 
-	SecondaryFiles []Expression        `json:"secondaryFiles,omitempty"`
-	Format         []Expression        `json:"format,omitempty"`
+	InputBinding *CommandLineBinding `json:"inputBinding,omitempty"`
 
-	InputBinding   *CommandLineBinding `json:"inputBinding,omitempty"`
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type WorkflowOutput struct {
@@ -37,12 +49,20 @@ type WorkflowOutput struct {
 	Streamable bool            `json:"streamable,omitempty"`
 	LinkMerge  LinkMergeMethod `json:"linkMerge,omitempty"`
 
-	Type           []OutputType `json:"type,omitempty"`
-	SecondaryFiles []Expression `json:"secondaryFiles,omitempty"`
-	Format         []Expression `json:"format,omitempty"`
+	Type           []OutputType          `json:"type,omitempty"`
+	SecondaryFiles []SecondaryFileSchema `json:"secondaryFiles,omitempty"`
+	Format         []Expression          `json:"format,omitempty"`
 
 	OutputBinding *CommandOutputBinding `json:"outputBinding,omitempty"`
 	OutputSource  []string              `json:"outputSource,omitempty"`
+	PickValue     PickValueMethod       `json:"pickValue,omitempty"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+	// FullOutputSource holds the fully qualified IDs of OutputSource.
+	FullOutputSource []string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type Step struct {
@@ -60,16 +80,40 @@ type Step struct {
 
 	Scatter       []string      `json:"scatter,omitempty"`
 	ScatterMethod ScatterMethod `json:"scatterMethod,omitempty"`
+
+	// When is a conditional expression. The step is skipped when
+	// it evaluates to false, and the step's outputs are null.
+	When Expression `json:"when,omitempty"`
+
+	Extensions map[string]Value `json:"-" yaml:"-"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type StepInput struct {
 	ID        string          `json:"id,omitempty"`
 	Source    []string        `json:"source,omitempty"`
 	LinkMerge LinkMergeMethod `json:"linkMerge,omitempty"`
+	PickValue PickValueMethod `json:"pickValue,omitempty"`
 	Default   Value           `json:"default,omitempty"`
 	ValueFrom Expression      `json:"valueFrom,omitempty"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+	// FullSource holds the fully qualified IDs of Source.
+	FullSource []string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
 
 type StepOutput struct {
 	ID string `json:"id,omitempty"`
+
+	FullID  string `json:"-" yaml:"-"`
+	LocalID string `json:"-" yaml:"-"`
+
+	Pos Position `json:"-" yaml:"-"`
 }
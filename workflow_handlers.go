@@ -20,14 +20,15 @@ func (l *loader) MappingToWorkflowInputSlice(n node) ([]WorkflowInput, error) {
 				return nil, err
 			}
 			i.ID = k
-		case yamlast.ScalarNode:
+		// A type, or a list of types, e.g. "[File, 'null']".
+		case yamlast.ScalarNode, yamlast.SequenceNode:
 			err := l.load(v, &i.Type)
 			if err != nil {
 				return nil, err
 			}
+			i.Pos = l.pos(v)
 		default:
-			// TODO want errors to return position information
-			return nil, fmt.Errorf("invalid yaml node type for workflow input")
+			return nil, l.errorAt(v, fmt.Errorf("invalid yaml node type for workflow input"))
 		}
 
 		inputs = append(inputs, i)
@@ -52,14 +53,14 @@ func (l *loader) MappingToWorkflowOutputSlice(n node) ([]WorkflowOutput, error)
 			}
 			o.ID = k
 
-		case yamlast.ScalarNode:
+		case yamlast.ScalarNode, yamlast.SequenceNode:
 			err := l.load(v, &o.Type)
 			if err != nil {
 				return nil, err
 			}
+			o.Pos = l.pos(v)
 		default:
-			// TODO want errors to return position information
-			return nil, fmt.Errorf("invalid yaml node type for workflow output")
+			return nil, l.errorAt(v, fmt.Errorf("invalid yaml node type for workflow output"))
 		}
 		outputs = append(outputs, o)
 	}
@@ -68,7 +69,7 @@ func (l *loader) MappingToWorkflowOutputSlice(n node) ([]WorkflowOutput, error)
 }
 
 func (l *loader) MappingToStepSlice(n node) ([]Step, error) {
-	steps := []Step{}
+	var steps []Step
 	for _, kv := range itermap(n) {
 		k := kv.k
 		v := kv.v
@@ -88,7 +89,7 @@ func (l *loader) ScalarToStepOutput(n node) (StepOutput, error) {
 }
 
 func (l *loader) SeqToStepInputSlice(n node) ([]StepInput, error) {
-	ins := []StepInput{}
+	var ins []StepInput
 	for _, c := range n.Children {
 		in := StepInput{}
 		err := l.load(c, &in)
@@ -102,7 +103,7 @@ func (l *loader) SeqToStepInputSlice(n node) ([]StepInput, error) {
 }
 
 func (l *loader) SeqToStepOutputSlice(n node) ([]StepOutput, error) {
-	outs := []StepOutput{}
+	var outs []StepOutput
 	for _, c := range n.Children {
 		out := StepOutput{}
 		err := l.load(c, &out)
@@ -115,7 +116,7 @@ func (l *loader) SeqToStepOutputSlice(n node) ([]StepOutput, error) {
 }
 
 func (l *loader) MappingToStepInputSlice(n node) ([]StepInput, error) {
-	ins := []StepInput{}
+	var ins []StepInput
 	for _, kv := range itermap(n) {
 		k := kv.k
 		v := kv.v
@@ -131,12 +132,29 @@ func (l *loader) MappingToStepInputSlice(n node) ([]StepInput, error) {
 
 		case yamlast.ScalarNode:
 			in.Source = []string{v.Value}
+			in.Pos = l.pos(v)
+
+		// A list of sources, e.g. "[file1, file2]".
+		case yamlast.SequenceNode:
+			err := l.load(v, &in.Source)
+			if err != nil {
+				return nil, err
+			}
+			in.Pos = l.pos(v)
 		default:
-			// TODO want errors to return position information
-			return nil, fmt.Errorf("invalid yaml node type for step input")
+			return nil, l.errorAt(v, fmt.Errorf("invalid yaml node type for step input"))
 		}
 
 		ins = append(ins, in)
 	}
 	return ins, nil
 }
+
+func (l *loader) ScalarToPickValueMethod(n node) (PickValueMethod, error) {
+	switch x := PickValueMethod(n.Value); x {
+	case FirstNonNull, TheOnlyNonNull, AllNonNull:
+		return x, nil
+	default:
+		return "", fmt.Errorf("invalid pickValue method: %s", n.Value)
+	}
+}
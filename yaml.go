@@ -0,0 +1,254 @@
+package cwl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-yaml/yaml"
+	"strings"
+)
+
+// MarshalYAML marshals a document to YAML.
+//
+// The document is marshaled via JSON, so that the output uses the CWL field
+// names (e.g. "cwlVersion", "class"), and keeps the field order of the types.
+func MarshalYAML(doc Document) ([]byte, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(b)
+}
+
+// MarshalCompactYAML marshals a document to YAML, like MarshalYAML, using
+// the shortcut forms which are usually written by hand, e.g.
+//   - inputs, outputs, steps and step inputs as maps keyed by ID, and
+//     requirements and hints as maps keyed by class
+//   - "reads: File[]" for an input with only a type, and "x: step1/out"
+//     for a step input with only a source
+//   - types such as "File?", "string[]" and "int[]?"
+//   - lists with a single item, e.g. "baseCommand" and "glob", as the item
+//
+// Load reads the output back as the same document.
+func MarshalCompactYAML(doc Document) ([]byte, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decodeOrderedJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(compactYAML(v))
+}
+
+// jsonToYAML converts a JSON document to YAML, preserving the order of keys.
+func jsonToYAML(b []byte) ([]byte, error) {
+	v, err := decodeOrderedJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+func decodeOrderedJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeOrdered(dec)
+}
+
+// decodeOrdered decodes the next JSON value, using yaml.MapSlice for objects
+// so that the order of keys is preserved.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := yaml.MapSlice{}
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				m = append(m, yaml.MapItem{Key: k, Value: v})
+			}
+			// Consume the closing delimiter.
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return m, nil
+
+		case '[':
+			l := []interface{}{}
+			for dec.More() {
+				v, err := decodeOrdered(dec)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, v)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return l, nil
+		}
+		return nil, fmt.Errorf("unexpected JSON delimiter %q", t)
+
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	}
+	return tok, nil
+}
+
+// compactYAML rewrites a document decoded by decodeOrdered to use
+// shortcut forms. See MarshalCompactYAML.
+func compactYAML(v interface{}) interface{} {
+	switch z := v.(type) {
+	case yaml.MapSlice:
+		out := yaml.MapSlice{}
+		for _, item := range z {
+			k, _ := item.Key.(string)
+			val := item.Value
+			switch {
+			case k == "$graph":
+				val = compactYAML(val)
+			case k == "default" || isExtensionField(k):
+				// Values and extensions are kept as they are.
+			case k == "inputs" || k == "outputs" || k == "steps":
+				val = compactMap(compactYAML(val), "id", "type")
+			case k == "in":
+				val = compactMap(compactYAML(val), "id", "source")
+			case k == "requirements" || k == "hints":
+				val = compactMap(compactYAML(val), "class", "")
+			case k == "out":
+				val = compactStepOutputs(compactYAML(val))
+			case k == "type" || k == "items":
+				val = compactType(compactYAML(val))
+			case k == "baseCommand" || k == "glob" || k == "source" ||
+				k == "outputSource" || k == "scatter" || k == "format":
+				val = compactList(compactYAML(val))
+			default:
+				val = compactYAML(val)
+			}
+			out = append(out, yaml.MapItem{Key: item.Key, Value: val})
+		}
+		return out
+
+	case []interface{}:
+		out := make([]interface{}, len(z))
+		for i, x := range z {
+			out[i] = compactYAML(x)
+		}
+		return out
+	}
+	return v
+}
+
+// compactMap rewrites a list of objects as a map keyed by the "key" field
+// of each object. An object with only a "short" field, which is a string,
+// is written as that string.
+//
+// The list is left as it is if any object doesn't have a unique key.
+func compactMap(v interface{}, key, short string) interface{} {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return v
+	}
+
+	out := yaml.MapSlice{}
+	seen := map[string]bool{}
+	for _, x := range list {
+		obj, ok := x.(yaml.MapSlice)
+		if !ok {
+			return v
+		}
+
+		var id string
+		rest := yaml.MapSlice{}
+		for _, item := range obj {
+			if item.Key == key {
+				id, _ = item.Value.(string)
+			} else {
+				rest = append(rest, item)
+			}
+		}
+		if id == "" || seen[id] {
+			return v
+		}
+		seen[id] = true
+
+		var val interface{} = rest
+		if len(rest) == 1 && rest[0].Key == short {
+			if s, ok := rest[0].Value.(string); ok {
+				val = s
+			}
+		}
+		out = append(out, yaml.MapItem{Key: id, Value: val})
+	}
+	return out
+}
+
+// compactStepOutputs rewrites the outputs of a step which only have an ID,
+// e.g. [{id: out}], as a list of IDs, e.g. [out].
+func compactStepOutputs(v interface{}) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	out := make([]interface{}, len(list))
+	for i, x := range list {
+		obj, ok := x.(yaml.MapSlice)
+		if !ok || len(obj) != 1 || obj[0].Key != "id" {
+			return v
+		}
+		out[i] = obj[0].Value
+	}
+	return out
+}
+
+// compactType rewrites a type as a string, e.g. "File[]?",
+// if it has a shortcut form.
+func compactType(v interface{}) interface{} {
+	switch z := v.(type) {
+	case []interface{}:
+		if len(z) == 1 {
+			return compactType(z[0])
+		}
+		// The loader reads "File?" as [File, null].
+		if len(z) == 2 && z[1] == "null" {
+			if s, ok := z[0].(string); ok && !strings.HasSuffix(s, "?") {
+				return s + "?"
+			}
+		}
+
+	case yaml.MapSlice:
+		if len(z) == 2 && z[0].Key == "type" && z[0].Value == "array" && z[1].Key == "items" {
+			if s, ok := z[1].Value.(string); ok {
+				return s + "[]"
+			}
+		}
+	}
+	return v
+}
+
+// compactList rewrites a list with a single string as the string.
+func compactList(v interface{}) interface{} {
+	if list, ok := v.([]interface{}); ok && len(list) == 1 {
+		if s, ok := list[0].(string); ok {
+			return s
+		}
+	}
+	return v
+}